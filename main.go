@@ -20,9 +20,9 @@ import (
 )
 
 func main() {
-	app := app.New()
+	a := app.New()
 	ctx := signals.SetupSignalContext()
-	if err := app.RunContext(ctx, os.Args); err != nil && !errors.Is(err, context.Canceled) {
+	if err := a.RunContext(ctx, app.ReorderArgs(a, os.Args)); err != nil && !errors.Is(err, context.Canceled) {
 		logrus.Fatal(err)
 	}
 }
@@ -36,6 +36,14 @@ var (
 	ServerSideAuto  = ServerSide("auto")
 )
 
+// +kubebuilder:validation:Enum={"cluster","namespaced"}
+type RBACScope string
+
+var (
+	RBACScopeCluster    = RBACScope("cluster")
+	RBACScopeNamespaced = RBACScope("namespaced")
+)
+
 // +genclient
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:shortName=hc
@@ -44,8 +52,13 @@ var (
 // +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.spec.version`
 // +kubebuilder:printcolumn:name="TargetNamespace",type=string,JSONPath=`.spec.targetNamespace`
 // +kubebuilder:printcolumn:name="Bootstrap",type=boolean,JSONPath=`.spec.bootstrap`
+// +kubebuilder:printcolumn:name="Installed Version",type=string,JSONPath=`.status.release.chartVersion`
+// +kubebuilder:printcolumn:name="App Version",type=string,JSONPath=`.status.release.appVersion`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=='Ready')].status`
 // +kubebuilder:printcolumn:name="Failed",type=string,JSONPath=`.status.conditions[?(@.type=='Failed')].status`
+// +kubebuilder:printcolumn:name="Revision",type=integer,JSONPath=`.status.releaseRevision`,priority=10
 // +kubebuilder:printcolumn:name="Job",type=string,JSONPath=`.status.jobName`,priority=10
+// +kubebuilder:printcolumn:name="Suspended",type=boolean,JSONPath=`.spec.suspend`,priority=10
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // HelmChart represents configuration and state for the deployment of a Helm chart.
@@ -69,6 +82,8 @@ type HelmChartSpec struct {
 	// Helm CLI positional argument/flag: `CHART`
 	Chart string `json:"chart,omitempty"`
 	// Helm Chart version. Only used when installing from repository; ignored when .spec.chart or .spec.chartContent is used to install a specific chart archive.
+	// May also be a semver range such as `~1.4` or `>=2.0 <3`, when installing from a repository or OCI registry. The controller periodically resolves
+	// the newest matching version, and upgrades the chart when a newer matching version is published.
 	// Helm CLI positional argument/flag: `--version`
 	Version string `json:"version,omitempty"`
 	// Helm Chart repository URL.
@@ -80,6 +95,9 @@ type HelmChartSpec struct {
 	// Reference to a ConfigMap containing CA Certificates to be be trusted by Helm. Can be used along with or instead of `.spec.repoCA`
 	// Helm CLI positional argument/flag: `--ca-file`
 	RepoCAConfigMap *corev1.LocalObjectReference `json:"repoCAConfigMap,omitempty"`
+	// Namespace of the ConfigMap referenced by `.spec.repoCAConfigMap`. Defaults to the namespace of the HelmChart.
+	// References to other namespaces must be permitted by a HelmChartReferenceGrant in that namespace.
+	RepoCAConfigMapNamespace string `json:"repoCAConfigMapNamespace,omitempty"`
 	// Override simple Chart values. These take precedence over options set via values or valuesContent.
 	// Helm CLI positional argument/flag: `--set`, `--set-string`
 	Set map[string]intstr.IntOrString `json:"set,omitempty"`
@@ -92,6 +110,9 @@ type HelmChartSpec struct {
 	// Override complex Chart values via references to external Secrets.
 	// Helm CLI positional argument/flag: `--values`
 	ValuesSecrets []SecretSpec `json:"valuesSecrets,omitempty"`
+	// Override complex Chart values via references to external ConfigMaps. Applied after values from ValuesSecrets.
+	// Helm CLI positional argument/flag: `--values`
+	ValuesConfigMaps []ConfigMapSpec `json:"valuesConfigMaps,omitempty"`
 	// DEPRECATED. Helm version to use. Only v3 is currently supported.
 	HelmVersion string `json:"helmVersion,omitempty"`
 	// Set to True if this chart is needed to bootstrap the cluster (Cloud Controller Manager, CNI, etc).
@@ -126,6 +147,9 @@ type HelmChartSpec struct {
 	FailurePolicy FailurePolicy `json:"failurePolicy,omitempty"`
 	// Reference to Secret of type kubernetes.io/basic-auth holding Basic auth credentials for the Chart repo.
 	AuthSecret *corev1.LocalObjectReference `json:"authSecret,omitempty"`
+	// Namespace of the Secret referenced by `.spec.authSecret`. Defaults to the namespace of the HelmChart.
+	// References to other namespaces must be permitted by a HelmChartReferenceGrant in that namespace.
+	AuthSecretNamespace string `json:"authSecretNamespace,omitempty"`
 	// Pass Basic auth credentials to all domains.
 	// Helm CLI positional argument/flag: `--pass-credentials`
 	AuthPassCredentials bool `json:"authPassCredentials,omitempty"`
@@ -137,10 +161,35 @@ type HelmChartSpec struct {
 	PlainHTTP bool `json:"plainHTTP,omitempty"`
 	// Reference to Secret of type kubernetes.io/dockerconfigjson holding Docker auth credentials for the OCI-based registry acting as the Chart repo.
 	DockerRegistrySecret *corev1.LocalObjectReference `json:"dockerRegistrySecret,omitempty"`
+	// Namespace of the Secret referenced by `.spec.dockerRegistrySecret`. Defaults to the namespace of the HelmChart.
+	// References to other namespaces must be permitted by a HelmChartReferenceGrant in that namespace.
+	DockerRegistrySecretNamespace string `json:"dockerRegistrySecretNamespace,omitempty"`
 	// Custom PodSecurityContext for the helm job pod.
 	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
 	// custom SecurityContext for the helm job pod.
 	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+	// Node selector for the helm job pod. Merged over the default node selector, which selects Linux nodes, and control-plane nodes for bootstrap charts.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Affinity for the helm job pod.
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+	// Tolerations for the helm job pod. Added to the tolerations configured on the controller, and the default tolerations for bootstrap charts.
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// Priority class for the helm job pod. Defaults to `system-cluster-critical`.
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// Topology spread constraints for the helm job pod.
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+	// Compute resources for the helm job container. Overrides the resources configured on the controller.
+	JobResources *corev1.ResourceRequirements `json:"jobResources,omitempty"`
+	// Name of an existing ServiceAccount in the HelmChart's namespace to run the helm job as.
+	// If set, the controller does not create a ServiceAccount or bind a role for the helm job; the ServiceAccount must already have the permissions needed to manage the release.
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+	// Scope of the permissions granted to the ServiceAccount created for the helm job.
+	// - `cluster` binds the job role with a ClusterRoleBinding; this is the default behavior.
+	// - `namespaced` binds the job role with a RoleBinding in the target namespace, which must already exist. Cannot be used with `createNamespace`.
+	RBACScope RBACScope `json:"rbacScope,omitempty"`
+	// Role to bind to the ServiceAccount created for the helm job. Defaults to the ClusterRole configured on the controller.
+	// A Role may only be used with the `namespaced` RBAC scope, and must exist in the target namespace.
+	JobRole *JobRoleReference `json:"jobRole,omitempty"`
 	// Helm storage driver to use for this chart's release metadata.
 	// `secret` stores releases in Kubernetes Secrets (default).
 	// `configmap` stores releases in ConfigMaps.
@@ -149,14 +198,180 @@ type HelmChartSpec struct {
 	// +kubebuilder:default=secret
 	// +kubebuilder:validation:XValidation:rule="!oldSelf.hasValue() || self == oldSelf.value()",message="driver is immutable after creation",optionalOldSelf=true
 	Driver HelmDriver `json:"driver,omitempty"`
+	// List of HelmCharts that must be successfully deployed before this chart is installed or upgraded.
+	// A dependency is considered deployed once its latest release has the `deployed` status and a config hash matching its current job.
+	DependsOn []HelmChartReference `json:"dependsOn,omitempty"`
+	// Roll back the release to the specified revision, instead of installing or upgrading the chart.
+	// Set to `0` to roll back to the last successfully deployed revision prior to the latest release.
+	// While this field is set, changes to the chart configuration are not applied; clear it to resume upgrades.
+	// Helm CLI positional argument/flag: `rollback <revision>`
+	// +kubebuilder:validation:Minimum=0
+	RollbackTo *int64 `json:"rollbackTo,omitempty"`
+	// Preview the changes that would be made by installing or upgrading the chart, instead of applying them.
+	// The rendered manifest is compared against that of the deployed release; the diff is written to the ConfigMap `chart-diff-<name>`, and summarized in the `DryRun` condition.
+	// The contents of Secrets are redacted from the ConfigMap.
+	// While this field is set, changes to the chart configuration are not applied; clear it to apply the previewed changes.
+	// Helm CLI positional argument/flag: `--dry-run`
+	DryRun bool `json:"dryRun,omitempty"`
+	// Periodically compare the live resources of the deployed release against its manifest, and report any differences in the `Drifted` condition.
+	// Drift detection is disabled if this field is not set.
+	DriftDetection *DriftDetection `json:"driftDetection,omitempty"`
+	// Verify the health of the release once the helm job has installed or upgraded it, and report the result in the `Healthy` condition.
+	// Health checks are disabled if this field is not set.
+	HealthChecks *HealthChecks `json:"healthChecks,omitempty"`
+	// Run the tests defined by the chart once the release has been installed or upgraded, and report the result in the `Tested` condition.
+	// The tests are run by the Job `helm-test-<name>`; the tail of the test output is included in the condition message.
+	// Helm CLI positional argument/flag: `test`
+	Test *ReleaseTest `json:"test,omitempty"`
+	// Git repository to retrieve the chart from. Takes precedence over `chart` and `chartContent`.
+	// The controller resolves the ref to a commit, and packages the chart from the repository at that commit.
+	Git *GitSource `json:"git,omitempty"`
+	// Restrict changes to the release to recurring maintenance windows.
+	// Outside of the window, changes to the chart configuration are recorded in `.status.pendingConfigHash` and the `Pending` condition, and are applied once the window opens.
+	// Dry runs are not restricted; bootstrap charts and uninstalls are only restricted if enabled in the window.
+	UpgradeWindow *UpgradeWindow `json:"upgradeWindow,omitempty"`
+	// Suspend installation and upgrade of the chart. While suspended, changes to the chart configuration are not applied, and no jobs are
+	// created or resumed; the release is left as-is, and the `Suspended` condition is reported. Deleting a suspended chart still uninstalls the release.
+	// Unlike the `helmcharts.helm.cattle.io/unmanaged` annotation, the controller continues to manage the finalizer and status of a suspended chart.
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// UpgradeWindow represents recurring windows during which changes may be made to a release.
+type UpgradeWindow struct {
+	// Cron schedules at which the window opens, in standard five-field format or as a descriptor such as `@daily`.
+	// +kubebuilder:validation:MinItems=1
+	Schedules []string `json:"schedules"`
+	// Length of time that the window stays open after each scheduled start time.
+	Duration metav1.Duration `json:"duration"`
+	// IANA time zone that the schedules are evaluated in, such as `Europe/Berlin`. Defaults to UTC.
+	TimeZone string `json:"timeZone,omitempty"`
+	// Also hold uninstalling the chart until the window opens, when the HelmChart is deleted.
+	// By default, uninstalls are not restricted by the window.
+	DeferDeletion bool `json:"deferDeletion,omitempty"`
+	// Also restrict changes to bootstrap charts to the window.
+	// By default, bootstrap charts are not restricted by the window, as they may be required for the cluster to function.
+	DeferBootstrap bool `json:"deferBootstrap,omitempty"`
+	// Also hold rollbacks until the window opens, whether requested by `.spec.rollbackTo` or made after failed tests.
+	// By default, rollbacks are not restricted by the window, so that a broken release can be restored at any time.
+	DeferRollback bool `json:"deferRollback,omitempty"`
+}
+
+// GitSource represents a chart stored in a git repository.
+type GitSource struct {
+	// URL of the git repository. Both HTTP(S) and SSH URLs are supported.
+	// +kubebuilder:validation:MinLength=1
+	URL string `json:"url"`
+	// Branch or tag to retrieve the chart from. Defaults to the default branch of the repository.
+	Ref string `json:"ref,omitempty"`
+	// Commit to retrieve the chart from. If set, `ref` is ignored, and the repository is not polled for changes.
+	// +kubebuilder:validation:Pattern=`^[0-9a-f]{40}$`
+	Commit string `json:"commit,omitempty"`
+	// Path to the chart directory within the repository. Defaults to the root of the repository.
+	Path string `json:"path,omitempty"`
+	// Reference to Secret holding credentials for the git repository.
+	// Secrets of type kubernetes.io/ssh-auth may contain an `ssh-privatekey` and `known_hosts`; the host key is verified against `known_hosts`, which is required unless `insecureIgnoreHostKey` is set.
+	// Secrets of type kubernetes.io/basic-auth may contain a `username` and `password`; a `token` may be used in place of the password.
+	AuthSecret *corev1.LocalObjectReference `json:"authSecret,omitempty"`
+	// Skip verification of the SSH host key, if the auth secret does not contain `known_hosts`.
+	InsecureIgnoreHostKey bool `json:"insecureIgnoreHostKey,omitempty"`
+	// Interval at which the ref is polled for new commits. Defaults to 5m; set to 0s to disable polling.
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// JobRoleReference identifies the ClusterRole or Role bound to the helm job ServiceAccount.
+type JobRoleReference struct {
+	// Kind of the role; either `ClusterRole` or `Role`.
+	// +kubebuilder:validation:Enum={"ClusterRole","Role"}
+	// +kubebuilder:default=ClusterRole
+	Kind string `json:"kind,omitempty"`
+	// Name of the role.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// DriftDetection configures periodic drift checks for the resources of a deployed release.
+type DriftDetection struct {
+	// Interval between drift checks. Defaults to the interval configured on the controller.
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// Re-apply the release when drift is detected, by replacing the completed install job.
+	// The release is only re-applied once for the same drift; if the resources still differ afterwards, they are reported as drifted without re-applying again.
+	Resync bool `json:"resync,omitempty"`
+}
+
+// HealthChecks configures verification of a release after it has been installed or upgraded.
+// If the release does not become healthy within the timeout, the chart is marked as failed. By default, the failure policy is not
+// applied to unhealthy releases: the release is left as-is until the chart configuration changes.
+type HealthChecks struct {
+	// Wait for the Deployments, StatefulSets, and DaemonSets in the release to become ready.
+	Workloads bool `json:"workloads,omitempty"`
+	// Run the tests defined by the chart, using the Job `helm-test-<name>`.
+	// Helm CLI positional argument/flag: `test`
+	HelmTest bool `json:"helmTest,omitempty"`
+	// Time to wait for the release to become healthy after the helm job completes. Defaults to 5m.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// Handle a failed health check in the same way as a failed helm job: the failure is added to `.status.history`, and the failure policy is applied.
+	// - `abort` and `retry` leave the release as-is until the chart configuration changes.
+	// - `reinstall` re-runs the helm job once for the current chart configuration. As the release is deployed rather than failed, it is upgraded in place instead of being uninstalled.
+	ApplyFailurePolicy bool `json:"applyFailurePolicy,omitempty"`
+}
+
+// ReleaseTest configures the tests run against a release after it has been installed or upgraded.
+type ReleaseTest struct {
+	// Time to wait for the tests to complete. Defaults to 5m.
+	// Helm CLI positional argument/flag: `--timeout`
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// Roll back the release to the previously deployed revision if the tests fail.
+	// The release is held at that revision until the chart configuration changes.
+	RollbackOnFailure bool `json:"rollbackOnFailure,omitempty"`
 }
 
 // HelmChartStatus represents the resulting state from processing HelmChart events
 type HelmChartStatus struct {
+	// The generation of the HelmChart most recently observed by the controller.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 	// The name of the job created to install or upgrade the chart.
 	JobName string `json:"jobName,omitempty"`
+	// The revision of the latest helm release.
+	ReleaseRevision int64 `json:"releaseRevision,omitempty"`
+	// The chart version deployed by the latest release. Only set once the release has been deployed.
+	// If no version was specified in `.spec.version`, the version recorded in the release is used.
+	ChartVersion string `json:"chartVersion,omitempty"`
+	// The chart version most recently resolved from the semver range in `.spec.version`.
+	ResolvedVersion string `json:"resolvedVersion,omitempty"`
+	// The config hash applied by the latest helm release.
+	ConfigHash string `json:"configHash,omitempty"`
+	// The revision that the release was rolled back to. Only set while the release is rolled back by `.spec.rollbackTo`,
+	// or after failing its tests.
+	RolledBackRevision int64 `json:"rolledBackRevision,omitempty"`
+	// The time at which the resources of the deployed release were last checked for drift.
+	LastDriftCheckTime *metav1.Time `json:"lastDriftCheckTime,omitempty"`
+	// The revision of the release most recently tested.
+	TestedRevision int64 `json:"testedRevision,omitempty"`
+	// The config hash of the release that failed its tests and was rolled back, when `.spec.test.rollbackOnFailure` is set.
+	// The release is held at the rolled back revision until the chart configuration no longer matches this hash.
+	TestRollbackConfigHash string `json:"testRollbackConfigHash,omitempty"`
+	// The time at which the health checks of the latest release passed or failed.
+	LastHealthCheckTime *metav1.Time `json:"lastHealthCheckTime,omitempty"`
+	// The commit resolved from `.spec.git`.
+	GitCommit string `json:"gitCommit,omitempty"`
+	// The config hash of changes that are waiting for the upgrade window to open.
+	PendingConfigHash string `json:"pendingConfigHash,omitempty"`
+	// Failures of the helm job, and failed health checks if `.spec.healthChecks.applyFailurePolicy` is set, newest first. Limited to the 10 most recent failures.
+	History []HelmChartFailure `json:"history,omitempty"`
+	// Revisions of the helm release, newest first. Limited to the 10 most recent revisions.
+	ReleaseHistory []HelmChartRelease `json:"releaseHistory,omitempty"`
+	// The chart installed by the latest helm release, as recorded in the release by helm.
+	Release *HelmChartReleaseInfo `json:"release,omitempty"`
 	// `JobCreated` indicates that a job has been created to install or upgrade the chart.
 	// `Failed` indicates that the helm job has failed and the failure policy is set to `abort`.
+	// `Waiting` indicates that the chart is waiting for one or more of the HelmCharts listed in `dependsOn` to be deployed.
+	// `DryRun` indicates that a dry run has rendered the chart, and whether or not it differs from the deployed release.
+	// `Drifted` indicates that the live resources of the deployed release differ from its manifest.
+	// `Pending` indicates that changes to the chart are waiting for the upgrade window to open.
+	// `Healthy` indicates whether the latest release passed the health checks configured in `.spec.healthChecks`.
+	// `Tested` indicates whether the latest release passed the tests defined by the chart, when `.spec.test` is set.
+	// `Suspended` indicates that installation and upgrade of the chart are suspended by `.spec.suspend`.
+	// `Ready` indicates that the latest release has been deployed with the current chart configuration, or rolled back to the requested revision.
 	// +optional
 	// +patchMergeKey=type
 	// +patchStrategy=merge
@@ -191,6 +406,9 @@ type HelmChartConfigSpec struct {
 	// Override complex Chart values via references to external Secrets.
 	// Helm CLI positional argument/flag: `--values`
 	ValuesSecrets []SecretSpec `json:"valuesSecrets,omitempty"`
+	// Override complex Chart values via references to external ConfigMaps. Applied after values from ValuesSecrets.
+	// Helm CLI positional argument/flag: `--values`
+	ValuesConfigMaps []ConfigMapSpec `json:"valuesConfigMaps,omitempty"`
 	// Configures handling of failed chart installation or upgrades.
 	// - `abort` will take no action and leave the chart in a failed state so that the administrator can manually resolve the error.
 	// - `reinstall` will perform a clean uninstall and reinstall of the chart; this is the default behavior.
@@ -206,6 +424,28 @@ type HelmChartConfigSpec struct {
 	// Set to true if helm should configure server-side apply to force changes when conflicts arise in ownership of managed fields.
 	// Helm CLI positional argument/flag: `--force-conflicts`
 	ForceConflicts *bool `json:"forceConflicts,omitempty"`
+	// Override the upgrade window of the HelmChart.
+	UpgradeWindow *UpgradeWindow `json:"upgradeWindow,omitempty"`
+	// Node selector for the helm job pod. Merged over the node selector of the HelmChart.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Override the affinity for the helm job pod.
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+	// Tolerations for the helm job pod. Added to the tolerations of the HelmChart.
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// Override the priority class for the helm job pod.
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// Override the topology spread constraints for the helm job pod.
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+	// Override the compute resources for the helm job container.
+	JobResources *corev1.ResourceRequirements `json:"jobResources,omitempty"`
+	// Select additional HelmCharts to apply this configuration to, by label. The HelmChart with the same name is always selected.
+	// Only HelmCharts in the same namespace are selected, unless the HelmChartConfig is in the controller's system namespace,
+	// in which case HelmCharts in all namespaces are selected. ValuesSecrets and ValuesConfigMaps are only applied to HelmCharts in the same namespace.
+	ChartSelector *metav1.LabelSelector `json:"chartSelector,omitempty"`
+	// Order in which this configuration is applied, when multiple HelmChartConfigs apply to a HelmChart.
+	// Configurations with a higher priority are applied later, and take precedence. At equal priority,
+	// the HelmChartConfig with the same name as the HelmChart takes precedence over those that select it by label.
+	Priority int32 `json:"priority,omitempty"`
 }
 
 type HelmChartConditionType string
@@ -213,6 +453,14 @@ type HelmChartConditionType string
 const (
 	HelmChartJobCreated HelmChartConditionType = "JobCreated"
 	HelmChartFailed     HelmChartConditionType = "Failed"
+	HelmChartWaiting    HelmChartConditionType = "Waiting"
+	HelmChartReady      HelmChartConditionType = "Ready"
+	HelmChartDryRun     HelmChartConditionType = "DryRun"
+	HelmChartDrifted    HelmChartConditionType = "Drifted"
+	HelmChartPending    HelmChartConditionType = "Pending"
+	HelmChartHealthy    HelmChartConditionType = "Healthy"
+	HelmChartTested     HelmChartConditionType = "Tested"
+	HelmChartSuspended  HelmChartConditionType = "Suspended"
 )
 
 type HelmChartCondition struct {
@@ -226,12 +474,81 @@ type HelmChartCondition struct {
 	// Human readable message indicating details about last transition.
 	// +optional
 	Message string `json:"message,omitempty"`
+	// Last time the condition transitioned from one status to another.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// HelmChartFailure records a failure of the job that installs or upgrades the chart.
+type HelmChartFailure struct {
+	// Time at which the job failed.
+	Time metav1.Time `json:"time"`
+	// Name of the job that failed.
+	JobName string `json:"jobName,omitempty"`
+	// Config hash of the job that failed.
+	ConfigHash string `json:"configHash,omitempty"`
+	// Reason from the Failed condition of the job, or `HealthCheckFailed` if the release failed its health checks.
+	Reason string `json:"reason,omitempty"`
+	// Trimmed termination message or log output of the failed helm pod.
+	Message string `json:"message,omitempty"`
+}
+
+// HelmChartRelease describes a revision of the chart's helm release.
+type HelmChartRelease struct {
+	// Revision of the release.
+	Revision int64 `json:"revision"`
+	// Status of the revision, such as deployed, superseded, or failed.
+	Status string `json:"status,omitempty"`
+	// Config hash of the chart configuration applied by the revision.
+	ConfigHash string `json:"configHash,omitempty"`
+	// Version of the chart installed by the revision.
+	ChartVersion string `json:"chartVersion,omitempty"`
+	// Description of the revision, such as "Upgrade complete" or "Rollback to 2".
+	Description string `json:"description,omitempty"`
+	// Time at which the revision was created.
+	Created metav1.Time `json:"created,omitempty"`
+	// Time at which the revision was deployed.
+	Deployed *metav1.Time `json:"deployed,omitempty"`
+}
+
+// HelmChartReleaseInfo describes the chart installed by a helm release.
+type HelmChartReleaseInfo struct {
+	// Name of the installed chart.
+	ChartName string `json:"chartName,omitempty"`
+	// Version of the installed chart.
+	ChartVersion string `json:"chartVersion,omitempty"`
+	// Version of the application deployed by the installed chart.
+	AppVersion string `json:"appVersion,omitempty"`
+	// Notes rendered by the chart. Truncated to 4KiB.
+	Notes string `json:"notes,omitempty"`
+	// Resources in the release manifest. Limited to the first 256 resources.
+	Resources []HelmChartResource `json:"resources,omitempty"`
+}
+
+// HelmChartResource identifies a resource in a helm release manifest.
+type HelmChartResource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// Namespace of the resource, if set in the manifest.
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// HelmChartReference identifies a HelmChart by namespace and name.
+type HelmChartReference struct {
+	// Namespace of the HelmChart. Defaults to the namespace of the referencing HelmChart.
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the HelmChart.
+	Name string `json:"name"`
 }
 
 // SecretSpec describes a key in a secret to load chart values from.
 type SecretSpec struct {
-	// Name of the secret. Must be in the same namespace as the HelmChart resource.
+	// Name of the secret.
 	Name string `json:"name,omitempty"`
+	// Namespace of the secret. Defaults to the namespace of the HelmChart; only HelmCharts may reference secrets in other namespaces.
+	// References to other namespaces must be permitted by a HelmChartReferenceGrant in that namespace.
+	Namespace string `json:"namespace,omitempty"`
 	// Keys to read values content from. If no keys are specified, the secret is not used.
 	Keys []string `json:"keys,omitempty"`
 	// Ignore changes to the secret, and mark the secret as optional.
@@ -239,3 +556,57 @@ type SecretSpec struct {
 	// If `ignoreUpdates` is true, the secret is optional, and changes to the secret will not trigger an upgrade of the chart.
 	IgnoreUpdates bool `json:"ignoreUpdates,omitempty"`
 }
+
+// ConfigMapSpec describes a key in a ConfigMap to load chart values from.
+type ConfigMapSpec struct {
+	// Name of the ConfigMap.
+	Name string `json:"name,omitempty"`
+	// Namespace of the ConfigMap. Defaults to the namespace of the HelmChart; only HelmCharts may reference ConfigMaps in other namespaces.
+	// References to other namespaces must be permitted by a HelmChartReferenceGrant in that namespace.
+	Namespace string `json:"namespace,omitempty"`
+	// Keys to read values content from. If no keys are specified, the ConfigMap is not used.
+	Keys []string `json:"keys,omitempty"`
+	// Ignore changes to the ConfigMap, and mark the ConfigMap as optional.
+	// By default, the ConfigMap must exist, and changes to the ConfigMap will trigger an upgrade of the chart to apply the updated values.
+	// If `ignoreUpdates` is true, the ConfigMap is optional, and changes to the ConfigMap will not trigger an upgrade of the chart.
+	IgnoreUpdates bool `json:"ignoreUpdates,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:resource:shortName=hcrg
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// HelmChartReferenceGrant permits HelmCharts in other namespaces to reference Secrets and ConfigMaps in the namespace of the grant.
+// Referenced resources are copied into the namespace of the HelmChart, and kept in sync with the original.
+type HelmChartReferenceGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec HelmChartReferenceGrantSpec `json:"spec,omitempty"`
+}
+
+// HelmChartReferenceGrantSpec identifies the namespaces that may reference resources in the namespace of the grant, and the resources that may be referenced.
+type HelmChartReferenceGrantSpec struct {
+	// Namespaces of HelmCharts that may reference resources in this namespace.
+	// +kubebuilder:validation:MinItems=1
+	From []ReferenceGrantFrom `json:"from"`
+	// Resources in this namespace that may be referenced.
+	// +kubebuilder:validation:MinItems=1
+	To []ReferenceGrantTo `json:"to"`
+}
+
+// ReferenceGrantFrom identifies a namespace of HelmCharts that may reference resources.
+type ReferenceGrantFrom struct {
+	// Namespace of the referencing HelmCharts.
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace"`
+}
+
+// ReferenceGrantTo identifies resources that may be referenced.
+type ReferenceGrantTo struct {
+	// Kind of the referenced resource.
+	// +kubebuilder:validation:Enum={"Secret","ConfigMap"}
+	Kind string `json:"kind"`
+	// Name of the referenced resource. If not set, all resources of the kind may be referenced.
+	Name string `json:"name,omitempty"`
+}
@@ -57,3 +57,20 @@ func NewHelmChartConfig(namespace, name string, obj HelmChartConfig) *HelmChartC
 	obj.Namespace = namespace
 	return &obj
 }
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// HelmChartReferenceGrantList is a list of HelmChartReferenceGrant resources
+type HelmChartReferenceGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []HelmChartReferenceGrant `json:"items"`
+}
+
+func NewHelmChartReferenceGrant(namespace, name string, obj HelmChartReferenceGrant) *HelmChartReferenceGrant {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("HelmChartReferenceGrant").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
@@ -1,6 +1,8 @@
 package app
 
 import (
+	"time"
+
 	"github.com/k3s-io/helm-controller/pkg/cmd"
 	"github.com/k3s-io/helm-controller/pkg/config"
 	"github.com/k3s-io/helm-controller/pkg/version"
@@ -10,6 +12,9 @@ import (
 var cliconfig config.CLI
 
 func New() *cli.App {
+	if isPlugin() {
+		return newPlugin()
+	}
 	return &cli.App{
 		Name:        "helm-controller",
 		Description: "A simple way to manage helm charts with CRDs in K8s.",
@@ -17,6 +22,7 @@ func New() *cli.App {
 		Action: func(app *cli.Context) error {
 			return cmd.Run(app.Context, cliconfig)
 		},
+		Commands: commands(),
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:        "controller-name",
@@ -38,7 +44,7 @@ func New() *cli.App {
 			&cli.StringFlag{
 				Name:        "job-resources",
 				Value:       `{"requests": {"cpu": "0.1", "memory": "10M"}, "limits": {"cpu": "32", "memory": "32G"}}`,
-				Usage:       "JSON spec of resource limits and requests to apply to containers for all jobs managing helm charts",
+				Usage:       "JSON spec of resource limits and requests to apply to containers for all jobs managing helm charts. May be overridden by spec.jobResources on individual HelmCharts",
 				EnvVars:     []string{"JOB_RESOURCES"},
 				Destination: &cliconfig.JobResources,
 			},
@@ -91,6 +97,75 @@ func New() *cli.App {
 				Usage:       "Port to publish HTTP server runtime profiling data in the format expected by the pprof visualization tool. Only enabled if in debug mode",
 				Destination: &cliconfig.PprofPort,
 			},
+			&cli.IntFlag{
+				Name:        "metrics-port",
+				Usage:       "Port to publish Prometheus metrics on at /metrics. Disabled if not set",
+				EnvVars:     []string{"METRICS_PORT"},
+				Destination: &cliconfig.MetricsPort,
+			},
+			&cli.DurationFlag{
+				Name:        "drift-detection-interval",
+				Value:       10 * time.Minute,
+				Usage:       "Default interval between drift checks, for HelmCharts with drift detection enabled",
+				EnvVars:     []string{"DRIFT_DETECTION_INTERVAL"},
+				Destination: &cliconfig.DriftDetectionInterval,
+			},
+			&cli.BoolFlag{
+				Name:        "cache-chart-archives",
+				Usage:       "Fetch and cache chart archives in the controller, and pass them to jobs in the chart content ConfigMap, so that jobs do not need to download charts from the repository",
+				EnvVars:     []string{"CACHE_CHART_ARCHIVES"},
+				Destination: &cliconfig.CacheChartArchives,
+			},
+			&cli.StringFlag{
+				Name:        "notification-urls",
+				Usage:       "Comma-separated list of URLs to post CloudEvents to when HelmCharts are applied, succeed, fail, are rolled back, or are uninstalled",
+				EnvVars:     []string{"NOTIFICATION_URLS"},
+				Destination: &cliconfig.NotificationURLs,
+			},
+			&cli.StringFlag{
+				Name:        "notification-secret",
+				Usage:       "Secret used to sign notifications; the HMAC-SHA256 signature of the body is sent in the X-Helm-Controller-Signature header",
+				EnvVars:     []string{"NOTIFICATION_SECRET"},
+				Destination: &cliconfig.NotificationSecret,
+			},
+			&cli.StringFlag{
+				Name:        "notification-events",
+				Usage:       "Comma-separated list of events to send notifications for: applying, succeeded, failed, rolledback, uninstalling, uninstalled. Defaults to all events",
+				EnvVars:     []string{"NOTIFICATION_EVENTS"},
+				Destination: &cliconfig.NotificationEvents,
+			},
+			&cli.StringFlag{
+				Name:        "notification-selector",
+				Usage:       "Label selector restricting notifications to matching HelmCharts",
+				EnvVars:     []string{"NOTIFICATION_SELECTOR"},
+				Destination: &cliconfig.NotificationSelector,
+			},
+			&cli.IntFlag{
+				Name:        "notification-retries",
+				Value:       3,
+				Usage:       "Number of times to retry sending a notification to an endpoint",
+				EnvVars:     []string{"NOTIFICATION_RETRIES"},
+				Destination: &cliconfig.NotificationRetries,
+			},
+			&cli.IntFlag{
+				Name:        "webhook-port",
+				Usage:       "Port to serve the validating admission webhook for HelmCharts and HelmChartConfigs on. Set to 0 to disable",
+				EnvVars:     []string{"WEBHOOK_PORT"},
+				Destination: &cliconfig.WebhookPort,
+			},
+			&cli.StringFlag{
+				Name:        "webhook-service",
+				Value:       "helm-controller-webhook",
+				Usage:       "Name of the Service that routes to the validating admission webhook port",
+				EnvVars:     []string{"WEBHOOK_SERVICE"},
+				Destination: &cliconfig.WebhookService,
+			},
+			&cli.StringFlag{
+				Name:        "webhook-namespace",
+				Usage:       "Namespace of the validating admission webhook Service and certificate Secret. Defaults to the watched namespace, or kube-system",
+				EnvVars:     []string{"WEBHOOK_NAMESPACE"},
+				Destination: &cliconfig.WebhookNamespace,
+			},
 			&cli.IntFlag{
 				Name:        "threads",
 				Value:       2,
@@ -0,0 +1,240 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/k3s-io/helm-controller/pkg/cmd"
+	"github.com/k3s-io/helm-controller/pkg/config"
+	"github.com/k3s-io/helm-controller/pkg/version"
+	"github.com/urfave/cli/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PluginName is the name of the binary when it is installed as a kubectl plugin, for use as `kubectl helmchart`.
+const PluginName = "kubectl-helmchart"
+
+var clientconfig config.Client
+
+// isPlugin returns true if the binary was run as a kubectl plugin.
+func isPlugin() bool {
+	return strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe") == PluginName
+}
+
+// newPlugin returns the app for the kubectl plugin, which has the subcommands of the controller but does not run the controller.
+func newPlugin() *cli.App {
+	return &cli.App{
+		Name:     PluginName,
+		HelpName: "kubectl helmchart",
+		Usage:    "Inspect and operate HelmCharts",
+		Version:  version.FriendlyVersion(),
+		Commands: commands(),
+	}
+}
+
+func commands() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:      "status",
+			Usage:     "Show the status of a HelmChart",
+			ArgsUsage: "NAME",
+			Flags:     clientFlags(),
+			Action: chartAction(func(c *cli.Context, client *cmd.Client, name string) error {
+				return client.Status(c.Context, name)
+			}),
+		},
+		{
+			Name:      "history",
+			Usage:     "List the revisions of a HelmChart's release",
+			ArgsUsage: "NAME",
+			Flags: append(clientFlags(),
+				&cli.BoolFlag{
+					Name:  "failures",
+					Usage: "Also list the failures of the HelmChart's jobs",
+				},
+			),
+			Action: chartAction(func(c *cli.Context, client *cmd.Client, name string) error {
+				return client.History(c.Context, name, c.Bool("failures"))
+			}),
+		},
+		{
+			Name:      "logs",
+			Usage:     "Print the log of a HelmChart's job",
+			ArgsUsage: "NAME",
+			Flags: append(clientFlags(),
+				&cli.BoolFlag{
+					Name:    "follow",
+					Aliases: []string{"f"},
+					Usage:   "Stream the log",
+				},
+				&cli.BoolFlag{
+					Name:    "previous",
+					Aliases: []string{"p"},
+					Usage:   "Print the log of the previous container, if the container has restarted",
+				},
+				&cli.Int64Flag{
+					Name:  "tail",
+					Value: -1,
+					Usage: "Number of lines from the end of the log to print. Defaults to the whole log",
+				},
+			),
+			Action: chartAction(func(c *cli.Context, client *cmd.Client, name string) error {
+				return client.Logs(c.Context, name, c.Bool("follow"), c.Bool("previous"), c.Int64("tail"))
+			}),
+		},
+		{
+			Name:      "retry",
+			Usage:     "Create a new job for a HelmChart whose job has failed with the abort failure policy",
+			ArgsUsage: "NAME",
+			Flags:     clientFlags(),
+			Action: chartAction(func(c *cli.Context, client *cmd.Client, name string) error {
+				return client.Retry(c.Context, name)
+			}),
+		},
+		{
+			Name:      "suspend",
+			Usage:     "Suspend installation and upgrade of a HelmChart",
+			ArgsUsage: "NAME",
+			Flags:     clientFlags(),
+			Action: chartAction(func(c *cli.Context, client *cmd.Client, name string) error {
+				return client.Suspend(c.Context, name, true)
+			}),
+		},
+		{
+			Name:      "resume",
+			Usage:     "Resume installation and upgrade of a suspended HelmChart",
+			ArgsUsage: "NAME",
+			Flags:     clientFlags(),
+			Action: chartAction(func(c *cli.Context, client *cmd.Client, name string) error {
+				return client.Suspend(c.Context, name, false)
+			}),
+		},
+		{
+			Name:      "render",
+			Usage:     "Print the job and values secret generated for a HelmChart",
+			ArgsUsage: "[NAME]",
+			Flags: append(clientFlags(),
+				&cli.StringFlag{
+					Name:    "filename",
+					Aliases: []string{"f"},
+					Usage:   "YAML file to read the HelmChart and HelmChartConfigs from, or - to read from stdin. Defaults to retrieving them from the cluster",
+				},
+				&cli.StringFlag{
+					Name:  "system-namespace",
+					Usage: "Namespace that the controller is restricted to, if any; HelmChartConfigs in this namespace may select HelmCharts in all namespaces",
+				},
+			),
+			Action: func(c *cli.Context) error {
+				file := c.String("filename")
+				if c.NArg() > 1 || (c.NArg() == 0 && file == "") {
+					return fmt.Errorf("%s requires a HelmChart name, unless a file is provided", c.Command.Name)
+				}
+				client := &cmd.Client{Namespace: clientconfig.Namespace, Out: c.App.Writer}
+				if client.Namespace == "" {
+					client.Namespace = metav1.NamespaceDefault
+				}
+				if file == "" {
+					var err error
+					if client, err = cmd.NewClient(clientconfig, c.App.Writer); err != nil {
+						return err
+					}
+				}
+				return client.Render(c.Context, c.Args().First(), file, c.String("system-namespace"))
+			},
+		},
+	}
+}
+
+// clientFlags returns the flags that select the cluster and namespace of the HelmChart.
+func clientFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "kubeconfig",
+			Usage:       "Kubernetes config files, e.g. $HOME/.kube/config",
+			EnvVars:     []string{"KUBECONFIG"},
+			Destination: &clientconfig.Kubeconfig,
+		},
+		&cli.StringFlag{
+			Name:        "context",
+			Usage:       "Kubernetes config context to use. Defaults to the current context",
+			Destination: &clientconfig.Context,
+		},
+		&cli.StringFlag{
+			Name:        "namespace",
+			Aliases:     []string{"n"},
+			Usage:       "Namespace of the HelmChart. Defaults to the namespace of the Kubernetes config context",
+			Destination: &clientconfig.Namespace,
+		},
+	}
+}
+
+// chartAction returns an action that calls fn with a client for the cluster, and the HelmChart name from the arguments.
+func chartAction(fn func(c *cli.Context, client *cmd.Client, name string) error) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return fmt.Errorf("%s requires exactly one HelmChart name", c.Command.Name)
+		}
+		client, err := cmd.NewClient(clientconfig, c.App.Writer)
+		if err != nil {
+			return err
+		}
+		return fn(c, client, c.Args().First())
+	}
+}
+
+// ReorderArgs moves the flags of a subcommand ahead of its arguments, so that flags may follow the HelmChart
+// name, as is usual for kubectl commands. Arguments following "--" are not moved.
+func ReorderArgs(a *cli.App, args []string) []string {
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if strings.HasPrefix(arg, "-") {
+			if arg == "--" {
+				return args
+			}
+			if takesValue(a.Flags, arg) {
+				i++
+			}
+			continue
+		}
+		command := a.Command(arg)
+		if command == nil {
+			return args
+		}
+		var flags, rest []string
+		for j := i + 1; j < len(args); j++ {
+			switch arg := args[j]; {
+			case arg == "--":
+				rest = append(rest, args[j:]...)
+				j = len(args)
+			case strings.HasPrefix(arg, "-") && arg != "-":
+				flags = append(flags, arg)
+				if takesValue(command.Flags, arg) && j+1 < len(args) {
+					j++
+					flags = append(flags, args[j])
+				}
+			default:
+				rest = append(rest, arg)
+			}
+		}
+		return slices.Concat(args[:i+1], flags, rest)
+	}
+	return args
+}
+
+// takesValue returns true if the argument is a flag that takes a value, which is not provided in the same argument.
+func takesValue(flags []cli.Flag, arg string) bool {
+	if strings.Contains(arg, "=") {
+		return false
+	}
+	name := strings.TrimLeft(arg, "-")
+	for _, flag := range flags {
+		if slices.Contains(flag.Names(), name) {
+			f, ok := flag.(cli.DocGenerationFlag)
+			return ok && f.TakesValue()
+		}
+	}
+	return false
+}
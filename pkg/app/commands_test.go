@@ -0,0 +1,29 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReorderArgs(t *testing.T) {
+	a := New()
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"no command", []string{"helm-controller", "--namespace", "status"}, []string{"helm-controller", "--namespace", "status"}},
+		{"flags before name", []string{"helm-controller", "status", "-n", "kube-system", "traefik"}, []string{"helm-controller", "status", "-n", "kube-system", "traefik"}},
+		{"flags after name", []string{"helm-controller", "status", "traefik", "-n", "kube-system"}, []string{"helm-controller", "status", "-n", "kube-system", "traefik"}},
+		{"boolean flags", []string{"helm-controller", "logs", "traefik", "-f", "--tail", "10"}, []string{"helm-controller", "logs", "-f", "--tail", "10", "traefik"}},
+		{"flag values", []string{"helm-controller", "render", "traefik", "--filename=-", "-n", "default"}, []string{"helm-controller", "render", "--filename=-", "-n", "default", "traefik"}},
+		{"root flags", []string{"helm-controller", "--debug-level", "2", "history", "traefik", "--failures"}, []string{"helm-controller", "--debug-level", "2", "history", "--failures", "traefik"}},
+		{"terminator", []string{"helm-controller", "status", "traefik", "--", "-n"}, []string{"helm-controller", "status", "traefik", "--", "-n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ReorderArgs(a, tt.args))
+		})
+	}
+}
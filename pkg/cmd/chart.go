@@ -0,0 +1,355 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/k3s-io/helm-controller/pkg/config"
+	"github.com/k3s-io/helm-controller/pkg/controllers/chart"
+	"github.com/k3s-io/helm-controller/pkg/generated/clientset/versioned"
+	"github.com/rancher/wrangler/v3/pkg/kubeconfig"
+	"github.com/rancher/wrangler/v3/pkg/yaml"
+	batch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/utils/ptr"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// Client inspects and operates HelmCharts in a cluster, for the subcommands of the CLI and kubectl plugin.
+type Client struct {
+	Namespace string
+	Helm      versioned.Interface
+	K8s       kubernetes.Interface
+	Out       io.Writer
+}
+
+// NewClient returns a Client for the cluster and namespace selected by the options. If no namespace is
+// provided, the namespace of the current kubeconfig context is used.
+func NewClient(opts config.Client, out io.Writer) (*Client, error) {
+	cfg := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		kubeconfig.GetLoadingRules(opts.Kubeconfig),
+		&clientcmd.ConfigOverrides{
+			ClusterDefaults: clientcmd.ClusterDefaults,
+			CurrentContext:  opts.Context,
+		})
+	rest, err := cfg.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	namespace := opts.Namespace
+	if namespace == "" {
+		if namespace, _, err = cfg.Namespace(); err != nil {
+			return nil, err
+		}
+	}
+	helm, err := versioned.NewForConfig(rest)
+	if err != nil {
+		return nil, err
+	}
+	k8s, err := kubernetes.NewForConfig(rest)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Namespace: namespace, Helm: helm, K8s: k8s, Out: out}, nil
+}
+
+// Status prints the spec, installed release, and conditions of the HelmChart.
+func (c *Client) Status(ctx context.Context, name string) error {
+	hc, err := c.Helm.HelmV1().HelmCharts(c.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.Out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", hc.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", hc.Namespace)
+	fmt.Fprintf(w, "Target Namespace:\t%s\n", orNone(hc.Spec.TargetNamespace))
+	fmt.Fprintf(w, "Chart:\t%s\n", orNone(hc.Spec.Chart))
+	if hc.Spec.Repo != "" {
+		fmt.Fprintf(w, "Repo:\t%s\n", hc.Spec.Repo)
+	}
+	fmt.Fprintf(w, "Version:\t%s\n", orNone(hc.Spec.Version))
+	if rel := hc.Status.Release; rel != nil {
+		fmt.Fprintf(w, "Installed Chart:\t%s-%s\n", rel.ChartName, rel.ChartVersion)
+		fmt.Fprintf(w, "App Version:\t%s\n", orNone(rel.AppVersion))
+	}
+	fmt.Fprintf(w, "Revision:\t%d\n", hc.Status.ReleaseRevision)
+	fmt.Fprintf(w, "Job:\t%s\n", orNone(hc.Status.JobName))
+	fmt.Fprintf(w, "Suspended:\t%t\n", hc.Spec.Suspend)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(hc.Status.Conditions) > 0 {
+		fmt.Fprintln(c.Out, "\nConditions:")
+		w = tabwriter.NewWriter(c.Out, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "  TYPE\tSTATUS\tREASON\tMESSAGE")
+		for _, condition := range hc.Status.Conditions {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", condition.Type, condition.Status, condition.Reason, firstLine(condition.Message))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	if len(hc.Status.History) > 0 {
+		failure := hc.Status.History[0]
+		fmt.Fprintf(c.Out, "\nLast Failure: Job %s at %s\n%s\n", failure.JobName, failure.Time.Format(time.RFC3339), indent(failure.Message))
+	}
+	if rel := hc.Status.Release; rel != nil && rel.Notes != "" {
+		fmt.Fprintf(c.Out, "\nNotes:\n%s\n", indent(rel.Notes))
+	}
+	return nil
+}
+
+// History prints the revisions of the HelmChart's release, and optionally the failures of its jobs.
+func (c *Client) History(ctx context.Context, name string, failures bool) error {
+	hc, err := c.Helm.HelmV1().HelmCharts(c.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.Out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tUPDATED\tSTATUS\tCHART VERSION\tCONFIG HASH\tDESCRIPTION")
+	for _, rel := range hc.Status.ReleaseHistory {
+		updated := rel.Created
+		if rel.Deployed != nil {
+			updated = *rel.Deployed
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", rel.Revision, updated.Format(time.ANSIC), rel.Status, rel.ChartVersion, rel.ConfigHash, rel.Description)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failures && len(hc.Status.History) > 0 {
+		fmt.Fprintln(c.Out)
+		w = tabwriter.NewWriter(c.Out, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "FAILED\tJOB\tCONFIG HASH\tREASON\tMESSAGE")
+		for _, failure := range hc.Status.History {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", failure.Time.Format(time.ANSIC), failure.JobName, failure.ConfigHash, failure.Reason, lastLine(failure.Message))
+		}
+		return w.Flush()
+	}
+	return nil
+}
+
+// Logs prints the helm container log of the most recent pod of the HelmChart's job.
+func (c *Client) Logs(ctx context.Context, name string, follow, previous bool, tail int64) error {
+	job, err := c.job(ctx, name)
+	if err != nil {
+		return err
+	}
+	ls := labels.Set{"job-name": job.Name}.AsSelector()
+	podList, err := c.K8s.CoreV1().Pods(job.Namespace).List(ctx, metav1.ListOptions{LabelSelector: ls.String()})
+	if err != nil {
+		return err
+	}
+	var pod *corev1.Pod
+	for i := range podList.Items {
+		if p := &podList.Items[i]; pod == nil || pod.CreationTimestamp.Before(&p.CreationTimestamp) {
+			pod = p
+		}
+	}
+	if pod == nil {
+		return fmt.Errorf("no pods found for job %s/%s", job.Namespace, job.Name)
+	}
+
+	opts := &corev1.PodLogOptions{Container: "helm", Follow: follow, Previous: previous}
+	if tail >= 0 {
+		opts.TailLines = ptr.To(tail)
+	}
+	logs, err := c.K8s.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+	_, err = io.Copy(c.Out, logs)
+	return err
+}
+
+// Retry deletes the failed job of a HelmChart that has failed with the abort failure policy, so that the
+// controller creates a new job for the chart.
+func (c *Client) Retry(ctx context.Context, name string) error {
+	job, err := c.job(ctx, name)
+	if err != nil {
+		return err
+	}
+	failed := false
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batch.JobFailed && condition.Status == corev1.ConditionTrue {
+			failed = true
+		}
+	}
+	if !failed {
+		return fmt.Errorf("job %s/%s for HelmChart %s/%s has not failed", job.Namespace, job.Name, c.Namespace, name)
+	}
+	err = c.K8s.BatchV1().Jobs(job.Namespace).Delete(ctx, job.Name, metav1.DeleteOptions{
+		PropagationPolicy: ptr.To(metav1.DeletePropagationBackground),
+		Preconditions:     &metav1.Preconditions{UID: &job.UID},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Out, "Deleted failed job %s/%s; a new job will be created for HelmChart %s/%s\n", job.Namespace, job.Name, c.Namespace, name)
+	return nil
+}
+
+// Suspend suspends or resumes installation and upgrade of the HelmChart, by setting `.spec.suspend`.
+func (c *Client) Suspend(ctx context.Context, name string, suspend bool) error {
+	patch := fmt.Appendf(nil, `{"spec":{"suspend":%t}}`, suspend)
+	if _, err := c.Helm.HelmV1().HelmCharts(c.Namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return err
+	}
+	action := "Suspended"
+	if !suspend {
+		action = "Resumed"
+	}
+	fmt.Fprintf(c.Out, "%s HelmChart %s/%s\n", action, c.Namespace, name)
+	return nil
+}
+
+// Render prints the job and values secret generated for the HelmChart. If a file is provided, the HelmChart
+// and any HelmChartConfigs are read from the file; otherwise, they are retrieved from the cluster.
+func (c *Client) Render(ctx context.Context, name, file, systemNamespace string) error {
+	var hc *v1.HelmChart
+	var confs []*v1.HelmChartConfig
+	if file != "" {
+		var err error
+		if hc, confs, err = readCharts(file, name, c.Namespace); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if hc, err = c.Helm.HelmV1().HelmCharts(c.Namespace).Get(ctx, name, metav1.GetOptions{}); err != nil {
+			return err
+		}
+		confList, err := c.Helm.HelmV1().HelmChartConfigs(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		for i := range confList.Items {
+			confs = append(confs, &confList.Items[i])
+		}
+	}
+
+	job, valuesSecret := chart.Render(hc, confs, systemNamespace)
+	for i, obj := range []runtime.Object{job, valuesSecret} {
+		b, err := sigsyaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprintln(c.Out, "---")
+		}
+		if _, err := c.Out.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// job returns the job most recently created for the HelmChart.
+func (c *Client) job(ctx context.Context, name string) (*batch.Job, error) {
+	hc, err := c.Helm.HelmV1().HelmCharts(c.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if hc.Status.JobName == "" {
+		return nil, fmt.Errorf("no job has been created for HelmChart %s/%s", hc.Namespace, hc.Name)
+	}
+	return c.K8s.BatchV1().Jobs(hc.Namespace).Get(ctx, hc.Status.JobName, metav1.GetOptions{})
+}
+
+// readCharts reads the HelmChart with the given name, and all HelmChartConfigs, from a YAML file. The first
+// HelmChart is used if no name is provided, and objects without a namespace are placed in the provided
+// namespace. The file is read from stdin if the file name is "-".
+func readCharts(file, name, namespace string) (*v1.HelmChart, []*v1.HelmChartConfig, error) {
+	var b []byte
+	var err error
+	if file == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	objs, err := yaml.ToObjects(bytes.NewReader(b))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var hc *v1.HelmChart
+	var confs []*v1.HelmChartConfig
+	for _, obj := range objs {
+		u, ok := obj.(runtime.Unstructured)
+		if !ok || obj.GetObjectKind().GroupVersionKind().GroupVersion() != v1.SchemeGroupVersion {
+			continue
+		}
+		switch obj.GetObjectKind().GroupVersionKind().Kind {
+		case "HelmChart":
+			c := &v1.HelmChart{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), c); err != nil {
+				return nil, nil, err
+			}
+			if hc == nil && (name == "" || c.Name == name) {
+				hc = c
+			}
+		case "HelmChartConfig":
+			c := &v1.HelmChartConfig{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), c); err != nil {
+				return nil, nil, err
+			}
+			confs = append(confs, c)
+		}
+	}
+	if hc == nil {
+		if name == "" {
+			return nil, nil, fmt.Errorf("no HelmChart found in %s", file)
+		}
+		return nil, nil, fmt.Errorf("HelmChart %s not found in %s", name, file)
+	}
+	if hc.Namespace == "" {
+		hc.Namespace = namespace
+	}
+	for _, conf := range confs {
+		if conf.Namespace == "" {
+			conf.Namespace = namespace
+		}
+	}
+	return hc, confs, nil
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
+
+func indent(s string) string {
+	return "  " + strings.ReplaceAll(strings.TrimSpace(s), "\n", "\n  ")
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	return s[strings.LastIndex(s, "\n")+1:]
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/k3s-io/helm-controller/pkg/generated/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	batch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newChart() *v1.HelmChart {
+	return v1.NewHelmChart("kube-system", "traefik", v1.HelmChart{
+		Spec: v1.HelmChartSpec{
+			Chart:   "traefik",
+			Repo:    "https://traefik.github.io/charts",
+			Version: "27.0.0",
+		},
+		Status: v1.HelmChartStatus{
+			JobName:         "helm-install-traefik",
+			ReleaseRevision: 2,
+			ReleaseHistory: []v1.HelmChartRelease{
+				{Revision: 2, Status: "deployed", ChartVersion: "27.0.0", ConfigHash: "SHA256=B", Description: "Upgrade complete", Created: metav1.NewTime(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))},
+				{Revision: 1, Status: "superseded", ChartVersion: "26.0.0", ConfigHash: "SHA256=A", Description: "Install complete", Created: metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))},
+			},
+			History: []v1.HelmChartFailure{
+				{JobName: "helm-install-traefik", ConfigHash: "SHA256=C", Reason: "BackoffLimitExceeded", Message: "Error: UPGRADE FAILED: timed out"},
+			},
+			Release: &v1.HelmChartReleaseInfo{ChartName: "traefik", ChartVersion: "27.0.0", AppVersion: "v2.11.0", Notes: "Traefik has been installed."},
+			Conditions: []v1.HelmChartCondition{
+				{Type: v1.HelmChartFailed, Status: corev1.ConditionTrue, Reason: "Job failed", Message: "Job has reached configured number of retries without succeeding"},
+			},
+		},
+	})
+}
+
+func TestStatus(t *testing.T) {
+	assert := assert.New(t)
+	out := &bytes.Buffer{}
+	c := &Client{Namespace: "kube-system", Helm: fake.NewSimpleClientset(newChart()), Out: out}
+
+	assert.NoError(c.Status(context.Background(), "traefik"))
+	assert.Contains(out.String(), "Installed Chart:   traefik-27.0.0\n")
+	assert.Contains(out.String(), "App Version:       v2.11.0\n")
+	assert.Contains(out.String(), "  Failed  True    Job failed")
+	assert.Contains(out.String(), "Last Failure: Job helm-install-traefik")
+	assert.Contains(out.String(), "Notes:\n  Traefik has been installed.\n")
+
+	assert.True(apierrors.IsNotFound(c.Status(context.Background(), "missing")))
+}
+
+func TestHistory(t *testing.T) {
+	assert := assert.New(t)
+	out := &bytes.Buffer{}
+	c := &Client{Namespace: "kube-system", Helm: fake.NewSimpleClientset(newChart()), Out: out}
+
+	assert.NoError(c.History(context.Background(), "traefik", false))
+	assert.Equal(""+
+		"REVISION  UPDATED                   STATUS      CHART VERSION  CONFIG HASH  DESCRIPTION\n"+
+		"2         Tue Jan  2 00:00:00 2024  deployed    27.0.0         SHA256=B     Upgrade complete\n"+
+		"1         Mon Jan  1 00:00:00 2024  superseded  26.0.0         SHA256=A     Install complete\n", out.String())
+
+	out.Reset()
+	assert.NoError(c.History(context.Background(), "traefik", true))
+	assert.Contains(out.String(), "BackoffLimitExceeded  Error: UPGRADE FAILED: timed out\n")
+}
+
+func TestRetry(t *testing.T) {
+	assert := assert.New(t)
+	job := &batch.Job{ObjectMeta: metav1.ObjectMeta{Name: "helm-install-traefik", Namespace: "kube-system"}}
+	k8s := k8sfake.NewClientset(job)
+	c := &Client{Namespace: "kube-system", Helm: fake.NewSimpleClientset(newChart()), K8s: k8s, Out: &bytes.Buffer{}}
+
+	// jobs that have not failed are not deleted
+	assert.ErrorContains(c.Retry(context.Background(), "traefik"), "has not failed")
+
+	job.Status.Conditions = []batch.JobCondition{{Type: batch.JobFailed, Status: corev1.ConditionTrue}}
+	_, err := k8s.BatchV1().Jobs(job.Namespace).UpdateStatus(context.Background(), job, metav1.UpdateOptions{})
+	assert.NoError(err)
+	assert.NoError(c.Retry(context.Background(), "traefik"))
+	_, err = k8s.BatchV1().Jobs(job.Namespace).Get(context.Background(), job.Name, metav1.GetOptions{})
+	assert.True(apierrors.IsNotFound(err))
+}
+
+func TestSuspend(t *testing.T) {
+	assert := assert.New(t)
+	helm := fake.NewSimpleClientset(newChart())
+	c := &Client{Namespace: "kube-system", Helm: helm, Out: &bytes.Buffer{}}
+
+	for _, suspend := range []bool{true, false} {
+		assert.NoError(c.Suspend(context.Background(), "traefik", suspend))
+		hc, err := helm.HelmV1().HelmCharts("kube-system").Get(context.Background(), "traefik", metav1.GetOptions{})
+		assert.NoError(err)
+		assert.Equal(suspend, hc.Spec.Suspend)
+	}
+}
+
+func TestRender(t *testing.T) {
+	assert := assert.New(t)
+	file := filepath.Join(t.TempDir(), "traefik.yaml")
+	assert.NoError(os.WriteFile(file, []byte(`apiVersion: helm.cattle.io/v1
+kind: HelmChart
+metadata:
+  name: traefik
+spec:
+  chart: traefik
+  valuesContent: "foo: bar"
+---
+apiVersion: helm.cattle.io/v1
+kind: HelmChartConfig
+metadata:
+  name: traefik
+spec:
+  failurePolicy: abort
+  valuesContent: "foo: baz"
+`), 0600))
+
+	out := &bytes.Buffer{}
+	c := &Client{Namespace: "kube-system", Out: out}
+	assert.NoError(c.Render(context.Background(), "", file, ""))
+	assert.Contains(out.String(), "kind: Job\n")
+	assert.Contains(out.String(), "name: helm-install-traefik\n  namespace: kube-system\n")
+	assert.Contains(out.String(), "- name: FAILURE_POLICY\n          value: abort\n")
+	assert.Contains(out.String(), "\n---\n")
+	assert.Contains(out.String(), "kind: Secret\n")
+
+	assert.ErrorContains(c.Render(context.Background(), "missing", file, ""), "HelmChart missing not found")
+}
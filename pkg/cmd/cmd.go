@@ -12,10 +12,17 @@ import (
 	"github.com/k3s-io/helm-controller/pkg/controllers"
 	"github.com/k3s-io/helm-controller/pkg/controllers/common"
 	"github.com/k3s-io/helm-controller/pkg/crds"
+	"github.com/k3s-io/helm-controller/pkg/metrics"
+	"github.com/k3s-io/helm-controller/pkg/webhook"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rancher/wrangler/v3/pkg/crd"
 	"github.com/rancher/wrangler/v3/pkg/kubeconfig"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	restclient "k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"k8s.io/klog/v2"
@@ -53,6 +60,16 @@ func Run(ctx context.Context, hc config.CLI) error {
 	if err != nil {
 		return err
 	}
+	if hc.MetricsPort > 0 {
+		metrics.MustRegister(prometheus.DefaultRegisterer)
+		go func() {
+			// Serves Prometheus metrics for HelmChart reconciles, job outcomes, and release state
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", promhttp.Handler())
+			err := http.ListenAndServe(fmt.Sprintf(":%d", hc.MetricsPort), mux)
+			logger.Error(err, "Metrics listener stopped", "port", hc.MetricsPort)
+		}()
+	}
 	ctx = klog.NewContext(ctx, logger)
 
 	cfg := getNonInteractiveClientConfig(hc)
@@ -79,6 +96,12 @@ func Run(ctx context.Context, hc config.CLI) error {
 		return err
 	}
 
+	if hc.WebhookPort > 0 {
+		if err := startWebhook(ctx, hc, rest); err != nil {
+			return err
+		}
+	}
+
 	if err := controllers.Register(ctx, hc.Namespace, hc.ControllerName, cfg, opts); err != nil {
 		return err
 	}
@@ -87,6 +110,32 @@ func Run(ctx context.Context, hc config.CLI) error {
 	return ctx.Err()
 }
 
+// startWebhook starts the validating admission webhook server, with a serving certificate managed by the webhook.
+func startWebhook(ctx context.Context, hc config.CLI, rest *restclient.Config) error {
+	k8s, err := kubernetes.NewForConfig(rest)
+	if err != nil {
+		return err
+	}
+	name := hc.ControllerName
+	if name == "" {
+		name = "helm-controller"
+	}
+	namespace := hc.WebhookNamespace
+	if namespace == "" {
+		namespace = hc.Namespace
+	}
+	if namespace == "" {
+		namespace = metav1.NamespaceSystem
+	}
+	return webhook.Start(ctx, k8s, webhook.Options{
+		Name:           name,
+		Namespace:      namespace,
+		Service:        hc.WebhookService,
+		Port:           hc.WebhookPort,
+		WatchNamespace: hc.Namespace,
+	})
+}
+
 func getNonInteractiveClientConfig(hc config.CLI) clientcmd.ClientConfig {
 	// Modified https://github.com/rancher/wrangler/blob/3ecd23dfea3bb4c76cbe8e06fb158eed6ae3dd31/pkg/kubeconfig/loader.go#L12-L32
 	return clientcmd.NewInteractiveDeferredLoadingClientConfig(
@@ -19,6 +19,7 @@ func main() {
 				Types: []any{
 					v1.HelmChart{},
 					v1.HelmChartConfig{},
+					v1.HelmChartReferenceGrant{},
 				},
 				GenerateTypes:   true,
 				GenerateClients: true,
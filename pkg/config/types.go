@@ -2,8 +2,12 @@ package config
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/k3s-io/helm-controller/pkg/notify"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 type CLI struct {
@@ -20,6 +24,27 @@ type CLI struct {
 	JobTolerations  string
 	JobResources    string
 	PprofPort       int
+	MetricsPort     int
+
+	DriftDetectionInterval time.Duration
+	CacheChartArchives     bool
+
+	NotificationURLs     string
+	NotificationSecret   string
+	NotificationEvents   string
+	NotificationSelector string
+	NotificationRetries  int
+
+	WebhookPort      int
+	WebhookService   string
+	WebhookNamespace string
+}
+
+// Client holds the options for the subcommands that inspect and operate HelmCharts in a cluster.
+type Client struct {
+	Kubeconfig string
+	Context    string
+	Namespace  string
 }
 
 func (c CLI) GetControllerConfig() (*Controller, error) {
@@ -32,6 +57,11 @@ func (c CLI) GetControllerConfig() (*Controller, error) {
 		return nil, fmt.Errorf("invalid --job-tolerations JSON: %w", err)
 	}
 
+	notifications, err := c.parseNotifications()
+	if err != nil {
+		return nil, err
+	}
+
 	if c.Threads <= 0 {
 		return nil, fmt.Errorf("cannot start with thread count of %d, please pass a proper thread count", c.Threads)
 	}
@@ -43,9 +73,45 @@ func (c CLI) GetControllerConfig() (*Controller, error) {
 		DefaultJobImage: c.DefaultJobImage,
 		JobTolerations:  tolerations,
 		JobResources:    resources,
+
+		DriftDetectionInterval: c.DriftDetectionInterval,
+		CacheChartArchives:     c.CacheChartArchives,
+		Notifications:          notifications,
 	}, nil
 }
 
+// parseNotifications parses the notification endpoints, event filter, and chart label selector.
+func (c CLI) parseNotifications() (notify.Options, error) {
+	opts := notify.Options{
+		URLs:    splitList(c.NotificationURLs),
+		Secret:  c.NotificationSecret,
+		Events:  splitList(c.NotificationEvents),
+		Retries: c.NotificationRetries,
+	}
+	if err := opts.Validate(); err != nil {
+		return opts, fmt.Errorf("invalid --notification-events: %w", err)
+	}
+	if c.NotificationSelector != "" {
+		selector, err := labels.Parse(c.NotificationSelector)
+		if err != nil {
+			return opts, fmt.Errorf("invalid --notification-selector: %w", err)
+		}
+		opts.Selector = selector
+	}
+	return opts, nil
+}
+
+// splitList splits a comma-separated list, ignoring empty items.
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 type Controller struct {
 	Threadiness     int
 	NodeName        string
@@ -53,4 +119,8 @@ type Controller struct {
 	DefaultJobImage string
 	JobTolerations  []corev1.Toleration
 	JobResources    *corev1.ResourceRequirements
+
+	DriftDetectionInterval time.Duration
+	CacheChartArchives     bool
+	Notifications          notify.Options
 }
@@ -28,16 +28,21 @@ func parseResources(raw string) (*corev1.ResourceRequirements, error) {
 	if err := json.Unmarshal([]byte(raw), resources); err != nil {
 		return nil, err
 	}
-	coreResources := &typedcore.ResourceRequirements{}
-	if err := scheme.Convert(resources, coreResources, nil); err != nil {
-		return nil, err
-	}
-	if err := validation.ValidateContainerResourceRequirements(coreResources, nil, field.NewPath("pod-resources"), opts).ToAggregate(); err != nil {
+	if err := ValidateResources(resources, field.NewPath("pod-resources")).ToAggregate(); err != nil {
 		return nil, err
 	}
 	return resources, nil
 }
 
+// ValidateResources validates resource requirements for the helm job container, using the same validation as the apiserver applies to pods.
+func ValidateResources(resources *corev1.ResourceRequirements, fldPath *field.Path) field.ErrorList {
+	coreResources := &typedcore.ResourceRequirements{}
+	if err := scheme.Convert(resources, coreResources, nil); err != nil {
+		return field.ErrorList{field.InternalError(fldPath, err)}
+	}
+	return validation.ValidateContainerResourceRequirements(coreResources, nil, fldPath, opts)
+}
+
 // parseTolerations takes the CLI string and parses it into a slice of corev1.Toleration objects
 func parseTolerations(raw string) ([]corev1.Toleration, error) {
 	raw = strings.TrimSpace(raw)
@@ -0,0 +1,343 @@
+package chart
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+)
+
+const (
+	// maxChartContentBytes limits the size of chart archives passed to the job, so that they fit within the chart content ConfigMap.
+	maxChartContentBytes = 768 * 1024
+	// maxCachedArchives limits the number of chart archives held in the controller's archive cache.
+	maxCachedArchives = 64
+	// archiveRetryInterval is the time for which a failure to fetch a chart archive is cached, before the
+	// archive is fetched again.
+	archiveRetryInterval = 5 * time.Minute
+	// ociManifestMediaType is the media type of the OCI image manifest for a chart pushed by Helm.
+	ociManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	// ociChartLayerMediaType is the media type of the manifest layer that holds the chart archive.
+	ociChartLayerMediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+)
+
+// errArchiveTooLarge is returned when a chart archive is too large to be passed to the job in the chart content ConfigMap.
+var errArchiveTooLarge = fmt.Errorf("chart archive exceeds the maximum of %d bytes", maxChartContentBytes)
+
+// CacheChartArchives enables fetching of chart archives by the controller. Cached archives are passed to
+// the job in the chart content ConfigMap, so that the job does not need to download the chart.
+var CacheChartArchives = false
+
+// archiveCache holds base64-encoded chart archives, keyed by source and version. The least recently used
+// archive is evicted once the cache is full. Failures to fetch an archive are also held, so that the
+// archive is not fetched again until the retry interval has passed.
+type archiveCache struct {
+	mu       sync.Mutex
+	keys     []string
+	content  map[string]string
+	failures map[string]archiveFailure
+}
+
+// archiveFailure records a failure to fetch a chart archive.
+type archiveFailure struct {
+	err  error
+	time time.Time
+}
+
+// get returns the cached archive for the key, and marks it as most recently used.
+func (a *archiveCache) get(key string) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	content, ok := a.content[key]
+	if ok {
+		a.touch(key)
+	}
+	return content, ok
+}
+
+// add adds the archive to the cache, evicting the least recently used archive if the cache is full.
+func (a *archiveCache) add(key, content string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.content == nil {
+		a.content = map[string]string{}
+	}
+	if _, ok := a.content[key]; !ok && len(a.keys) >= maxCachedArchives {
+		delete(a.content, a.keys[0])
+		a.keys = a.keys[1:]
+	}
+	a.content[key] = content
+	a.touch(key)
+}
+
+// fail records a failure to fetch the archive for the key.
+func (a *archiveCache) fail(key string, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.failures == nil {
+		a.failures = map[string]archiveFailure{}
+	}
+	for k, failure := range a.failures {
+		if time.Since(failure.time) >= archiveRetryInterval {
+			delete(a.failures, k)
+		}
+	}
+	a.failures[key] = archiveFailure{err: err, time: time.Now()}
+}
+
+// failure returns the error from the most recent failure to fetch the archive for the key, if the
+// archive should not yet be fetched again.
+func (a *archiveCache) failure(key string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if failure, ok := a.failures[key]; ok && time.Since(failure.time) < archiveRetryInterval {
+		return failure.err
+	}
+	return nil
+}
+
+// touch moves the key to the end of the list of keys. The lock must be held by the caller.
+func (a *archiveCache) touch(key string) {
+	for i, k := range a.keys {
+		if k == key {
+			a.keys = append(a.keys[:i], a.keys[i+1:]...)
+			break
+		}
+	}
+	a.keys = append(a.keys, key)
+}
+
+// archiveKey returns the key identifying the chart archive that will be installed for the chart, or an
+// empty string if the archive cannot be cached. Only charts from a repository or OCI registry with a
+// specific version, or a direct archive URL, are cached.
+func archiveKey(chart *v1.HelmChart) string {
+	switch {
+	case chart.Spec.Git != nil || chart.Spec.ChartContent != "" || chart.Spec.Chart == "":
+		return ""
+	case strings.HasPrefix(chart.Spec.Chart, "oci://"):
+		if chart.Spec.Version == "" {
+			return ""
+		}
+		return chart.Spec.Chart + ":" + chart.Spec.Version
+	case strings.HasPrefix(chart.Spec.Chart, "http://") || strings.HasPrefix(chart.Spec.Chart, "https://"):
+		return chart.Spec.Chart
+	case chart.Spec.Repo == "" || chart.Spec.Version == "" || strings.Contains(chart.Spec.Chart, "://"):
+		return ""
+	default:
+		return strings.TrimSuffix(chart.Spec.Repo, "/") + "/" + chart.Spec.Chart + ":" + chart.Spec.Version
+	}
+}
+
+// resolveChartArchive returns a copy of the chart with the chart content set to the cached chart
+// archive, along with the archive key. If archive caching is disabled or the archive cannot be cached,
+// the chart is returned unmodified, and the job downloads the chart as usual. Archives are also
+// recovered from the chart content ConfigMap, so that a restart of the controller does not require
+// them to be fetched again. If the archive cannot be fetched, an error is returned, rather than
+// switching the job to download the chart; the failure is cached, so that the archive is only
+// fetched again once the retry interval has passed.
+func (c *Controller) resolveChartArchive(chart *v1.HelmChart) (*v1.HelmChart, string, error) {
+	key := archiveKey(chart)
+	if !CacheChartArchives || key == "" {
+		return chart, "", nil
+	}
+
+	content, ok := c.archives.get(key)
+	if !ok {
+		content, ok = c.existingArchive(chart, key)
+	}
+	if !ok {
+		err := c.archives.failure(key)
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), repoTimeout)
+			var b []byte
+			b, err = c.fetchChartArchive(ctx, chart)
+			cancel()
+			if err != nil {
+				c.archives.fail(key, err)
+			} else {
+				content = base64.StdEncoding.EncodeToString(b)
+				c.logger.V(1).Info("Cached chart archive",
+					"chart.name", chart.Namespace+"/"+chart.Name,
+					"chart.archive", key,
+					"chart.size", len(b),
+				)
+			}
+		}
+		// archives that are too large are never passed to the job, so the job downloads the chart instead
+		if errors.Is(err, errArchiveTooLarge) {
+			return chart, "", nil
+		} else if err != nil {
+			return nil, "", fmt.Errorf("failed to fetch chart archive %s: %w", key, err)
+		}
+	}
+	c.archives.add(key, content)
+
+	// the repository is cleared so that the job does not add it, as the chart content is installed instead
+	chart = chart.DeepCopy()
+	chart.Spec.ChartContent = content
+	chart.Spec.Repo = ""
+	return chart, key, nil
+}
+
+// existingArchive returns the chart archive from the chart content ConfigMap, if it holds the archive for the key.
+func (c *Controller) existingArchive(chart *v1.HelmChart, key string) (string, bool) {
+	cm, err := c.configMapCache.Get(chart.Namespace, fmt.Sprintf("chart-content-%s", chart.Name))
+	if err != nil || cm.Annotations[KeyChartArchive] != key {
+		return "", false
+	}
+	content, ok := cm.Data[fmt.Sprintf("%s.tgz.base64", chart.Name)]
+	return content, ok && content != ""
+}
+
+// fetchChartArchive downloads the chart archive from the chart's repository, OCI registry, or archive URL.
+func (c *Controller) fetchChartArchive(ctx context.Context, chart *v1.HelmChart) ([]byte, error) {
+	client, err := c.repoClient(chart)
+	if err != nil {
+		return nil, err
+	}
+	var b []byte
+	switch {
+	case strings.HasPrefix(chart.Spec.Chart, "oci://"):
+		b, err = c.fetchOCIChart(ctx, chart, client)
+	case chart.Spec.Repo == "":
+		b, err = c.fetchChartURL(ctx, chart, client, chart.Spec.Chart, "", true)
+	default:
+		b, err = c.fetchIndexChart(ctx, chart, client)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxChartContentBytes {
+		return nil, errArchiveTooLarge
+	}
+	return b, nil
+}
+
+// fetchIndexChart downloads the chart archive for the chart's version, from the URL listed in the repository
+// index. As with helm, credentials are only sent to hosts other than the repository if the chart allows it.
+func (c *Controller) fetchIndexChart(ctx context.Context, chart *v1.HelmChart, client *http.Client) ([]byte, error) {
+	b, err := c.getIndex(ctx, chart, client)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := indexEntries(b, chart.Spec.Chart)
+	if err != nil {
+		return nil, err
+	}
+	version := strings.TrimPrefix(chart.Spec.Version, "v")
+	for _, entry := range entries {
+		if strings.TrimPrefix(entry.Version, "v") != version {
+			continue
+		}
+		if len(entry.URLs) == 0 {
+			return nil, fmt.Errorf("chart %s version %s has no URLs in repository index", chart.Spec.Chart, chart.Spec.Version)
+		}
+		base, err := url.Parse(strings.TrimSuffix(chart.Spec.Repo, "/") + "/")
+		if err != nil {
+			return nil, err
+		}
+		archiveURL, err := base.Parse(entry.URLs[0])
+		if err != nil {
+			return nil, err
+		}
+		auth := archiveURL.Host == base.Host || chart.Spec.AuthPassCredentials
+		return c.fetchChartURL(ctx, chart, client, archiveURL.String(), entry.Digest, auth)
+	}
+	return nil, fmt.Errorf("chart %s version %s not found in repository index", chart.Spec.Chart, chart.Spec.Version)
+}
+
+// fetchChartURL downloads the chart archive from the URL, and verifies the digest, if provided.
+func (c *Controller) fetchChartURL(ctx context.Context, chart *v1.HelmChart, client *http.Client, archiveURL, digest string, auth bool) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, archiveURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if auth {
+		if err := c.setRepoAuth(chart, req); err != nil {
+			return nil, err
+		}
+	}
+	b, err := doRequest(client, req, maxChartContentBytes+1)
+	if err != nil {
+		return nil, err
+	}
+	return b, verifyDigest(b, digest)
+}
+
+// fetchOCIChart downloads the chart archive layer of the chart's version from the OCI registry. Helm
+// replaces the `+` in semver build metadata with `_` when pushing tags, so this is done here as well.
+func (c *Controller) fetchOCIChart(ctx context.Context, chart *v1.HelmChart, client *http.Client) ([]byte, error) {
+	base, host := ociRepository(chart)
+	username, password, err := c.registryCredentials(chart, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var token string
+	tag := strings.ReplaceAll(chart.Spec.Version, "+", "_")
+	resp, err := registryGet(ctx, client, base+"/manifests/"+tag, ociManifestMediaType, username, password, &token)
+	if err != nil {
+		return nil, err
+	}
+	b, err := readResponse(resp, 1024*1024)
+	if err != nil {
+		return nil, err
+	}
+	manifest := struct {
+		Layers []struct {
+			MediaType string `json:"mediaType"`
+			Digest    string `json:"digest"`
+			Size      int64  `json:"size"`
+		} `json:"layers"`
+	}{}
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != ociChartLayerMediaType {
+			continue
+		}
+		if layer.Size > maxChartContentBytes {
+			return nil, fmt.Errorf("chart archive is %d bytes: %w", layer.Size, errArchiveTooLarge)
+		}
+		resp, err := registryGet(ctx, client, base+"/blobs/"+layer.Digest, "", username, password, &token)
+		if err != nil {
+			return nil, err
+		}
+		if b, err = readResponse(resp, maxChartContentBytes+1); err != nil {
+			return nil, err
+		}
+		return b, verifyDigest(b, layer.Digest)
+	}
+	return nil, errors.New("manifest does not contain a chart archive layer")
+}
+
+// verifyDigest checks that the sha256 digest of the archive matches the expected digest. Digests from
+// repository indexes are plain hex, while OCI digests are prefixed with the algorithm.
+func verifyDigest(b []byte, digest string) error {
+	if digest == "" {
+		return nil
+	}
+	algorithm, expected, found := strings.Cut(digest, ":")
+	if !found {
+		algorithm, expected = "sha256", digest
+	}
+	if algorithm != "sha256" {
+		return fmt.Errorf("unsupported digest algorithm %s", algorithm)
+	}
+	sum := sha256.Sum256(b)
+	if actual := hex.EncodeToString(sum[:]); actual != expected {
+		return fmt.Errorf("chart archive digest sha256:%s does not match expected digest %s", actual, digest)
+	}
+	return nil
+}
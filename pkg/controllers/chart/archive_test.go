@@ -0,0 +1,173 @@
+package chart
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+type fakeContentConfigMapCache struct {
+	fakeConfigMapCache
+	configMaps []*corev1.ConfigMap
+}
+
+func (f fakeContentConfigMapCache) Get(namespace, name string) (*corev1.ConfigMap, error) {
+	for _, cm := range f.configMaps {
+		if cm.Namespace == namespace && cm.Name == name {
+			return cm, nil
+		}
+	}
+	return f.fakeConfigMapCache.Get(namespace, name)
+}
+
+func TestArchiveKey(t *testing.T) {
+	tests := []struct {
+		name string
+		spec v1.HelmChartSpec
+		key  string
+	}{
+		{"repository", v1.HelmChartSpec{Repo: "https://charts.example.com/", Chart: "traefik", Version: "1.0.0"}, "https://charts.example.com/traefik:1.0.0"},
+		{"repository without version", v1.HelmChartSpec{Repo: "https://charts.example.com", Chart: "traefik"}, ""},
+		{"oci", v1.HelmChartSpec{Chart: "oci://registry.example.com/charts/traefik", Version: "1.0.0"}, "oci://registry.example.com/charts/traefik:1.0.0"},
+		{"oci without version", v1.HelmChartSpec{Chart: "oci://registry.example.com/charts/traefik"}, ""},
+		{"url", v1.HelmChartSpec{Chart: "https://charts.example.com/traefik-1.0.0.tgz"}, "https://charts.example.com/traefik-1.0.0.tgz"},
+		{"chart content", v1.HelmChartSpec{ChartContent: "H4sI"}, ""},
+		{"git", v1.HelmChartSpec{Git: &v1.GitSource{URL: "https://git.example.com/charts.git"}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.key, archiveKey(&v1.HelmChart{Spec: tt.spec}))
+		})
+	}
+}
+
+func TestResolveChartArchive(t *testing.T) {
+	assert := assert.New(t)
+	defer func(enabled bool) { CacheChartArchives = enabled }(CacheChartArchives)
+	CacheChartArchives = true
+
+	archive := []byte("chart archive")
+	sum := sha256.Sum256(archive)
+	digest := hex.EncodeToString(sum[:])
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/charts/index.yaml":
+			fmt.Fprintf(w, "apiVersion: v1\nentries:\n  traefik:\n  - version: 1.0.0\n    urls: [traefik-1.0.0.tgz]\n    digest: %s\n  - version: 1.1.0\n    urls: [traefik-1.1.0.tgz]\n    digest: %s\n", digest, strings.Repeat("0", 64))
+		case "/v2/charts/traefik/manifests/1.0.0_build.1":
+			assert.Equal(ociManifestMediaType, r.Header.Get("Accept"))
+			fmt.Fprintf(w, `{"layers":[{"mediaType":"%s","digest":"sha256:%s","size":%d}]}`, ociChartLayerMediaType, digest, len(archive))
+		case "/v2/charts/traefik/manifests/1.2.0":
+			fmt.Fprintf(w, `{"layers":[{"mediaType":"%s","digest":"sha256:%s","size":%d}]}`, ociChartLayerMediaType, digest, maxChartContentBytes+1)
+		case "/charts/traefik-1.0.0.tgz", "/charts/traefik-1.1.0.tgz", "/v2/charts/traefik/blobs/sha256:" + digest:
+			downloads++
+			w.Write(archive)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := &Controller{logger: klog.Background(), configMapCache: fakeConfigMapCache{}}
+	chart := NewChart()
+	chart.Spec.Repo = server.URL + "/charts/"
+	chart.Spec.Chart = "traefik"
+	chart.Spec.Version = "1.0.0"
+
+	// the archive is downloaded once, and then served from the cache
+	for range 2 {
+		resolved, key, err := c.resolveChartArchive(chart)
+		assert.NoError(err)
+		assert.Equal(server.URL+"/charts/traefik:1.0.0", key)
+		assert.Equal(base64.StdEncoding.EncodeToString(archive), resolved.Spec.ChartContent)
+		assert.Empty(resolved.Spec.Repo)
+	}
+	assert.Equal(1, downloads)
+	assert.Empty(chart.Spec.ChartContent, "original chart should not be modified")
+
+	// archives that do not match the digest are not used, and the failure is cached
+	chart.Spec.Version = "1.1.0"
+	for range 2 {
+		resolved, key, err := c.resolveChartArchive(chart)
+		assert.ErrorContains(err, "does not match expected digest")
+		assert.Empty(key)
+		assert.Nil(resolved)
+	}
+	assert.Equal(2, downloads)
+
+	// archives are recovered from the chart content ConfigMap
+	content := contentConfigMap(chart)
+	content.Annotations = map[string]string{KeyChartArchive: archiveKey(chart)}
+	content.Data["traefik.tgz.base64"] = "cached"
+	c = &Controller{logger: klog.Background(), configMapCache: fakeContentConfigMapCache{configMaps: []*corev1.ConfigMap{content}}}
+	downloads = 0
+	resolved, _, err := c.resolveChartArchive(chart)
+	assert.NoError(err)
+	assert.Equal("cached", resolved.Spec.ChartContent)
+	assert.Zero(downloads)
+
+	// archives are downloaded from OCI registries by digest
+	chart.Spec.Repo = ""
+	chart.Spec.Chart = "oci://" + strings.TrimPrefix(server.URL, "http://") + "/charts/traefik"
+	chart.Spec.PlainHTTP = true
+	chart.Spec.Version = "1.0.0+build.1"
+	resolved, _, err = c.resolveChartArchive(chart)
+	assert.NoError(err)
+	assert.Equal(base64.StdEncoding.EncodeToString(archive), resolved.Spec.ChartContent)
+	assert.Equal(1, downloads)
+
+	// OCI layers that are too large are not downloaded, and the job downloads the chart instead
+	chart.Spec.Version = "1.2.0"
+	resolved, key, err := c.resolveChartArchive(chart)
+	assert.NoError(err)
+	assert.Empty(key)
+	assert.Same(chart, resolved)
+	assert.Equal(1, downloads)
+
+	// archives are not cached if caching is disabled
+	CacheChartArchives = false
+	chart.Spec.Version = "2.0.0"
+	resolved, key, err = c.resolveChartArchive(chart)
+	assert.NoError(err)
+	assert.Empty(key)
+	assert.Same(chart, resolved)
+}
+
+func TestArchiveCacheEviction(t *testing.T) {
+	assert := assert.New(t)
+	cache := archiveCache{}
+	for i := range maxCachedArchives {
+		cache.add(fmt.Sprint(i), "content")
+	}
+	_, ok := cache.get("0")
+	assert.True(ok)
+	cache.add("new", "content")
+	_, ok = cache.get("0")
+	assert.True(ok, "recently used archive should not be evicted")
+	_, ok = cache.get("1")
+	assert.False(ok, "least recently used archive should be evicted")
+	assert.Len(cache.content, maxCachedArchives)
+}
+
+func TestArchiveCacheFailures(t *testing.T) {
+	assert := assert.New(t)
+	cache := archiveCache{}
+	cache.fail("key", errArchiveTooLarge)
+	assert.ErrorIs(cache.failure("key"), errArchiveTooLarge)
+	assert.NoError(cache.failure("other"))
+
+	// failures are retried once the retry interval has passed
+	cache.failures["key"] = archiveFailure{err: errArchiveTooLarge, time: time.Now().Add(-archiveRetryInterval)}
+	assert.NoError(cache.failure("key"))
+}
@@ -13,16 +13,22 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/k3s-io/helm-controller/pkg/config"
 	"github.com/k3s-io/helm-controller/pkg/controllers/extjson"
 	helmcontroller "github.com/k3s-io/helm-controller/pkg/generated/controllers/helm.cattle.io/v1"
+	"github.com/k3s-io/helm-controller/pkg/metrics"
+	"github.com/k3s-io/helm-controller/pkg/notify"
 	"github.com/k3s-io/helm-controller/pkg/remove"
 	"github.com/rancher/wrangler/v3/pkg/apply"
 	batchcontroller "github.com/rancher/wrangler/v3/pkg/generated/controllers/batch/v1"
 	corecontroller "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
 	rbaccontroller "github.com/rancher/wrangler/v3/pkg/generated/controllers/rbac/v1"
 	"github.com/rancher/wrangler/v3/pkg/generic"
+	"github.com/rancher/wrangler/v3/pkg/kv"
 	"github.com/rancher/wrangler/v3/pkg/merr"
 	"github.com/rancher/wrangler/v3/pkg/relatedresource"
 	batch "k8s.io/api/batch/v1"
@@ -30,6 +36,7 @@ import (
 	rbac "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/fields"
@@ -38,7 +45,12 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 	"k8s.io/utils/ptr"
@@ -52,7 +64,10 @@ const (
 
 	TaintExternalCloudProvider = "node.cloudprovider.kubernetes.io/uninitialized"
 
-	KeyConfigHash = "helmcharts.helm.cattle.io/configHash"
+	KeyConfigHash       = "helmcharts.helm.cattle.io/configHash"
+	KeyRollbackRevision = "helmcharts.helm.cattle.io/rollbackRevision"
+	KeyReleaseRevision  = "helmcharts.helm.cattle.io/releaseRevision"
+	KeyChartArchive     = "helmcharts.helm.cattle.io/chartArchive"
 
 	AnnotationChartURL  = "helm.cattle.io/chart-url"
 	AnnotationManagedBy = "helmcharts.cattle.io/managed-by"
@@ -63,8 +78,12 @@ const (
 	LabelControlPlaneSuffix = "control-plane"
 	LabelEtcdSuffix         = "etcd"
 
-	chartBySecretIndex       = "helmcharts.helm.cattle.io/chart-by-secret"
-	chartConfigBySecretIndex = "helmcharts.helm.cattle.io/chartconfig-by-secret"
+	chartBySecretIndex              = "helmcharts.helm.cattle.io/chart-by-secret"
+	chartConfigBySecretIndex        = "helmcharts.helm.cattle.io/chartconfig-by-secret"
+	chartByDependencyIndex          = "helmcharts.helm.cattle.io/chart-by-dependency"
+	chartByConfigMapIndex           = "helmcharts.helm.cattle.io/chart-by-configmap"
+	chartConfigByConfigMapIndex     = "helmcharts.helm.cattle.io/chartconfig-by-configmap"
+	chartByReferencedNamespaceIndex = "helmcharts.helm.cattle.io/chart-by-referenced-namespace"
 )
 
 var (
@@ -90,25 +109,54 @@ var (
 		ReadOnlyRootFilesystem: ptr.To(true),
 	}
 	defaultPriorityClassName = "system-cluster-critical"
+
+	// DefaultDriftDetectionInterval is the interval between drift checks for charts that do not specify one.
+	DefaultDriftDetectionInterval = 10 * time.Minute
+
+	// Notifier sends notifications of release lifecycle transitions, if configured.
+	Notifier *notify.Notifier
+
+	// errInvalidJobResources indicates that the resource requirements for the job, merged from the
+	// controller defaults, HelmChart and HelmChartConfigs, would not be accepted by the apiserver.
+	errInvalidJobResources = errors.New("invalid job resources")
 )
 
 type Controller struct {
-	apiServerPort   string
-	jobClusterRole  string
-	managedBy       string
-	systemNamespace string
-	logger          klog.Logger
-	helms           helmcontroller.HelmChartController
-	helmCache       helmcontroller.HelmChartCache
-	confs           helmcontroller.HelmChartConfigController
-	confCache       helmcontroller.HelmChartConfigCache
-	jobs            batchcontroller.JobController
-	jobCache        batchcontroller.JobCache
-	configMaps      configMapLister
-	secrets         secretLister
-	secretCache     corecontroller.SecretCache
-	apply           apply.Apply
-	recorder        record.EventRecorder
+	apiServerPort    string
+	jobClusterRole   string
+	managedBy        string
+	systemNamespace  string
+	logger           klog.Logger
+	helms            helmcontroller.HelmChartController
+	helmCache        helmcontroller.HelmChartCache
+	confs            helmcontroller.HelmChartConfigController
+	confCache        helmcontroller.HelmChartConfigCache
+	grantCache       helmcontroller.HelmChartReferenceGrantCache
+	jobs             batchcontroller.JobController
+	jobCache         batchcontroller.JobCache
+	configMaps       configMapLister
+	configMapCache   corecontroller.ConfigMapCache
+	configMapClient  corecontroller.ConfigMapClient
+	pods             typedcorev1.PodsGetter
+	dynamic          dynamic.Interface
+	namespacedRBAC   bool
+	mapper           meta.ResettableRESTMapper
+	secrets          secretLister
+	secretCache      corecontroller.SecretCache
+	apply            apply.Apply
+	recorder         record.EventRecorder
+	notifier         *notify.Notifier
+	started          time.Time
+	jobOutcomes      sync.Map
+	jobNotified      sync.Map
+	jobApplying      sync.Map
+	gitCharts        sync.Map
+	gitFetches       sync.Map
+	chartVersions    sync.Map
+	configCharts     sync.Map
+	releaseSummaries sync.Map
+	releaseInfos     sync.Map
+	archives         archiveCache
 }
 
 type configMapLister interface {
@@ -119,6 +167,18 @@ type secretLister interface {
 	List(namespace string, opts metav1.ListOptions) (*corev1.SecretList, error)
 }
 
+// Options holds optional dependencies of the controller. Features that require a dependency are
+// disabled if it is not provided.
+type Options struct {
+	// Dynamic is used to retrieve the resources of deployed releases, for drift detection and workload health checks.
+	Dynamic dynamic.Interface
+	// ReferenceGrants is used to permit references to Secrets and ConfigMaps in other namespaces.
+	// Cross-namespace references are not permitted if it is not provided.
+	ReferenceGrants helmcontroller.HelmChartReferenceGrantController
+	// RoleBindings is used to bind the job role in the target namespace, for charts with a namespaced RBAC scope.
+	RoleBindings rbaccontroller.RoleBindingController
+}
+
 func Register(
 	ctx context.Context,
 	systemNamespace,
@@ -139,6 +199,33 @@ func Register(
 	cm corecontroller.ConfigMapController,
 	s corecontroller.SecretController,
 	sCache corecontroller.SecretCache) {
+	RegisterWithOptions(ctx, systemNamespace, managedBy, jobClusterRole, apiServerPort, k8s, apply, recorder,
+		helms, helmCache, confs, confCache, jobs, jobCache, crbs, sas, cm, s, sCache, Options{})
+}
+
+// RegisterWithOptions registers the controller, as Register does, with the optional dependencies
+// that enable additional features.
+func RegisterWithOptions(
+	ctx context.Context,
+	systemNamespace,
+	managedBy,
+	jobClusterRole string,
+	apiServerPort string,
+	k8s kubernetes.Interface,
+	apply apply.Apply,
+	recorder record.EventRecorder,
+	helms helmcontroller.HelmChartController,
+	helmCache helmcontroller.HelmChartCache,
+	confs helmcontroller.HelmChartConfigController,
+	confCache helmcontroller.HelmChartConfigCache,
+	jobs batchcontroller.JobController,
+	jobCache batchcontroller.JobCache,
+	crbs rbaccontroller.ClusterRoleBindingController,
+	sas corecontroller.ServiceAccountController,
+	cm corecontroller.ConfigMapController,
+	s corecontroller.SecretController,
+	sCache corecontroller.SecretCache,
+	opts Options) {
 	c := &Controller{
 		apiServerPort:   apiServerPort,
 		jobClusterRole:  jobClusterRole,
@@ -152,22 +239,46 @@ func Register(
 		jobs:            jobs,
 		jobCache:        jobCache,
 		configMaps:      cm,
+		configMapCache:  cm.Cache(),
+		configMapClient: cm,
+		pods:            k8s.CoreV1(),
+		dynamic:         opts.Dynamic,
+		namespacedRBAC:  opts.RoleBindings != nil,
+		mapper:          restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(k8s.Discovery())),
 		secrets:         s,
 		secretCache:     sCache,
 		recorder:        recorder,
+		notifier:        Notifier,
+		started:         time.Now(),
 	}
 
 	c.apply = apply.
 		WithCacheTypes(helms, confs, jobs, crbs, sas, cm, s).
 		WithStrictCaching().
 		WithReconciler(jobs.GroupVersionKind(), c.reconcileJob)
+	owned := []relatedresource.ControllerWrapper{jobs, crbs, sas, cm}
+	if opts.RoleBindings != nil {
+		c.apply = c.apply.WithCacheTypes(opts.RoleBindings)
+		owned = append(owned, opts.RoleBindings)
+	}
 
 	helmCache.AddIndexer(chartBySecretIndex, chartBySecret)
 	confCache.AddIndexer(chartConfigBySecretIndex, chartConfigBySecret)
+	helmCache.AddIndexer(chartByDependencyIndex, chartByDependency)
+	helmCache.AddIndexer(chartByConfigMapIndex, chartByConfigMap)
+	confCache.AddIndexer(chartConfigByConfigMapIndex, chartConfigByConfigMap)
+	helmCache.AddIndexer(chartByReferencedNamespaceIndex, chartByReferencedNamespace)
 
 	relatedresource.Watch(ctx, "resolve-helm-chart-from-helm-chart-config", c.resolveHelmChartFromHelmChartConfig, helms, confs)
 	relatedresource.Watch(ctx, "resolve-helm-chart-from-secret", c.resolveHelmChartFromSecret, helms, s)
 	relatedresource.Watch(ctx, "resolve-helm-chart-config-from-secret", c.resolveHelmChartConfigFromSecret, confs, s)
+	relatedresource.Watch(ctx, "resolve-helm-chart-from-configmap", c.resolveHelmChartFromConfigMap, helms, cm)
+	relatedresource.Watch(ctx, "resolve-helm-chart-config-from-configmap", c.resolveHelmChartConfigFromConfigMap, confs, cm)
+	if opts.ReferenceGrants != nil {
+		c.grantCache = opts.ReferenceGrants.Cache()
+		relatedresource.Watch(ctx, "resolve-helm-chart-from-reference-grant", c.resolveHelmChartFromReferenceGrant, helms, opts.ReferenceGrants)
+	}
+	relatedresource.Watch(ctx, "resolve-helm-chart-from-dependency", c.resolveHelmChartFromDependency, helms, helms, jobs)
 
 	// Why do we need to add the managedBy string to the generatingHandlerName?
 	//
@@ -178,9 +289,17 @@ func Register(
 	// To resolve this, we simply prefix the provided managedBy string to the generatingHandler controller's name only to ensure that the
 	// set ID specified will only target this particular controller
 	generatingHandlerName := fmt.Sprintf("%s-chart-registration", managedBy)
-	helmcontroller.RegisterHelmChartGeneratingHandler(ctx, helms, c.apply, "", generatingHandlerName, c.OnChange, &generic.GeneratingHandlerOptions{
-		AllowClusterScoped: true,
-	})
+	helmcontroller.RegisterHelmChartGeneratingHandler(ctx, helms, c.apply, "", generatingHandlerName,
+		func(chart *v1.HelmChart, chartStatus v1.HelmChartStatus) ([]runtime.Object, v1.HelmChartStatus, error) {
+			start := time.Now()
+			objs, chartStatus, err := c.OnChange(chart, chartStatus)
+			metrics.ObserveReconcile("OnChange", start, err)
+			return objs, chartStatus, err
+		},
+		&generic.GeneratingHandlerOptions{
+			AllowClusterScoped: true,
+		},
+	)
 
 	remove.RegisterScopedOnRemoveHandler(ctx, helms, "on-helm-chart-remove",
 		func(key string, obj runtime.Object) (bool, error) {
@@ -193,13 +312,22 @@ func Register(
 			}
 			return c.shouldManage(helmChart)
 		},
-		generic.FromObjectHandlerToHandler(generic.ObjectHandler[*v1.HelmChart](c.OnRemove)),
+		generic.FromObjectHandlerToHandler(generic.ObjectHandler[*v1.HelmChart](func(key string, chart *v1.HelmChart) (*v1.HelmChart, error) {
+			start := time.Now()
+			chart, err := c.OnRemove(key, chart)
+			metrics.ObserveReconcile("OnRemove", start, err)
+			return chart, err
+		})),
 	)
 
+	helms.OnChange(ctx, "helm-chart-metrics", c.updateChartMetrics)
+	jobs.OnChange(ctx, "helm-job-metrics", c.updateJobMetrics)
+	jobs.OnChange(ctx, "helm-job-notifications", c.notifyJob)
+
 	relatedresource.Watch(ctx, "resolve-helm-chart-owned-resources",
 		relatedresource.OwnerResolver(true, v1.SchemeGroupVersion.String(), "HelmChart"),
 		helms,
-		jobs, crbs, sas, cm,
+		owned...,
 	)
 }
 
@@ -236,30 +364,141 @@ func (c *Controller) reconcileJob(_, newObj runtime.Object) (bool, error) {
 	return false, apply.ErrReplace
 }
 
-func (c *Controller) resolveHelmChartFromHelmChartConfig(namespace, name string, obj runtime.Object) ([]relatedresource.Key, error) {
-	if len(c.systemNamespace) > 0 && namespace != c.systemNamespace {
-		// do nothing if it's not in the namespace this controller was registered with
+// updateChartMetrics tracks the Failed condition of charts managed by this controller,
+// and removes metrics for charts that have been deleted.
+func (c *Controller) updateChartMetrics(key string, chart *v1.HelmChart) (*v1.HelmChart, error) {
+	if chart == nil {
+		namespace, name := kv.RSplit(key, "/")
+		metrics.DeleteChart(namespace, name)
 		return nil, nil
 	}
-	// See if there is a HelmChart with the same name/namespace as this HelmChartConfig
-	if conf, ok := obj.(*v1.HelmChartConfig); ok {
-		chart, err := c.helmCache.Get(conf.Namespace, conf.Name)
-		if err != nil {
-			if !apierrors.IsNotFound(err) {
-				return nil, err
-			}
+	if chart.Annotations[AnnotationManagedBy] != c.managedBy {
+		return chart, nil
+	}
+	var failed bool
+	for _, condition := range chart.Status.Conditions {
+		if condition.Type == v1.HelmChartFailed {
+			failed = condition.Status == corev1.ConditionTrue
 		}
-		if chart == nil {
-			return nil, nil
+	}
+	metrics.SetChartFailed(chart.Namespace, chart.Name, failed)
+	return chart, nil
+}
+
+// updateJobMetrics records the outcome of helm jobs once they have completed or failed.
+// The UID of the last recorded job is tracked for each key, so that each job is only counted once;
+// jobs that finished before the controller started are not counted, as they were counted by the
+// previous instance of the controller.
+func (c *Controller) updateJobMetrics(key string, job *batch.Job) (*batch.Job, error) {
+	if job == nil {
+		c.jobOutcomes.Delete(key)
+		return nil, nil
+	}
+	chartName := job.Labels[LabelChartName]
+	if chartName == "" {
+		return job, nil
+	}
+	finished := finishedCondition(job)
+	if finished == nil || finished.LastTransitionTime.Time.Before(c.started) {
+		return job, nil
+	}
+	if uid, ok := c.jobOutcomes.Load(key); ok && uid == job.UID {
+		return job, nil
+	}
+	c.jobOutcomes.Store(key, job.UID)
+	outcome := metrics.OutcomeSucceeded
+	if finished.Type == batch.JobFailed {
+		outcome = metrics.OutcomeFailed
+	}
+	action, _, _ := strings.Cut(strings.TrimPrefix(job.Name, "helm-"), "-")
+	metrics.IncJobOutcome(job.Namespace, chartName, action, outcome)
+	return job, nil
+}
+
+// finishedCondition returns the True Complete or Failed condition of the job, or nil if the job has not finished.
+func finishedCondition(job *batch.Job) *batch.JobCondition {
+	var finished *batch.JobCondition
+	for i, condition := range job.Status.Conditions {
+		if condition.Status == corev1.ConditionTrue && (condition.Type == batch.JobComplete || condition.Type == batch.JobFailed) {
+			finished = &job.Status.Conditions[i]
 		}
-		return []relatedresource.Key{
-			{
-				Name:      conf.Name,
-				Namespace: conf.Namespace,
-			},
-		}, nil
 	}
-	return nil, nil
+	return finished
+}
+
+// notifyJob sends a notification once a helm job to install, upgrade, roll back, or uninstall the chart has
+// completed or failed. As with metrics, the UID of the last job is tracked for each key, so that each job is
+// only notified once; jobs that finished before the controller started are not notified.
+func (c *Controller) notifyJob(key string, job *batch.Job) (*batch.Job, error) {
+	if job == nil {
+		c.jobNotified.Delete(key)
+		c.jobApplying.Delete(key)
+		return nil, nil
+	}
+	chartName := job.Labels[LabelChartName]
+	if c.notifier == nil || chartName == "" {
+		return job, nil
+	}
+	action, _, _ := strings.Cut(strings.TrimPrefix(job.Name, "helm-"), "-")
+	if err := c.notifyApplying(key, job, chartName, action); err != nil {
+		return job, err
+	}
+	finished := finishedCondition(job)
+	if finished == nil || finished.LastTransitionTime.Time.Before(c.started) {
+		return job, nil
+	}
+	if uid, ok := c.jobNotified.Load(key); ok && uid == job.UID {
+		return job, nil
+	}
+
+	// completed uninstall jobs are notified when the chart is removed
+	var event string
+	switch {
+	case finished.Type == batch.JobFailed && (action == "install" || action == "rollback" || action == "delete"):
+		event = notify.EventFailed
+	case action == "install":
+		event = notify.EventSucceeded
+	case action == "rollback":
+		event = notify.EventRolledBack
+	default:
+		return job, nil
+	}
+	chart, err := c.helmCache.Get(job.Namespace, chartName)
+	if apierrors.IsNotFound(err) {
+		return job, nil
+	} else if err != nil {
+		return job, err
+	}
+	c.jobNotified.Store(key, job.UID)
+
+	message := fmt.Sprintf("Job %s/%s completed", job.Namespace, job.Name)
+	if event == notify.EventFailed {
+		message = fmt.Sprintf("Job %s/%s failed: %s", job.Namespace, job.Name, finished.Message)
+	}
+	c.notifier.Notify(chart, event, job.Name, message)
+	return job, nil
+}
+
+// notifyApplying sends a notification when a job to install or upgrade the chart is first observed, so that
+// it is only sent once the job has been created. The UID and config hash of the last job are tracked for
+// each key, so that each job is only notified once; jobs created before the controller started are not notified.
+func (c *Controller) notifyApplying(key string, job *batch.Job, chartName, action string) error {
+	if action != "install" || job.CreationTimestamp.Time.Before(c.started) {
+		return nil
+	}
+	applying := string(job.UID) + "/" + configHash(job)
+	if prev, ok := c.jobApplying.Load(key); ok && prev == applying {
+		return nil
+	}
+	chart, err := c.helmCache.Get(job.Namespace, chartName)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	c.jobApplying.Store(key, applying)
+	c.notifier.Notify(chart, notify.EventApplying, job.Name, fmt.Sprintf("Applying HelmChart from %s", chartSource(chart)))
+	return nil
 }
 
 func (c *Controller) resolveHelmChartFromSecret(namespace, name string, obj runtime.Object) ([]relatedresource.Key, error) {
@@ -304,6 +543,75 @@ func (c *Controller) resolveHelmChartConfigFromSecret(namespace, name string, ob
 	return nil, nil
 }
 
+func (c *Controller) resolveHelmChartFromConfigMap(namespace, name string, obj runtime.Object) ([]relatedresource.Key, error) {
+	if len(c.systemNamespace) > 0 && namespace != c.systemNamespace {
+		// do nothing if it's not in the namespace this controller was registered with
+		return nil, nil
+	}
+	// See if there are HelmCharts in the same namespace that reference this ConfigMap
+	if configMap, ok := obj.(*corev1.ConfigMap); ok {
+		charts, err := c.helmCache.GetByIndex(chartByConfigMapIndex, configMap.Namespace+"."+configMap.Name)
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]relatedresource.Key, len(charts))
+		for i, chart := range charts {
+			keys[i].Name = chart.Name
+			keys[i].Namespace = chart.Namespace
+		}
+		return keys, nil
+	}
+	return nil, nil
+}
+
+func (c *Controller) resolveHelmChartConfigFromConfigMap(namespace, name string, obj runtime.Object) ([]relatedresource.Key, error) {
+	if len(c.systemNamespace) > 0 && namespace != c.systemNamespace {
+		// do nothing if it's not in the namespace this controller was registered with
+		return nil, nil
+	}
+	// See if there are HelmChartConfigs in the same namespace that reference this ConfigMap
+	if configMap, ok := obj.(*corev1.ConfigMap); ok {
+		confs, err := c.confCache.GetByIndex(chartConfigByConfigMapIndex, configMap.Namespace+"."+configMap.Name)
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]relatedresource.Key, len(confs))
+		for i, conf := range confs {
+			keys[i].Name = conf.Name
+			keys[i].Namespace = conf.Namespace
+		}
+		return keys, nil
+	}
+	return nil, nil
+}
+
+func (c *Controller) resolveHelmChartFromDependency(namespace, name string, obj runtime.Object) ([]relatedresource.Key, error) {
+	// See if there are HelmCharts that depend on this HelmChart, or on the HelmChart that owns this Job
+	var key string
+	switch o := obj.(type) {
+	case *v1.HelmChart:
+		key = o.Namespace + "." + o.Name
+	case *batch.Job:
+		chartName := o.Labels[LabelChartName]
+		if chartName == "" {
+			return nil, nil
+		}
+		key = o.Namespace + "." + chartName
+	default:
+		return nil, nil
+	}
+	charts, err := c.helmCache.GetByIndex(chartByDependencyIndex, key)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]relatedresource.Key, len(charts))
+	for i, chart := range charts {
+		keys[i].Name = chart.Name
+		keys[i].Namespace = chart.Namespace
+	}
+	return keys, nil
+}
+
 func (c *Controller) OnChange(chart *v1.HelmChart, chartStatus v1.HelmChartStatus) ([]runtime.Object, v1.HelmChartStatus, error) {
 	if shouldManage, err := c.shouldManage(chart); err != nil {
 		return nil, chartStatus, err
@@ -316,45 +624,94 @@ func (c *Controller) OnChange(chart *v1.HelmChart, chartStatus v1.HelmChartStatu
 		return nil, chartStatus, nil
 	}
 
+	// roll back the release if it failed its tests with the current configuration; the rollback is
+	// driven from the status, so the chart is only modified in memory.
+	installChart := chart
+	chart, rollingBack := testRollback(chart)
+	if !rollingBack {
+		chartStatus.TestRollbackConfigHash = ""
+	}
+
+	chartStatus.ObservedGeneration = chart.Generation
+	if chart.Spec.RollbackTo == nil {
+		chartStatus.RolledBackRevision = 0
+	}
+	if !chart.Spec.DryRun {
+		if err := c.removeDryRun(chart); err != nil {
+			return nil, chartStatus, fmt.Errorf("failed to remove dry-run diff: %w", err)
+		}
+		chartStatus.Conditions = removeCondition(chartStatus.Conditions, v1.HelmChartDryRun)
+	}
+	if driftDetectionInterval(chart) <= 0 {
+		chartStatus.LastDriftCheckTime = nil
+		chartStatus.Conditions = removeCondition(chartStatus.Conditions, v1.HelmChartDrifted)
+	}
+
 	switch chart.Spec.HelmVersion {
 	case "", "v3":
 	default:
 		c.recorder.Eventf(chart, corev1.EventTypeWarning, "UnsupportedVersion", "Unsupported Helm version %s: only v3 charts are supported", chart.Spec.HelmVersion)
-		chartStatus.Conditions = []v1.HelmChartCondition{
-			{
+		chartStatus.Conditions = setConditions(chartStatus.Conditions,
+			v1.HelmChartCondition{
 				Type:   v1.HelmChartJobCreated,
 				Status: corev1.ConditionFalse,
 			},
-			{
+			v1.HelmChartCondition{
 				Type:    v1.HelmChartFailed,
 				Status:  corev1.ConditionTrue,
 				Reason:  "Unsupported version",
 				Message: "Only Helm v3 charts are supported",
 			},
-		}
+			v1.HelmChartCondition{
+				Type:   v1.HelmChartReady,
+				Status: corev1.ConditionFalse,
+				Reason: "Unsupported version",
+			},
+		)
 		return nil, chartStatus, nil
 	}
 
-	if c.jobFailed(chart) {
-		c.recorder.Eventf(chart, corev1.EventTypeWarning, "JobFailed", "Job has reached configured number of retries without succeeding")
-		chartCopy := chart.DeepCopy()
-		chartCopy.Status.Conditions = []v1.HelmChartCondition{
-			{
+	// leave the release and any existing job as-is while the chart is suspended.
+	if chart.Spec.Suspend {
+		if !suspended(chart.Status) {
+			c.recorder.Eventf(chart, corev1.EventTypeNormal, "Suspended", "Installation and upgrade of HelmChart are suspended")
+		}
+		chartStatus.Conditions = setConditions(chartStatus.Conditions, v1.HelmChartCondition{
+			Type:    v1.HelmChartSuspended,
+			Status:  corev1.ConditionTrue,
+			Reason:  "Suspended",
+			Message: "Installation and upgrade of the chart are suspended; clear .spec.suspend to resume",
+		})
+		return nil, chartStatus, c.updateStatus(chart, chartStatus)
+	}
+	if suspended(chart.Status) {
+		c.recorder.Eventf(chart, corev1.EventTypeNormal, "Resumed", "Installation and upgrade of HelmChart have been resumed")
+	}
+	chartStatus.Conditions = removeCondition(chartStatus.Conditions, v1.HelmChartSuspended)
+
+	if job, condition := c.failedJob(chart); job != nil {
+		message := "Job has reached configured number of retries without succeeding"
+		failure, recorded := c.recordJobFailure(job, condition, &chartStatus)
+		if failure.Message != "" {
+			message += ": " + failure.Message
+		}
+		if recorded {
+			c.recorder.Event(chart, corev1.EventTypeWarning, "JobFailed", message)
+		}
+		chartStatus.Conditions = setConditions(chartStatus.Conditions,
+			v1.HelmChartCondition{
 				Type:    v1.HelmChartJobCreated,
 				Status:  corev1.ConditionTrue,
 				Reason:  "Job created",
 				Message: fmt.Sprintf("Applying HelmChart using Job %s/%s", chart.Namespace, jobName(chart)),
 			},
-			{
+			v1.HelmChartCondition{
 				Type:    v1.HelmChartFailed,
 				Status:  corev1.ConditionTrue,
 				Reason:  "Job failed",
-				Message: "Job has reached configured number of retries without succeeding",
+				Message: message,
 			},
-		}
-		if _, err := c.helms.UpdateStatus(chartCopy); err != nil {
-			return nil, chartStatus, fmt.Errorf("unable to update status of helm chart to set failed condition: %w", err)
-		}
+		)
 	}
 
 	// Jobs are created as suspended. Once the job controller syncs it and adds the
@@ -371,37 +728,146 @@ func (c *Controller) OnChange(chart *v1.HelmChart, chartStatus v1.HelmChartStatu
 	}
 
 	// getJobAndRelatedResources may return ErrSkip if no changes are necessary for the job,
-	// in which case the chartStatus does not get updated and no resources are modified.
-	job, objs, err := c.getJobAndRelatedResources(chart)
+	// in which case no resources are modified, and only the release info in the status is updated.
+	// While rolling back after failed tests, the install job is generated first to check whether the
+	// configuration has changed, and the rollback job is only generated if it has not.
+	job, objs, release, err := c.getJobAndRelatedResources(installChart)
+	if rollingBack {
+		if testRollbackRetained(installChart, job, err) {
+			job, objs, release, err = c.getJobAndRelatedResources(chart)
+		} else {
+			chart = installChart
+			chartStatus.TestRollbackConfigHash = ""
+		}
+	}
 	if err != nil {
-		chartStatus.Conditions = []v1.HelmChartCondition{
-			{
+		if errors.Is(err, generic.ErrSkip) {
+			chartStatus.JobName = job.Name
+			chartStatus.GitCommit = gitCommit(job)
+			chartStatus.ResolvedVersion = resolvedVersion(chart, job)
+			chartStatus.PendingConfigHash = ""
+			chartStatus.Conditions = removeCondition(chartStatus.Conditions, v1.HelmChartPending)
+			setReleaseStatus(chart, &chartStatus, job, release)
+			if chart.Spec.RollbackTo != nil {
+				chartStatus.RolledBackRevision = rollbackRevision(chart, release)
+				setRollbackStatus(&chartStatus, release, c.jobComplete(chart))
+				if rolledBack(chartStatus) && !rolledBack(chart.Status) {
+					c.recorder.Eventf(chart, corev1.EventTypeNormal, "RolledBack", "Rolled back HelmChart release to revision %d", chartStatus.RolledBackRevision)
+				}
+			} else if chart.Spec.DryRun && c.jobComplete(chart) && !c.dryRunRecorded(chart, job) {
+				condition, err := c.recordDryRun(chart, job)
+				if err != nil {
+					return nil, chartStatus, err
+				}
+				chartStatus.Conditions = setConditions(chartStatus.Conditions, condition)
+			} else if !chart.Spec.DryRun {
+				if err := c.checkDrift(chart, &chartStatus); err != nil {
+					return nil, chartStatus, err
+				}
+				if err := c.checkHealth(chart, job, &chartStatus); err != nil {
+					return nil, chartStatus, err
+				}
+				if err := c.checkTest(chart, job, &chartStatus); err != nil {
+					return nil, chartStatus, err
+				}
+			}
+			return nil, chartStatus, c.updateStatus(chart, chartStatus)
+		}
+		// the chart is enqueued once it has been fetched from git, so there is no need to retry
+		if errors.Is(err, errGitPending) {
+			c.logger.V(1).Info("Waiting for chart to be fetched from git", "chart.name", chart.Namespace+"/"+chart.Name)
+			return nil, chartStatus, c.updateStatus(chart, chartStatus)
+		}
+		chartStatus.Conditions = setConditions(chartStatus.Conditions,
+			v1.HelmChartCondition{
 				Type:   v1.HelmChartJobCreated,
 				Status: corev1.ConditionFalse,
 			},
-			{
+			v1.HelmChartCondition{
 				Type:    v1.HelmChartFailed,
 				Status:  corev1.ConditionTrue,
 				Reason:  "Job create failed",
 				Message: fmt.Sprintf("Failed to generate Job: %v", err),
 			},
+		)
+		// the job cannot be created until the resources are corrected, so there is no point in retrying
+		if errors.Is(err, errInvalidJobResources) {
+			return nil, chartStatus, c.updateStatus(chart, chartStatus)
 		}
 		return nil, chartStatus, err
 	}
 
+	// hold off on creating or replacing the job until all dependencies have been deployed.
+	if conditions := c.checkDependencies(chart); conditions != nil {
+		chartStatus.Conditions = setConditions(chartStatus.Conditions, conditions...)
+		setReleaseStatus(chart, &chartStatus, job, release)
+		return nil, chartStatus, c.updateStatus(chart, chartStatus)
+	}
+
+	// hold off on creating or replacing the job until the upgrade window opens.
+	conditions, err := c.checkUpgradeWindow(chart)
+	if err != nil {
+		chartStatus.Conditions = setConditions(chartStatus.Conditions,
+			v1.HelmChartCondition{
+				Type:   v1.HelmChartJobCreated,
+				Status: corev1.ConditionFalse,
+			},
+			v1.HelmChartCondition{
+				Type:    v1.HelmChartFailed,
+				Status:  corev1.ConditionTrue,
+				Reason:  "Invalid upgrade window",
+				Message: err.Error(),
+			},
+		)
+		return nil, chartStatus, err
+	}
+	if conditions != nil {
+		pendingHash := configHash(job)
+		if chartStatus.PendingConfigHash != pendingHash {
+			c.recorder.Eventf(chart, corev1.EventTypeNormal, "UpgradePending", "Changes to HelmChart are pending until the upgrade window opens")
+		}
+		chartStatus.PendingConfigHash = pendingHash
+		chartStatus.Conditions = removeCondition(chartStatus.Conditions, v1.HelmChartWaiting)
+		chartStatus.Conditions = setConditions(chartStatus.Conditions, conditions...)
+		setReleaseStatus(chart, &chartStatus, job, release)
+		return nil, chartStatus, c.updateStatus(chart, chartStatus)
+	}
+
 	// update status
 	chartStatus.JobName = job.Name
-	chartStatus.Conditions = []v1.HelmChartCondition{
-		{
+	chartStatus.GitCommit = gitCommit(job)
+	chartStatus.ResolvedVersion = resolvedVersion(chart, job)
+	chartStatus.PendingConfigHash = ""
+	chartStatus.Conditions = removeCondition(chartStatus.Conditions, v1.HelmChartWaiting)
+	chartStatus.Conditions = removeCondition(chartStatus.Conditions, v1.HelmChartPending)
+	chartStatus.Conditions = removeCondition(chartStatus.Conditions, v1.HelmChartHealthy)
+	chartStatus.Conditions = removeCondition(chartStatus.Conditions, v1.HelmChartTested)
+	chartStatus.Conditions = setConditions(chartStatus.Conditions,
+		v1.HelmChartCondition{
 			Type:    v1.HelmChartJobCreated,
 			Status:  corev1.ConditionTrue,
 			Reason:  "Job created",
 			Message: fmt.Sprintf("Applying HelmChart using Job %s/%s", chart.Namespace, jobName(chart)),
 		},
-		{
+		v1.HelmChartCondition{
 			Type:   v1.HelmChartFailed,
 			Status: corev1.ConditionFalse,
 		},
+		v1.HelmChartCondition{
+			Type:    v1.HelmChartReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  "Applying",
+			Message: fmt.Sprintf("Waiting for Job %s/%s to deploy the release", job.Namespace, job.Name),
+		},
+	)
+
+	if chart.Spec.DryRun && chart.Spec.RollbackTo == nil {
+		chartStatus.Conditions = setConditions(chartStatus.Conditions, v1.HelmChartCondition{
+			Type:    v1.HelmChartDryRun,
+			Status:  corev1.ConditionFalse,
+			Reason:  "Rendering",
+			Message: fmt.Sprintf("Waiting for Job %s/%s to render the chart", job.Namespace, job.Name),
+		})
 	}
 
 	// Suspend the current job before apply attempts to delete and recreate it.
@@ -409,9 +875,14 @@ func (c *Controller) OnChange(chart *v1.HelmChart, chartStatus v1.HelmChartStatu
 	// we don't care about whether or not this succeeds.
 	_ = c.setJobSuspended(chart, true)
 
-	// emit an event to indicate that this Helm chart is being applied
+	// emit an event to indicate that this Helm chart is being applied or rolled back
 	annotations := map[string]string{KeyConfigHash: job.Spec.Template.ObjectMeta.Annotations[KeyConfigHash]}
-	c.recorder.AnnotatedEventf(chart, annotations, corev1.EventTypeNormal, "ApplyJob", "Applying HelmChart from %s using Job %s/%s ", chartSource(chart), job.Namespace, job.Name)
+	if chart.Spec.RollbackTo != nil {
+		chartStatus.RolledBackRevision = rollbackRevision(chart, release)
+		c.recorder.AnnotatedEventf(chart, annotations, corev1.EventTypeNormal, "RollbackJob", "Rolling back HelmChart release to revision %d using Job %s/%s", chartStatus.RolledBackRevision, job.Namespace, job.Name)
+	} else {
+		c.recorder.AnnotatedEventf(chart, annotations, corev1.EventTypeNormal, "ApplyJob", "Applying HelmChart from %s using Job %s/%s ", chartSource(chart), job.Namespace, job.Name)
+	}
 
 	return append(objs, job), chartStatus, nil
 }
@@ -423,6 +894,11 @@ func (c *Controller) OnRemove(key string, chart *v1.HelmChart) (*v1.HelmChart, e
 		return nil, nil
 	}
 
+	c.gitCharts.Delete(chart.Namespace + "/" + chart.Name)
+	c.chartVersions.Delete(chart.Namespace + "/" + chart.Name)
+	c.releaseSummaries.Delete(chart.Namespace + "/" + chart.Name)
+	c.releaseInfos.Delete(chart.Namespace + "/" + chart.Name)
+
 	switch chart.Spec.HelmVersion {
 	case "", "v3":
 	default:
@@ -443,8 +919,12 @@ func (c *Controller) OnRemove(key string, chart *v1.HelmChart) (*v1.HelmChart, e
 	if c.jobComplete(chart) {
 		// uninstall job has successfully finished!
 		c.recorder.Eventf(chart, corev1.EventTypeNormal, "RemoveJob", "Uninstalled HelmChart using Job %s/%s, removing resources", chart.Namespace, jobName(chart))
+		c.notifier.Notify(chart, notify.EventUninstalled, jobName(chart), "Uninstalled HelmChart")
 
 		// note: an empty apply removes all resources owned by this chart
+		if err := c.applyReferences(chart); err != nil {
+			return nil, err
+		}
 		err := generic.ConfigureApplyForObject(c.apply, chart, &generic.GeneratingHandlerOptions{
 			AllowClusterScoped: true,
 		}).
@@ -458,8 +938,17 @@ func (c *Controller) OnRemove(key string, chart *v1.HelmChart) (*v1.HelmChart, e
 		return nil, nil
 	}
 
+	// hold off on creating the uninstall job until the upgrade window opens, if deletion is deferred.
+	if chart.Status.JobName != jobName(chart) {
+		if held, err := c.deletionHeld(chart); err != nil {
+			return nil, err
+		} else if held {
+			return chart, generic.ErrSkip
+		}
+	}
+
 	// getJobAndRelatedResources will return ErrSkip if no changes are necessary for the job
-	job, objs, err := c.getJobAndRelatedResources(chart)
+	job, objs, _, err := c.getJobAndRelatedResources(chart)
 	if err != nil {
 		return nil, err
 	}
@@ -472,6 +961,7 @@ func (c *Controller) OnRemove(key string, chart *v1.HelmChart) (*v1.HelmChart, e
 	c.recorder.Eventf(chart, corev1.EventTypeNormal, "RemoveJob", "Uninstalling HelmChart using Job %s/%s ", job.Namespace, job.Name)
 
 	if chart.Status.JobName != job.Name {
+		c.notifier.Notify(chart, notify.EventUninstalling, job.Name, "Uninstalling HelmChart")
 		chartCopy := chart.DeepCopy()
 		chartCopy.Status.JobName = job.Name
 		chart, err = c.helms.UpdateStatus(chartCopy)
@@ -507,6 +997,20 @@ func (c *Controller) OnRemove(key string, chart *v1.HelmChart) (*v1.HelmChart, e
 	return chart, generic.ErrSkip
 }
 
+// updateStatus writes the provided status to the chart if it has changed, and returns ErrSkip.
+// The generating handler discards any status returned alongside an error, so the status must
+// be written directly when skipping apply of the job and related resources.
+func (c *Controller) updateStatus(chart *v1.HelmChart, chartStatus v1.HelmChartStatus) error {
+	if !equality.Semantic.DeepEqual(chart.Status, chartStatus) {
+		chartCopy := chart.DeepCopy()
+		chartCopy.Status = chartStatus
+		if _, err := c.helms.UpdateStatus(chartCopy); err != nil {
+			return fmt.Errorf("unable to update status of helm chart: %w", err)
+		}
+	}
+	return generic.ErrSkip
+}
+
 func (c *Controller) shouldManage(chart *v1.HelmChart) (bool, error) {
 	if chart == nil {
 		return false, nil
@@ -515,7 +1019,7 @@ func (c *Controller) shouldManage(chart *v1.HelmChart) (bool, error) {
 		// do nothing if it's not in the namespace this controller was registered with
 		return false, nil
 	}
-	if chart.Spec.Chart == "" && chart.Spec.ChartContent == "" {
+	if chart.Spec.Chart == "" && chart.Spec.ChartContent == "" && chart.Spec.Git == nil {
 		return false, nil
 	}
 	if chart.Annotations != nil {
@@ -542,31 +1046,52 @@ func (c *Controller) shouldManage(chart *v1.HelmChart) (bool, error) {
 	return false, err
 }
 
-func (c *Controller) getJobAndRelatedResources(chart *v1.HelmChart) (*batch.Job, []runtime.Object, error) {
-	// set default for failure policy
-	failurePolicy := v1.FailurePolicyReinstall
-	if chart.Spec.FailurePolicy != "" {
-		failurePolicy = chart.Spec.FailurePolicy
+// getJobAndRelatedResources returns the job and related resources for the chart, along with
+// info on the latest release. ErrSkip is returned if no changes are necessary for the job.
+func (c *Controller) getJobAndRelatedResources(chart *v1.HelmChart) (*batch.Job, []runtime.Object, release, error) {
+	if chart.Spec.RBACScope == v1.RBACScopeNamespaced && chart.Spec.ServiceAccount == "" && !c.namespacedRBAC {
+		return nil, nil, release{}, errors.New("namespaced RBAC scope is not supported, as the controller does not manage RoleBindings")
 	}
-
-	// set default for server-side apply (SSA)
-	serverSide := v1.ServerSideAuto
-	if chart.Spec.ServerSide != "" {
-		serverSide = chart.Spec.ServerSide
+	if chart.DeletionTimestamp == nil && chart.Spec.RollbackTo != nil {
+		return c.getRollbackJobAndRelatedResources(chart)
 	}
 
-	// set default for SSA force-conflicts
-	forceConflicts := chart.Spec.ForceConflicts
+	// package the chart from git if the chart is being installed or upgraded from a git repository,
+	// otherwise resolve the newest version matching the version range, if a range is specified,
+	// and use the cached chart archive, if archive caching is enabled.
+	var commit, archive string
+	if chart.DeletionTimestamp == nil {
+		var err error
+		if chart.Spec.Git != nil {
+			if chart, commit, err = c.resolveGitChart(chart); err != nil {
+				return nil, nil, release{}, fmt.Errorf("failed to retrieve chart from git: %w", err)
+			}
+		} else {
+			if chart, _, err = c.resolveChartVersion(chart); err != nil {
+				return nil, nil, release{}, err
+			}
+			if chart, archive, err = c.resolveChartArchive(chart); err != nil {
+				return nil, nil, release{}, err
+			}
+		}
+	}
 
-	// override default backOffLimit if specified
-	backOffLimit := defaultBackOffLimit
-	if chart.Spec.BackOffLimit != nil {
-		backOffLimit = chart.Spec.BackOffLimit
+	// copy Secrets and ConfigMaps referenced from other namespaces into the chart namespace
+	chart, referencedObjects, err := c.resolveReferences(chart)
+	if err != nil {
+		return nil, nil, release{}, fmt.Errorf("failed to resolve references: %w", err)
 	}
 
 	// get the default job and configmaps
-	objects := []metav1.Object{}
+	var configs []*v1.HelmChartConfig
+	objects := referencedObjects
 	job, valuesSecret, contentConfigMap := job(chart, c.apiServerPort)
+	if commit != "" {
+		setGitCommit(job, commit)
+	}
+	if archive != "" {
+		contentConfigMap.Annotations = map[string]string{KeyChartArchive: archive}
+	}
 
 	if chart.DeletionTimestamp == nil {
 		// only need content and values secrets if the chart is being installed or upgraded
@@ -581,31 +1106,26 @@ func (c *Controller) getJobAndRelatedResources(chart *v1.HelmChart) (*batch.Job,
 			}
 		}
 
-		// check if a HelmChartConfig is registered for this Helm chart
-		config, err := c.confCache.Get(chart.Namespace, chart.Name)
-		if err != nil && !apierrors.IsNotFound(err) {
-			return nil, nil, err
-		}
-		if config != nil {
-			// Merge the values into the HelmChart's values
-			valuesSecretAddConfig(job, valuesSecret, config)
-
-			// Override the failure policy to what is provided in the HelmChartConfig
-			if config.Spec.FailurePolicy != "" {
-				failurePolicy = config.Spec.FailurePolicy
+		// make sure that changes to HelmChart ValuesConfigMaps trigger change to hash
+		for _, configMap := range chart.Spec.ValuesConfigMaps {
+			if !configMap.IgnoreUpdates {
+				if cm, err := c.configMapCache.Get(chart.Namespace, configMap.Name); err == nil {
+					objects = append(objects, cm)
+				}
 			}
+		}
 
-			// Override the server-side apply setting to what is provided in the HelmChartConfig
-			if config.Spec.ServerSide != "" {
-				serverSide = config.Spec.ServerSide
-			}
+		// check if any HelmChartConfigs apply to this Helm chart
+		configs, err = c.getChartConfigs(chart)
+		if err != nil {
+			return nil, nil, release{}, err
+		}
 
-			// Override the force-conflict setting to what is provided in the HelmChartConfig
-			if config.Spec.ForceConflicts != nil {
-				forceConflicts = *config.Spec.ForceConflicts
+		// make sure that changes to HelmChartConfig ValuesSecrets triger change to hash
+		for _, config := range configs {
+			if config.Namespace != chart.Namespace {
+				continue
 			}
-
-			// make sure that changes to HelmChart ValuesSecrets triger change to hash
 			for _, secret := range config.Spec.ValuesSecrets {
 				if !secret.IgnoreUpdates && secret.Name != "chart-values-"+config.Name {
 					if s, err := c.secretCache.Get(chart.Namespace, secret.Name); err == nil {
@@ -613,27 +1133,29 @@ func (c *Controller) getJobAndRelatedResources(chart *v1.HelmChart) (*batch.Job,
 					}
 				}
 			}
+			for _, configMap := range config.Spec.ValuesConfigMaps {
+				if !configMap.IgnoreUpdates {
+					if cm, err := c.configMapCache.Get(chart.Namespace, configMap.Name); err == nil {
+						objects = append(objects, cm)
+					}
+				}
+			}
 		}
 	}
 
-	// set the failure policy and add additional annotations to the job
-	// note: the purpose of the additional annotation is to cause the job to be destroyed
-	// and recreated if the hash of the HelmChartConfig changes while it is being processed
-	setFailurePolicy(job, failurePolicy)
-	setServerSide(job, serverSide)
-	setForceConflicts(job, forceConflicts)
-	setBackOffLimit(job, backOffLimit)
+	// merge the HelmChartConfigs into the job and values secret, and set the job policies
+	configureJob(chart, job, valuesSecret, configs)
+	if errs := config.ValidateResources(&job.Spec.Template.Spec.Containers[0].Resources, field.NewPath("jobResources")); len(errs) > 0 {
+		return nil, nil, release{}, fmt.Errorf("%w: %w", errInvalidJobResources, errs.ToAggregate())
+	}
 	hashObjects(job, objects...)
 
-	_, configHash, _ := strings.Cut(job.Spec.Template.ObjectMeta.Annotations[KeyConfigHash], "=")
-	if len(configHash) > 63 { // max label value
-		configHash = configHash[:63]
-	}
+	configHash := configHash(job)
 
 	// get current release info
 	release, err := c.getChartRelease(chart)
 	if err != nil && !apierrors.IsNotFound(err) {
-		return nil, nil, fmt.Errorf("failed to get latest chart release revision: %w", err)
+		return nil, nil, release, fmt.Errorf("failed to get latest chart release revision: %w", err)
 	}
 	c.logger.V(1).Info("Resolved latest chart release",
 		"chart.name", fmt.Sprintf("%s/%s", chart.Namespace, chart.Name),
@@ -642,8 +1164,14 @@ func (c *Controller) getJobAndRelatedResources(chart *v1.HelmChart) (*batch.Job,
 		"release.hash", release.hash,
 		"release.status", release.status,
 	)
+	metrics.SetRelease(chart.Namespace, chart.Name, release.revision, release.status)
 
-	if c.jobComplete(chart) {
+	if chart.DeletionTimestamp == nil && chart.Spec.DryRun {
+		// the dry-run job does not modify the release, so it only needs to run once for each config hash.
+		if oldJob, err := c.jobCache.Get(job.Namespace, job.Name); err == nil && !templateChanged(oldJob, job) {
+			return job, nil, release, generic.ErrSkip
+		}
+	} else if c.jobComplete(chart) {
 		if chart.DeletionTimestamp == nil {
 			// if the install or upgrade job is complete and the latest release's hash
 			// label matches the config hash, then there is nothing to be done.
@@ -651,7 +1179,7 @@ func (c *Controller) getJobAndRelatedResources(chart *v1.HelmChart) (*batch.Job,
 				c.logger.V(1).Info("Job is completed and deployed chart release has correct hash",
 					"chart.name", fmt.Sprintf("%s/%s", chart.Namespace, chart.Name),
 				)
-				return job, nil, generic.ErrSkip
+				return job, nil, release, generic.ErrSkip
 			}
 			c.logger.V(1).Info("Job is completed but release status or hash is incorrect",
 				"chart.name", fmt.Sprintf("%s/%s", chart.Namespace, chart.Name),
@@ -663,7 +1191,7 @@ func (c *Controller) getJobAndRelatedResources(chart *v1.HelmChart) (*batch.Job,
 				c.logger.V(1).Info("Job is completed and no release is present",
 					"chart.name", fmt.Sprintf("%s/%s", chart.Namespace, chart.Name),
 				)
-				return job, nil, generic.ErrSkip
+				return job, nil, release, generic.ErrSkip
 			}
 			c.logger.V(1).Info("Job is completed but release is still present",
 				"chart.name", fmt.Sprintf("%s/%s", chart.Namespace, chart.Name),
@@ -672,29 +1200,165 @@ func (c *Controller) getJobAndRelatedResources(chart *v1.HelmChart) (*batch.Job,
 	} else {
 		// job is not complete, do not modify the job if the template has not changed
 		if oldJob, err := c.jobCache.Get(job.Namespace, job.Name); err == nil && !templateChanged(oldJob, job) {
-			return job, nil, generic.ErrSkip
+			return job, nil, release, generic.ErrSkip
+		}
+	}
+
+	// inject the current chart release and hash into the job env; the helm job pod is
+	// expected to validate the hash, and not take action if it does not match. If the job
+	// pod does take action, the expected hash is added to the helm release resource as a label.
+	// Note that this is injected AFTER the hash is calculated, so that the hash does not
+	// include the revision or hash itself, which would cause an endless reconcile loop.
+	for i := range job.Spec.Template.Spec.Containers {
+		job.Spec.Template.Spec.Containers[i].Env = append(
+			job.Spec.Template.Spec.Containers[i].Env,
+			corev1.EnvVar{Name: "EXPECTED_RELEASE_REVISION", Value: strconv.FormatInt(release.revision, 10)},
+			corev1.EnvVar{Name: "CONFIG_HASH", Value: configHash},
+		)
+	}
+
+	return job, append([]runtime.Object{
+		valuesSecret,
+		contentConfigMap,
+	}, jobRBAC(chart, c.jobClusterRole)...), release, nil
+}
+
+// getRollbackJobAndRelatedResources returns the rollback job and related resources for the chart,
+// along with info on the latest release. ErrSkip is returned if no changes are necessary for the job.
+// The chart configuration is not applied while rolling back, so the job is only replaced if the
+// target revision or job settings change.
+func (c *Controller) getRollbackJobAndRelatedResources(chart *v1.HelmChart) (*batch.Job, []runtime.Object, release, error) {
+	release, err := c.getChartRelease(chart)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, nil, release, fmt.Errorf("failed to get latest chart release revision: %w", err)
+	}
+	metrics.SetRelease(chart.Namespace, chart.Name, release.revision, release.status)
+
+	revision := rollbackRevision(chart, release)
+	if revision == 0 {
+		return nil, nil, release, errors.New("no previously deployed release revision is available to roll back to")
+	}
+	c.logger.V(1).Info("Resolved chart rollback revision",
+		"chart.name", fmt.Sprintf("%s/%s", chart.Namespace, chart.Name),
+		"release.revision", release.revision,
+		"release.status", release.status,
+		"rollback.revision", revision,
+	)
+
+	backOffLimit := defaultBackOffLimit
+	if chart.Spec.BackOffLimit != nil {
+		backOffLimit = chart.Spec.BackOffLimit
+	}
+
+	// copy Secrets and ConfigMaps referenced from other namespaces into the chart namespace
+	chart, _, err = c.resolveReferences(chart)
+	if err != nil {
+		return nil, nil, release, fmt.Errorf("failed to resolve references: %w", err)
+	}
+
+	job, valuesSecret, contentConfigMap := job(chart, c.apiServerPort)
+	setRollback(job, chart, revision)
+	setBackOffLimit(job, backOffLimit)
+	hashObjects(job)
+
+	// do not modify the job if the template has not changed; the rollback only needs to run once.
+	if oldJob, err := c.jobCache.Get(job.Namespace, job.Name); err == nil && !templateChanged(oldJob, job) {
+		return job, nil, release, generic.ErrSkip
+	}
+
+	return job, append([]runtime.Object{
+		valuesSecret,
+		contentConfigMap,
+	}, jobRBAC(chart, c.jobClusterRole)...), release, nil
+}
+
+// checkDependencies returns the conditions that should be set on the chart if
+// it is blocked by its dependencies, or nil if the chart is free to proceed.
+// Dependency cycles are reported as a failure; dependencies that have not yet
+// been deployed are reported as waiting.
+func (c *Controller) checkDependencies(chart *v1.HelmChart) []v1.HelmChartCondition {
+	if len(chart.Spec.DependsOn) == 0 {
+		return nil
+	}
+
+	if cycle := dependencyCycle(chart, c.helmCache.Get); len(cycle) > 0 {
+		message := fmt.Sprintf("Dependency cycle detected: %s", strings.Join(cycle, " -> "))
+		c.recorder.Event(chart, corev1.EventTypeWarning, "DependencyCycle", message)
+		return []v1.HelmChartCondition{
+			{
+				Type:   v1.HelmChartJobCreated,
+				Status: corev1.ConditionFalse,
+			},
+			{
+				Type:    v1.HelmChartFailed,
+				Status:  corev1.ConditionTrue,
+				Reason:  "Dependency cycle",
+				Message: message,
+			},
 		}
 	}
 
-	// inject the current chart release and hash into the job env; the helm job pod is
-	// expected to validate the hash, and not take action if it does not match. If the job
-	// pod does take action, the expected hash is added to the helm release resource as a label.
-	// Note that this is injected AFTER the hash is calculated, so that the hash does not
-	// include the revision or hash itself, which would cause an endless reconcile loop.
-	for i := range job.Spec.Template.Spec.Containers {
-		job.Spec.Template.Spec.Containers[i].Env = append(
-			job.Spec.Template.Spec.Containers[i].Env,
-			corev1.EnvVar{Name: "EXPECTED_RELEASE_REVISION", Value: strconv.FormatInt(release.revision, 10)},
-			corev1.EnvVar{Name: "CONFIG_HASH", Value: configHash},
+	for _, ref := range chart.Spec.DependsOn {
+		namespace := dependencyNamespace(chart, ref)
+		ready, reason := c.dependencyReady(namespace, ref.Name)
+		if ready {
+			continue
+		}
+		c.logger.V(1).Info("Waiting for dependency",
+			"chart.name", fmt.Sprintf("%s/%s", chart.Namespace, chart.Name),
+			"dependency.name", fmt.Sprintf("%s/%s", namespace, ref.Name),
+			"reason", reason,
 		)
+		return []v1.HelmChartCondition{
+			{
+				Type:   v1.HelmChartJobCreated,
+				Status: corev1.ConditionFalse,
+			},
+			{
+				Type:   v1.HelmChartFailed,
+				Status: corev1.ConditionFalse,
+			},
+			{
+				Type:    v1.HelmChartWaiting,
+				Status:  corev1.ConditionTrue,
+				Reason:  "Waiting for dependency",
+				Message: fmt.Sprintf("Waiting for HelmChart %s/%s: %s", namespace, ref.Name, reason),
+			},
+		}
 	}
 
-	return job, []runtime.Object{
-		valuesSecret,
-		contentConfigMap,
-		serviceAccount(chart),
-		roleBinding(chart, c.jobClusterRole),
-	}, nil
+	return nil
+}
+
+// dependencyReady returns true if the referenced HelmChart has a deployed release
+// whose config hash matches that of the chart's current job. If the dependency is
+// not ready, a brief description of the reason is also returned.
+func (c *Controller) dependencyReady(namespace, name string) (bool, string) {
+	dep, err := c.helmCache.Get(namespace, name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, "HelmChart not found"
+		}
+		return false, err.Error()
+	}
+	if dep.DeletionTimestamp != nil {
+		return false, "HelmChart is being deleted"
+	}
+	job, err := c.jobCache.Get(dep.Namespace, jobName(dep))
+	if err != nil {
+		return false, "Job has not been created"
+	}
+	release, err := c.getChartRelease(dep)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return false, err.Error()
+	}
+	if release.status != "deployed" {
+		return false, "release has not been deployed"
+	}
+	if release.hash != configHash(job) {
+		return false, "deployed release does not match current configuration"
+	}
+	return true, ""
 }
 
 func (c *Controller) getChartRelease(chart *v1.HelmChart) (release, error) {
@@ -709,7 +1373,11 @@ func (c *Controller) getChartRelease(chart *v1.HelmChart) (release, error) {
 		for i := range cmList.Items {
 			objects[i] = cmList.Items[i].ObjectMeta
 		}
-		return latestRelease(objects)
+		rel, err := latestRelease(objects)
+		data := func(i int) string { return cmList.Items[i].Data["release"] }
+		rel.history = c.releaseHistory(chart, objects, data)
+		rel.info = c.latestReleaseInfo(chart, objects, data)
+		return rel, err
 	}
 
 	fs := fields.OneTermEqualSelector("type", ReleaseType)
@@ -721,7 +1389,11 @@ func (c *Controller) getChartRelease(chart *v1.HelmChart) (release, error) {
 	for i := range secretList.Items {
 		objects[i] = secretList.Items[i].ObjectMeta
 	}
-	return latestRelease(objects)
+	rel, err := latestRelease(objects)
+	data := func(i int) string { return string(secretList.Items[i].Data["release"]) }
+	rel.history = c.releaseHistory(chart, objects, data)
+	rel.info = c.latestReleaseInfo(chart, objects, data)
+	return rel, err
 }
 
 // jobComplete returns true if the job controller has added a True Completed
@@ -737,19 +1409,6 @@ func (c *Controller) jobComplete(chart *v1.HelmChart) bool {
 	return false
 }
 
-// jobFailed returns true if the job controller has added a True Failed
-// condition to the job for the given chart.
-func (c *Controller) jobFailed(chart *v1.HelmChart) bool {
-	if job, _ := c.jobs.Cache().Get(chart.Namespace, jobName(chart)); job != nil {
-		for _, condition := range job.Status.Conditions {
-			if condition.Type == batch.JobFailed {
-				return condition.Status == corev1.ConditionTrue
-			}
-		}
-	}
-	return false
-}
-
 // jobReady returns true if the job is suspended, has never been started, and
 // the Job controller has added a True Suspended condition to the job for the
 // given chart. The addition of this condition indicates that the controller
@@ -788,10 +1447,146 @@ func (c *Controller) setJobSuspended(chart *v1.HelmChart, suspend bool) error {
 	return err
 }
 
+// setReleaseStatus updates the status with info from the latest release, and sets the
+// Ready condition based on whether or not the latest release has been deployed with the
+// config hash of the current job.
+func setReleaseStatus(chart *v1.HelmChart, chartStatus *v1.HelmChartStatus, job *batch.Job, rel release) {
+	chartStatus.ReleaseRevision = rel.revision
+	chartStatus.ConfigHash = rel.hash
+	chartStatus.ReleaseHistory = rel.history
+	chartStatus.Release = rel.info
+
+	switch {
+	case rel.revision == 0:
+		chartStatus.Conditions = setConditions(chartStatus.Conditions, v1.HelmChartCondition{
+			Type:    v1.HelmChartReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  "Not installed",
+			Message: "No release has been installed",
+		})
+	case rel.status != "deployed":
+		chartStatus.Conditions = setConditions(chartStatus.Conditions, v1.HelmChartCondition{
+			Type:    v1.HelmChartReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  "Release not deployed",
+			Message: fmt.Sprintf("Release revision %d has status %s", rel.revision, rel.status),
+		})
+	case rel.hash != configHash(job):
+		chartStatus.Conditions = setConditions(chartStatus.Conditions, v1.HelmChartCondition{
+			Type:    v1.HelmChartReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  "Release outdated",
+			Message: fmt.Sprintf("Release revision %d does not match the current configuration", rel.revision),
+		})
+	default:
+		chartStatus.ChartVersion = chart.Spec.Version
+		if chartStatus.ResolvedVersion != "" {
+			chartStatus.ChartVersion = chartStatus.ResolvedVersion
+		}
+		if chartStatus.ChartVersion == "" && rel.info != nil {
+			chartStatus.ChartVersion = rel.info.ChartVersion
+		}
+		chartStatus.Conditions = setConditions(chartStatus.Conditions, v1.HelmChartCondition{
+			Type:    v1.HelmChartReady,
+			Status:  corev1.ConditionTrue,
+			Reason:  "Deployed",
+			Message: fmt.Sprintf("Release revision %d has been deployed", rel.revision),
+		})
+	}
+}
+
+// setRollbackStatus sets the Ready condition for a chart that is being rolled back, based on
+// whether or not the rollback job has completed and the resulting release has been deployed.
+func setRollbackStatus(chartStatus *v1.HelmChartStatus, rel release, complete bool) {
+	if complete && rel.status == "deployed" {
+		chartStatus.Conditions = setConditions(chartStatus.Conditions, v1.HelmChartCondition{
+			Type:    v1.HelmChartReady,
+			Status:  corev1.ConditionTrue,
+			Reason:  "Rolled back",
+			Message: fmt.Sprintf("Release rolled back to revision %d as revision %d", chartStatus.RolledBackRevision, rel.revision),
+		})
+		return
+	}
+	chartStatus.Conditions = setConditions(chartStatus.Conditions, v1.HelmChartCondition{
+		Type:    v1.HelmChartReady,
+		Status:  corev1.ConditionFalse,
+		Reason:  "Rolling back",
+		Message: fmt.Sprintf("Waiting for release to be rolled back to revision %d", chartStatus.RolledBackRevision),
+	})
+}
+
+// suspended returns true if the status has a True Suspended condition.
+func suspended(chartStatus v1.HelmChartStatus) bool {
+	for _, condition := range chartStatus.Conditions {
+		if condition.Type == v1.HelmChartSuspended {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// rolledBack returns true if the status has a True Ready condition set by a completed rollback.
+func rolledBack(chartStatus v1.HelmChartStatus) bool {
+	for _, condition := range chartStatus.Conditions {
+		if condition.Type == v1.HelmChartReady {
+			return condition.Status == corev1.ConditionTrue && condition.Reason == "Rolled back"
+		}
+	}
+	return false
+}
+
+// rollbackRevision returns the revision that the chart's release should be rolled back to.
+// If a revision is not specified, the revision previously recorded in the status is used, so
+// that the target does not change once the rollback itself has created a new release; failing
+// that, the last successfully deployed revision prior to the latest release is used.
+func rollbackRevision(chart *v1.HelmChart, rel release) int64 {
+	if revision := *chart.Spec.RollbackTo; revision != 0 {
+		return revision
+	}
+	if chart.Status.RolledBackRevision != 0 {
+		return chart.Status.RolledBackRevision
+	}
+	return rel.lastDeployedRevision
+}
+
+// setConditions returns a copy of the existing conditions, with the provided conditions added or
+// replacing any existing condition of the same type. The LastTransitionTime of existing conditions
+// is retained if the status has not changed.
+func setConditions(existing []v1.HelmChartCondition, conditions ...v1.HelmChartCondition) []v1.HelmChartCondition {
+	result := slices.Clone(existing)
+	for _, condition := range conditions {
+		i := slices.IndexFunc(result, func(c v1.HelmChartCondition) bool { return c.Type == condition.Type })
+		if i == -1 {
+			condition.LastTransitionTime = metav1.Now()
+			result = append(result, condition)
+			continue
+		}
+		if result[i].Status == condition.Status {
+			condition.LastTransitionTime = result[i].LastTransitionTime
+		} else {
+			condition.LastTransitionTime = metav1.Now()
+		}
+		result[i] = condition
+	}
+	return result
+}
+
+// removeCondition returns a copy of the existing conditions, without any condition of the provided type.
+func removeCondition(existing []v1.HelmChartCondition, conditionType v1.HelmChartConditionType) []v1.HelmChartCondition {
+	return slices.DeleteFunc(slices.Clone(existing), func(c v1.HelmChartCondition) bool { return c.Type == conditionType })
+}
+
 type release struct {
 	revision int64
 	hash     string
 	status   string
+	// lastDeployedRevision is the highest revision prior to the latest release
+	// that was successfully deployed, and is a candidate for rollback.
+	lastDeployedRevision int64
+	// history lists the most recent revisions of the release, newest first.
+	history []v1.HelmChartRelease
+	// info describes the chart installed by the latest release, if its record could be decoded.
+	info *v1.HelmChartReleaseInfo
 }
 
 // latestRelease returns info for the release with the highest version, from the provided list of objects.
@@ -804,14 +1599,106 @@ func latestRelease(objects []metav1.ObjectMeta) (release, error) {
 			rel.hash = obj.Labels[KeyConfigHash]
 		}
 	}
+	for _, obj := range objects {
+		switch obj.Labels["status"] {
+		case "deployed", "superseded":
+			if sv, err := strconv.ParseInt(obj.Labels["version"], 10, 64); err == nil && sv < rel.revision && sv > rel.lastDeployedRevision {
+				rel.lastDeployedRevision = sv
+			}
+		}
+	}
 	return rel, nil
 }
 
+// dependencyCycle walks the dependency graph of the provided chart, and returns the
+// path of the first cycle found that leads back to the chart, or nil if there is no cycle.
+// Dependencies that cannot be retrieved are treated as having no dependencies of their own.
+func dependencyCycle(chart *v1.HelmChart, get func(namespace, name string) (*v1.HelmChart, error)) []string {
+	root := chart.Namespace + "/" + chart.Name
+	visited := sets.New[string]()
+
+	var walk func(chart *v1.HelmChart, path []string) []string
+	walk = func(chart *v1.HelmChart, path []string) []string {
+		for _, ref := range chart.Spec.DependsOn {
+			namespace := dependencyNamespace(chart, ref)
+			key := namespace + "/" + ref.Name
+			if key == root {
+				return append(path, key)
+			}
+			if visited.Has(key) {
+				continue
+			}
+			visited.Insert(key)
+			dep, err := get(namespace, ref.Name)
+			if err != nil || dep == nil {
+				continue
+			}
+			if cycle := walk(dep, append(path, key)); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+
+	return walk(chart, []string{root})
+}
+
+// dependencyNamespace returns the namespace of the referenced chart,
+// defaulting to the namespace of the chart that holds the reference.
+func dependencyNamespace(chart *v1.HelmChart, ref v1.HelmChartReference) string {
+	if ref.Namespace != "" {
+		return ref.Namespace
+	}
+	return chart.Namespace
+}
+
+func chartByDependency(chart *v1.HelmChart) ([]string, error) {
+	keys := sets.Set[string]{}
+	for _, ref := range chart.Spec.DependsOn {
+		keys.Insert(dependencyNamespace(chart, ref) + "." + ref.Name)
+	}
+	return keys.UnsortedList(), nil
+}
+
 func chartBySecret(chart *v1.HelmChart) ([]string, error) {
 	keys := sets.Set[string]{}
 	for _, secret := range chart.Spec.ValuesSecrets {
 		if !secret.IgnoreUpdates {
-			keys.Insert(chart.Namespace + "." + secret.Name)
+			keys.Insert(referenceNamespace(chart, secret.Namespace) + "." + secret.Name)
+		}
+	}
+	// copies of secrets in other namespaces are kept in sync with the referenced secret
+	if ref := chart.Spec.AuthSecret; ref != nil && referencesOtherNamespace(chart.Namespace, chart.Spec.AuthSecretNamespace) {
+		keys.Insert(chart.Spec.AuthSecretNamespace + "." + ref.Name)
+	}
+	if ref := chart.Spec.DockerRegistrySecret; ref != nil && referencesOtherNamespace(chart.Namespace, chart.Spec.DockerRegistrySecretNamespace) {
+		keys.Insert(chart.Spec.DockerRegistrySecretNamespace + "." + ref.Name)
+	}
+	if chart.Spec.Git != nil && chart.Spec.Git.AuthSecret != nil && chart.Spec.Git.AuthSecret.Name != "" {
+		keys.Insert(chart.Namespace + "." + chart.Spec.Git.AuthSecret.Name)
+	}
+	return keys.UnsortedList(), nil
+}
+
+func chartByConfigMap(chart *v1.HelmChart) ([]string, error) {
+	keys := sets.Set[string]{}
+	for _, configMap := range chart.Spec.ValuesConfigMaps {
+		if !configMap.IgnoreUpdates {
+			keys.Insert(referenceNamespace(chart, configMap.Namespace) + "." + configMap.Name)
+		}
+	}
+	// copies of ConfigMaps in other namespaces are kept in sync with the referenced ConfigMap
+	if ref := chart.Spec.RepoCAConfigMap; ref != nil && referencesOtherNamespace(chart.Namespace, chart.Spec.RepoCAConfigMapNamespace) {
+		keys.Insert(chart.Spec.RepoCAConfigMapNamespace + "." + ref.Name)
+	}
+	return keys.UnsortedList(), nil
+}
+
+func chartConfigByConfigMap(conf *v1.HelmChartConfig) ([]string, error) {
+	keys := sets.Set[string]{}
+	for _, configMap := range conf.Spec.ValuesConfigMaps {
+		if !configMap.IgnoreUpdates {
+			keys.Insert(conf.Namespace + "." + configMap.Name)
 		}
 	}
 	return keys.UnsortedList(), nil
@@ -833,11 +1720,6 @@ func job(chart *v1.HelmChart, apiServerPort string) (*batch.Job, *corev1.Secret,
 		jobImage = DefaultJobImage
 	}
 
-	targetNamespace := chart.Namespace
-	if len(chart.Spec.TargetNamespace) != 0 {
-		targetNamespace = chart.Spec.TargetNamespace
-	}
-
 	chartName := chart.Spec.Chart
 	if chart.Spec.Repo != "" {
 		chartName = chart.Name + "/" + chart.Spec.Chart
@@ -906,7 +1788,7 @@ func job(chart *v1.HelmChart, apiServerPort string) (*batch.Job, *corev1.Secret,
 								},
 								{
 									Name:  "TARGET_NAMESPACE",
-									Value: targetNamespace,
+									Value: targetNamespace(chart),
 								},
 								{
 									Name:  "AUTH_PASS_CREDENTIALS",
@@ -942,7 +1824,7 @@ func job(chart *v1.HelmChart, apiServerPort string) (*batch.Job, *corev1.Secret,
 							},
 						},
 					},
-					ServiceAccountName: fmt.Sprintf("helm-%s", chart.Name),
+					ServiceAccountName: jobServiceAccountName(chart),
 					SecurityContext:    podSecurityContext,
 					PriorityClassName:  defaultPriorityClassName,
 					Volumes: []corev1.Volume{
@@ -1044,9 +1926,10 @@ func job(chart *v1.HelmChart, apiServerPort string) (*batch.Job, *corev1.Secret,
 	setAuthSecret(job, chart)
 	setDockerRegistrySecret(job, chart)
 	setRepoCAConfigMap(job, chart)
-	setPodResources(job)
+	setPodResources(job, chart.Spec.JobResources)
 	setSecurityContext(job, chart)
 	setTolerations(job)
+	setPlacement(job, chart.Spec.NodeSelector, chart.Spec.Affinity, chart.Spec.Tolerations, chart.Spec.PriorityClassName, chart.Spec.TopologySpreadConstraints)
 
 	if chart.DeletionTimestamp == nil {
 		// only need content and values secrets if the chart is being installed or upgraded
@@ -1085,14 +1968,92 @@ func valuesSecret(chart *v1.HelmChart) *corev1.Secret {
 	return secret
 }
 
-func valuesSecretAddConfig(job *batch.Job, secret *corev1.Secret, config *v1.HelmChartConfig) {
+// configureJob merges the values from the HelmChartConfigs into the values secret, in order, and sets the
+// failure policy, server-side apply, backoff limit, and pod placement of the job from the chart, as
+// overridden by the configs.
+func configureJob(chart *v1.HelmChart, job *batch.Job, valuesSecret *corev1.Secret, configs []*v1.HelmChartConfig) {
+	// set default for failure policy
+	failurePolicy := v1.FailurePolicyReinstall
+	if chart.Spec.FailurePolicy != "" {
+		failurePolicy = chart.Spec.FailurePolicy
+	}
+
+	// set default for server-side apply (SSA)
+	serverSide := v1.ServerSideAuto
+	if chart.Spec.ServerSide != "" {
+		serverSide = chart.Spec.ServerSide
+	}
+
+	// set default for SSA force-conflicts
+	forceConflicts := chart.Spec.ForceConflicts
+
+	// override default backOffLimit if specified
+	backOffLimit := defaultBackOffLimit
+	if chart.Spec.BackOffLimit != nil {
+		backOffLimit = chart.Spec.BackOffLimit
+	}
+
+	item := 0
+	for i, config := range configs {
+		// Merge the values into the HelmChart's values
+		item = valuesSecretAddConfig(job, valuesSecret, config, i, item)
+
+		// Override the failure policy to what is provided in the HelmChartConfig
+		if config.Spec.FailurePolicy != "" {
+			failurePolicy = config.Spec.FailurePolicy
+		}
+
+		// Override the server-side apply setting to what is provided in the HelmChartConfig
+		if config.Spec.ServerSide != "" {
+			serverSide = config.Spec.ServerSide
+		}
+
+		// Override the force-conflict setting to what is provided in the HelmChartConfig
+		if config.Spec.ForceConflicts != nil {
+			forceConflicts = *config.Spec.ForceConflicts
+		}
+
+		// Merge the job pod placement settings provided in the HelmChartConfig
+		setPlacement(job, config.Spec.NodeSelector, config.Spec.Affinity, config.Spec.Tolerations, config.Spec.PriorityClassName, config.Spec.TopologySpreadConstraints)
+
+		// Override the job resources to what is provided in the HelmChartConfig
+		if config.Spec.JobResources != nil {
+			setPodResources(job, config.Spec.JobResources)
+		}
+	}
+
+	// set the failure policy and add additional annotations to the job
+	// note: the purpose of the additional annotation is to cause the job to be destroyed
+	// and recreated if the hash of the HelmChartConfig changes while it is being processed.
+	// The dry-run job runs helm directly, and has no failure policy as it never modifies the release.
+	if chart.DeletionTimestamp == nil && chart.Spec.DryRun && chart.Spec.RollbackTo == nil {
+		setDryRun(job, chart)
+	} else {
+		setFailurePolicy(job, failurePolicy)
+	}
+	setServerSide(job, serverSide)
+	setForceConflicts(job, forceConflicts)
+	setBackOffLimit(job, backOffLimit)
+}
+
+// valuesSecretAddConfig adds the values from the HelmChartConfig at the given index to the values secret and volume.
+// Values files are numbered from the given item, so that files from multiple configs are applied in order; the next
+// unused item number is returned. ValuesSecrets and ValuesConfigMaps can only be projected if the config is in the same
+// namespace as the secret.
+func valuesSecretAddConfig(job *batch.Job, secret *corev1.Secret, config *v1.HelmChartConfig, index, item int) int {
+	valuesContentKey, valuesKey := "HelmChartConfigValuesContent", "HelmChartConfigValues"
+	if index > 0 {
+		valuesContentKey = fmt.Sprintf("%s-%d", valuesContentKey, index)
+		valuesKey = fmt.Sprintf("%s-%d", valuesKey, index)
+	}
 	if config.Spec.ValuesContent != "" {
-		secret.Data["HelmChartConfigValuesContent"] = []byte(config.Spec.ValuesContent)
+		secret.Data[valuesContentKey] = []byte(config.Spec.ValuesContent)
 	}
 	if !extjson.IsEmpty(config.Spec.Values) {
-		secret.Data["HelmChartConfigValues"] = []byte(extjson.TryToYAML(config.Spec.Values))
+		secret.Data[valuesKey] = []byte(extjson.TryToYAML(config.Spec.Values))
 	}
 
+	items := item + 1
 	// modify projected volumes to hold collected secret keys
 	for i := range job.Spec.Template.Spec.Volumes {
 		if job.Spec.Template.Spec.Volumes[i].Name != "values" {
@@ -1103,17 +2064,19 @@ func valuesSecretAddConfig(job *batch.Job, secret *corev1.Secret, config *v1.Hel
 		// the first source in this volume is always the managed secret for this HelmChart
 		// add item for HelmChartConfig ValuesContent
 		if config.Spec.ValuesContent != "" {
-			valuesVolume.Projected.Sources[0].Secret.Items = append(valuesVolume.Projected.Sources[0].Secret.Items, corev1.KeyToPath{Key: "HelmChartConfigValuesContent", Path: "values-1-000-HelmChartConfig-ValuesContent.yaml"})
+			valuesVolume.Projected.Sources[0].Secret.Items = append(valuesVolume.Projected.Sources[0].Secret.Items, corev1.KeyToPath{Key: valuesContentKey, Path: fmt.Sprintf("values-1-%03d-HelmChartConfig-ValuesContent.yaml", item)})
 		}
 		// add item for HelmChartConfig Values
 		if !extjson.IsEmpty(config.Spec.Values) {
-			valuesVolume.Projected.Sources[0].Secret.Items = append(valuesVolume.Projected.Sources[0].Secret.Items, corev1.KeyToPath{Key: "HelmChartConfigValues", Path: "values-1-001-HelmChartConfig-Values.yaml"})
+			valuesVolume.Projected.Sources[0].Secret.Items = append(valuesVolume.Projected.Sources[0].Secret.Items, corev1.KeyToPath{Key: valuesKey, Path: fmt.Sprintf("values-1-%03d-HelmChartConfig-Values.yaml", item+1)})
 		}
 
-		items := 1
+		if config.Namespace != secret.Namespace {
+			continue
+		}
 		// add projection and items for HelmChartConfig ValuesSecrets
 		for _, secret := range config.Spec.ValuesSecrets {
-			if len(secret.Keys) == 0 || secret.Name == "chart-values-"+config.Name {
+			if len(secret.Keys) == 0 || secret.Name == "chart-values-"+config.Name || referencesOtherNamespace(config.Namespace, secret.Namespace) {
 				continue
 			}
 			volumeProjection := corev1.VolumeProjection{
@@ -1130,10 +2093,88 @@ func valuesSecretAddConfig(job *batch.Job, secret *corev1.Secret, config *v1.Hel
 			}
 			valuesVolume.VolumeSource.Projected.Sources = append(valuesVolume.VolumeSource.Projected.Sources, volumeProjection)
 		}
+		// add projection and items for HelmChartConfig ValuesConfigMaps
+		for _, configMap := range config.Spec.ValuesConfigMaps {
+			if referencesOtherNamespace(config.Namespace, configMap.Namespace) {
+				continue
+			}
+			if volumeProjection := valuesConfigMapProjection(configMap, "values-1-%03d-HelmChartConfig-ValuesConfigMap.yaml", &items); volumeProjection != nil {
+				valuesVolume.VolumeSource.Projected.Sources = append(valuesVolume.VolumeSource.Projected.Sources, *volumeProjection)
+			}
+		}
+	}
+	return items + 1
+}
+
+// valuesConfigMapProjection returns a projection of the keys of the values ConfigMap, or nil if no keys are specified.
+// Items are numbered sequentially from the next item, using the path format.
+func valuesConfigMapProjection(configMap v1.ConfigMapSpec, pathFormat string, items *int) *corev1.VolumeProjection {
+	if len(configMap.Keys) == 0 {
+		return nil
+	}
+	volumeProjection := &corev1.VolumeProjection{
+		ConfigMap: &corev1.ConfigMapProjection{
+			Optional: ptr.To(configMap.IgnoreUpdates),
+			LocalObjectReference: corev1.LocalObjectReference{
+				Name: configMap.Name,
+			},
+		},
+	}
+	for _, key := range configMap.Keys {
+		*items++
+		volumeProjection.ConfigMap.Items = append(volumeProjection.ConfigMap.Items, corev1.KeyToPath{Key: key, Path: fmt.Sprintf(pathFormat, *items)})
+	}
+	return volumeProjection
+}
+
+// jobRBAC returns the ServiceAccount and role binding for the helm job,
+// or nothing if the job runs as an existing ServiceAccount.
+func jobRBAC(chart *v1.HelmChart, jobClusterRole string) []runtime.Object {
+	if chart.Spec.ServiceAccount != "" {
+		return nil
+	}
+	return []runtime.Object{
+		serviceAccount(chart),
+		roleBinding(chart, jobClusterRole),
 	}
 }
 
-func roleBinding(chart *v1.HelmChart, jobClusterRole string) *rbac.ClusterRoleBinding {
+// roleBinding returns the binding of the job role to the helm job ServiceAccount. A ClusterRoleBinding
+// is used by default; a RoleBinding in the target namespace is used if the RBAC scope is namespaced.
+func roleBinding(chart *v1.HelmChart, jobClusterRole string) runtime.Object {
+	roleRef := rbac.RoleRef{
+		Kind:     "ClusterRole",
+		APIGroup: "rbac.authorization.k8s.io",
+		Name:     jobClusterRole,
+	}
+	if role := chart.Spec.JobRole; role != nil {
+		roleRef.Name = role.Name
+		if role.Kind != "" {
+			roleRef.Kind = role.Kind
+		}
+	}
+	subjects := []rbac.Subject{
+		{
+			Name:      jobServiceAccountName(chart),
+			Kind:      "ServiceAccount",
+			Namespace: chart.Namespace,
+		},
+	}
+
+	if chart.Spec.RBACScope == v1.RBACScopeNamespaced {
+		return &rbac.RoleBinding{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "rbac.authorization.k8s.io/v1",
+				Kind:       "RoleBinding",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("helm-%s-%s", chart.Namespace, chart.Name),
+				Namespace: targetNamespace(chart),
+			},
+			RoleRef:  roleRef,
+			Subjects: subjects,
+		}
+	}
 	return &rbac.ClusterRoleBinding{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "rbac.authorization.k8s.io/v1",
@@ -1142,19 +2183,17 @@ func roleBinding(chart *v1.HelmChart, jobClusterRole string) *rbac.ClusterRoleBi
 		ObjectMeta: metav1.ObjectMeta{
 			Name: fmt.Sprintf("helm-%s-%s", chart.Namespace, chart.Name),
 		},
-		RoleRef: rbac.RoleRef{
-			Kind:     "ClusterRole",
-			APIGroup: "rbac.authorization.k8s.io",
-			Name:     jobClusterRole,
-		},
-		Subjects: []rbac.Subject{
-			{
-				Name:      fmt.Sprintf("helm-%s", chart.Name),
-				Kind:      "ServiceAccount",
-				Namespace: chart.Namespace,
-			},
-		},
+		RoleRef:  roleRef,
+		Subjects: subjects,
+	}
+}
+
+// jobServiceAccountName returns the name of the ServiceAccount that the helm job runs as.
+func jobServiceAccountName(chart *v1.HelmChart) string {
+	if chart.Spec.ServiceAccount != "" {
+		return chart.Spec.ServiceAccount
 	}
+	return fmt.Sprintf("helm-%s", chart.Name)
 }
 
 func serviceAccount(chart *v1.HelmChart) *corev1.ServiceAccount {
@@ -1164,7 +2203,7 @@ func serviceAccount(chart *v1.HelmChart) *corev1.ServiceAccount {
 			Kind:       "ServiceAccount",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("helm-%s", chart.Name),
+			Name:      jobServiceAccountName(chart),
 			Namespace: chart.Namespace,
 		},
 		AutomountServiceAccountToken: ptr.To(true),
@@ -1198,6 +2237,10 @@ func args(chart *v1.HelmChart) []string {
 		args = append(args, "--version", spec.Version)
 	}
 
+	if spec.DryRun {
+		args = append(args, "--dry-run")
+	}
+
 	for _, k := range keys(spec.Set) {
 		val := spec.Set[k]
 		if typedVal(val) {
@@ -1351,6 +2394,12 @@ func setValuesSecret(job *batch.Job, chart *v1.HelmChart) *corev1.Secret {
 		}
 		valuesVolume.VolumeSource.Projected.Sources = append(valuesVolume.VolumeSource.Projected.Sources, volumeProjection)
 	}
+	// add projection and items for HelmChart ValuesConfigMaps
+	for _, configMap := range chart.Spec.ValuesConfigMaps {
+		if volumeProjection := valuesConfigMapProjection(configMap, "values-0-%03d-HelmChart-ValuesConfigMap.yaml", &items); volumeProjection != nil {
+			valuesVolume.VolumeSource.Projected.Sources = append(valuesVolume.VolumeSource.Projected.Sources, *volumeProjection)
+		}
+	}
 
 	// add values volume and volume mount
 	job.Spec.Template.Spec.Volumes = append(job.Spec.Template.Spec.Volumes, valuesVolume)
@@ -1437,7 +2486,7 @@ func setRepoCAConfigMap(job *batch.Job, chart *v1.HelmChart) {
 			VolumeSource: corev1.VolumeSource{
 				ConfigMap: &corev1.ConfigMapVolumeSource{
 					DefaultMode:          ptr.To(int32(0644)),
-					LocalObjectReference: *cm,
+					LocalObjectReference: corev1.LocalObjectReference{Name: cm.Name},
 				},
 			},
 		})
@@ -1456,6 +2505,28 @@ func setFailurePolicy(job *batch.Job, failurePolicy v1.FailurePolicy) {
 	})
 }
 
+// jobFailurePolicy returns the failure policy of the job, as set by setFailurePolicy.
+func jobFailurePolicy(job *batch.Job) v1.FailurePolicy {
+	for _, env := range job.Spec.Template.Spec.Containers[0].Env {
+		if env.Name == "FAILURE_POLICY" {
+			return v1.FailurePolicy(env.Value)
+		}
+	}
+	return ""
+}
+
+// setRollback replaces the job's entrypoint with a helm rollback of the release to the specified revision.
+// The revision is also added to the pod template, so that the job is replaced if the revision changes.
+func setRollback(job *batch.Job, chart *v1.HelmChart, revision int64) {
+	args := []string{"rollback", chart.Name, strconv.FormatInt(revision, 10), "--namespace", targetNamespace(chart), "--wait"}
+	if chart.Spec.Timeout != nil {
+		args = append(args, "--timeout", chart.Spec.Timeout.Duration.String())
+	}
+	job.Spec.Template.Spec.Containers[0].Command = []string{"helm_v3"}
+	job.Spec.Template.Spec.Containers[0].Args = args
+	job.Spec.Template.ObjectMeta.Annotations[KeyRollbackRevision] = strconv.FormatInt(revision, 10)
+}
+
 func setServerSide(job *batch.Job, serverSide v1.ServerSide) {
 	job.Spec.Template.Spec.Containers[0].Env = append(job.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
 		Name:  "SERVER_SIDE",
@@ -1500,13 +2571,28 @@ func hashObjects(job *batch.Job, objs ...metav1.Object) {
 	job.Spec.Template.ObjectMeta.Annotations[KeyConfigHash] = fmt.Sprintf("SHA256=%X", hash.Sum(nil))
 }
 
+// configHash returns the config hash from the job's pod template annotation,
+// truncated to fit within the max length of a label value.
+func configHash(job *batch.Job) string {
+	_, hash, _ := strings.Cut(job.Spec.Template.ObjectMeta.Annotations[KeyConfigHash], "=")
+	if len(hash) > 63 { // max label value
+		hash = hash[:63]
+	}
+	return hash
+}
+
 func setBackOffLimit(job *batch.Job, backOffLimit *int32) {
 	job.Spec.BackoffLimit = backOffLimit
 }
 
-func setPodResources(job *batch.Job) {
-	if JobResources != nil {
-		job.Spec.Template.Spec.Containers[0].Resources = *JobResources.DeepCopy()
+// setPodResources sets the resources of the helm job container to the provided resources,
+// or to the resources configured on the controller if not set.
+func setPodResources(job *batch.Job, resources *corev1.ResourceRequirements) {
+	if resources == nil {
+		resources = JobResources
+	}
+	if resources != nil {
+		job.Spec.Template.Spec.Containers[0].Resources = *resources.DeepCopy()
 	}
 }
 
@@ -1526,6 +2612,29 @@ func setTolerations(job *batch.Job) {
 	}
 }
 
+// setPlacement merges the provided scheduling settings into the job pod spec. Node selector
+// labels are merged, and tolerations are appended; the other settings are replaced if set.
+func setPlacement(job *batch.Job, nodeSelector map[string]string, affinity *corev1.Affinity, tolerations []corev1.Toleration, priorityClassName string, topologySpreadConstraints []corev1.TopologySpreadConstraint) {
+	for k, v := range nodeSelector {
+		job.Spec.Template.Spec.NodeSelector[k] = v
+	}
+	if affinity != nil {
+		job.Spec.Template.Spec.Affinity = affinity.DeepCopy()
+	}
+	for _, toleration := range tolerations {
+		job.Spec.Template.Spec.Tolerations = append(job.Spec.Template.Spec.Tolerations, *toleration.DeepCopy())
+	}
+	if priorityClassName != "" {
+		job.Spec.Template.Spec.PriorityClassName = priorityClassName
+	}
+	if len(topologySpreadConstraints) > 0 {
+		job.Spec.Template.Spec.TopologySpreadConstraints = make([]corev1.TopologySpreadConstraint, len(topologySpreadConstraints))
+		for i := range topologySpreadConstraints {
+			topologySpreadConstraints[i].DeepCopyInto(&job.Spec.Template.Spec.TopologySpreadConstraints[i])
+		}
+	}
+}
+
 // chartSource returns a string describing the source of the chart:
 // chartContent, chart URL, or repo+version
 func chartSource(chart *v1.HelmChart) string {
@@ -1533,6 +2642,17 @@ func chartSource(chart *v1.HelmChart) string {
 		return "<unknown>"
 	}
 
+	if git := chart.Spec.Git; git != nil {
+		switch {
+		case git.Commit != "":
+			return fmt.Sprintf("commit %s of git repository %s", git.Commit, git.URL)
+		case git.Ref != "":
+			return fmt.Sprintf("ref %s of git repository %s", git.Ref, git.URL)
+		default:
+			return fmt.Sprintf("default branch of git repository %s", git.URL)
+		}
+	}
+
 	if chart.Spec.ChartContent != "" {
 		if url := chart.Annotations[AnnotationChartURL]; url != "" {
 			return fmt.Sprintf("inline spec.chartContent from %s", url)
@@ -1591,10 +2711,23 @@ func jobName(chart *v1.HelmChart) string {
 	action := "install"
 	if chart.DeletionTimestamp != nil {
 		action = "delete"
+	} else if chart.Spec.RollbackTo != nil {
+		action = "rollback"
+	} else if chart.Spec.DryRun {
+		action = "dryrun"
 	}
 	return fmt.Sprintf("helm-%s-%s", action, chart.Name)
 }
 
+// targetNamespace returns the namespace that the chart is installed into,
+// defaulting to the namespace of the chart itself.
+func targetNamespace(chart *v1.HelmChart) string {
+	if len(chart.Spec.TargetNamespace) != 0 {
+		return chart.Spec.TargetNamespace
+	}
+	return chart.Namespace
+}
+
 func helmDriver(chart *v1.HelmChart) string {
 	if chart.Spec.Driver != "" {
 		return string(chart.Spec.Driver)
@@ -1,23 +1,40 @@
 package chart
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
 	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
 	"github.com/k3s-io/helm-controller/pkg/controllers/extjson"
+	helmcontroller "github.com/k3s-io/helm-controller/pkg/generated/controllers/helm.cattle.io/v1"
+	"github.com/k3s-io/helm-controller/pkg/notify"
 
+	corecontroller "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/v3/pkg/generic"
 	"github.com/rancher/wrangler/v3/pkg/yaml"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	batch "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/ptr"
 )
 
@@ -167,7 +184,7 @@ func TestHashObjects(t *testing.T) {
 
 			objects := []metav1.Object{configMap, secret}
 			if chart.DeletionTimestamp == nil {
-				valuesSecretAddConfig(job, secret, config)
+				valuesSecretAddConfig(job, secret, config, 0, 0)
 
 				assert.Nil(secret.StringData, "Secret StringData should be nil")
 				assert.Nil(configMap.BinaryData, "ConfigMap BinaryData should be nil")
@@ -247,6 +264,105 @@ func TestInstallJobWithoutPodLimits(t *testing.T) {
 	assert.Empty(job.Spec.Template.Spec.Containers[0].Resources.Limits)
 }
 
+func TestInstallJobResources(t *testing.T) {
+	assert := assert.New(t)
+	oldJobResources := JobResources
+	defer func() { JobResources = oldJobResources }()
+	JobResources = &corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{
+			corev1.ResourceMemory: resource.MustParse("10G"),
+		},
+	}
+
+	chart := NewChart()
+	chart.Spec.JobResources = &corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceMemory: resource.MustParse("1G"),
+		},
+	}
+	job, _, _ := job(chart, "6443")
+	assert.Equal(*chart.Spec.JobResources, job.Spec.Template.Spec.Containers[0].Resources)
+
+	// resources from the HelmChartConfig take precedence
+	setPodResources(job, &corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2G")}})
+	assert.Equal("2G", job.Spec.Template.Spec.Containers[0].Resources.Limits.Memory().String())
+	assert.Empty(job.Spec.Template.Spec.Containers[0].Resources.Requests)
+}
+
+func TestValuesConfigMaps(t *testing.T) {
+	assert := assert.New(t)
+	chart := NewChart()
+	chart.Spec.ValuesSecrets = []v1.SecretSpec{{Name: "secret-values", Keys: []string{"values.yaml"}}}
+	chart.Spec.ValuesConfigMaps = []v1.ConfigMapSpec{
+		{Name: "shared-values", Keys: []string{"values.yaml", "overrides.yaml"}},
+		{Name: "optional-values", Keys: []string{"values.yaml"}, IgnoreUpdates: true},
+		{Name: "unused"},
+	}
+	config := v1.NewHelmChartConfig("kube-system", "traefik", v1.HelmChartConfig{
+		Spec: v1.HelmChartConfigSpec{
+			ValuesConfigMaps: []v1.ConfigMapSpec{{Name: "config-values", Keys: []string{"values.yaml"}}},
+		},
+	})
+
+	job, secret, _ := job(chart, "6443")
+	assert.Equal(3, valuesSecretAddConfig(job, secret, config, 0, 0))
+
+	var sources []corev1.VolumeProjection
+	for _, volume := range job.Spec.Template.Spec.Volumes {
+		if volume.Name == "values" {
+			sources = volume.Projected.Sources
+		}
+	}
+	if assert.Len(sources, 5) {
+		assert.Equal("values-0-002-HelmChart-ValuesSecret.yaml", sources[1].Secret.Items[0].Path)
+		assert.Equal(&corev1.ConfigMapProjection{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "shared-values"},
+			Items: []corev1.KeyToPath{
+				{Key: "values.yaml", Path: "values-0-003-HelmChart-ValuesConfigMap.yaml"},
+				{Key: "overrides.yaml", Path: "values-0-004-HelmChart-ValuesConfigMap.yaml"},
+			},
+			Optional: ptr.To(false),
+		}, sources[2].ConfigMap)
+		assert.True(*sources[3].ConfigMap.Optional)
+		assert.Equal("values-1-002-HelmChartConfig-ValuesConfigMap.yaml", sources[4].ConfigMap.Items[0].Path)
+	}
+
+	keys, _ := chartByConfigMap(chart)
+	assert.ElementsMatch([]string{"kube-system.shared-values", "kube-system.unused"}, keys)
+	keys, _ = chartConfigByConfigMap(config)
+	assert.Equal([]string{"kube-system.config-values"}, keys)
+}
+
+func TestJobRBAC(t *testing.T) {
+	assert := assert.New(t)
+	chart := NewChart()
+	objs := jobRBAC(chart, "cluster-admin")
+	if assert.Len(objs, 2) {
+		crb := objs[1].(*rbac.ClusterRoleBinding)
+		assert.Equal("helm-kube-system-traefik", crb.Name)
+		assert.Equal(rbac.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "cluster-admin"}, crb.RoleRef)
+		assert.Equal("helm-traefik", crb.Subjects[0].Name)
+	}
+
+	// a namespaced scope binds the job role in the target namespace only
+	chart.Spec.TargetNamespace = "traefik"
+	chart.Spec.RBACScope = v1.RBACScopeNamespaced
+	chart.Spec.JobRole = &v1.JobRoleReference{Kind: "Role", Name: "traefik-deployer"}
+	objs = jobRBAC(chart, "cluster-admin")
+	if assert.Len(objs, 2) {
+		rb := objs[1].(*rbac.RoleBinding)
+		assert.Equal("traefik", rb.Namespace)
+		assert.Equal(rbac.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "Role", Name: "traefik-deployer"}, rb.RoleRef)
+		assert.Equal(rbac.Subject{Kind: "ServiceAccount", Name: "helm-traefik", Namespace: "kube-system"}, rb.Subjects[0])
+	}
+
+	// an existing ServiceAccount is used as-is
+	chart.Spec.ServiceAccount = "traefik-deployer"
+	assert.Empty(jobRBAC(chart, "cluster-admin"))
+	job, _, _ := job(chart, "6443")
+	assert.Equal("traefik-deployer", job.Spec.Template.Spec.ServiceAccountName)
+}
+
 func TestDeleteJob(t *testing.T) {
 	assert := assert.New(t)
 	chart := NewChart()
@@ -256,6 +372,25 @@ func TestDeleteJob(t *testing.T) {
 	assert.Equal("helm-delete-traefik", job.Name)
 }
 
+func TestRollbackJob(t *testing.T) {
+	assert := assert.New(t)
+	chart := NewChart()
+	chart.Spec.TargetNamespace = "target-ns"
+	chart.Spec.Timeout = &metav1.Duration{Duration: 5 * time.Minute}
+	chart.Spec.RollbackTo = ptr.To[int64](2)
+	job, _, _ := job(chart, "6443")
+	setRollback(job, chart, 2)
+	assert.Equal("helm-rollback-traefik", job.Name)
+	assert.Equal([]string{"helm_v3"}, job.Spec.Template.Spec.Containers[0].Command)
+	assert.Equal("rollback traefik 2 --namespace target-ns --wait --timeout 5m0s", strings.Join(job.Spec.Template.Spec.Containers[0].Args, " "))
+	assert.Equal("2", job.Spec.Template.Annotations[KeyRollbackRevision])
+
+	// the delete job takes precedence over rollback
+	deleteTime := metav1.NewTime(time.Time{})
+	chart.DeletionTimestamp = &deleteTime
+	assert.Equal("helm-delete-traefik", jobName(chart))
+}
+
 func TestInstallJobImage(t *testing.T) {
 	assert := assert.New(t)
 	chart := NewChart()
@@ -296,6 +431,45 @@ func TestInstallJobBootstrapAndCustomTolerations(t *testing.T) {
 	assert.Contains(job.Spec.Template.Spec.Tolerations, JobTolerations[0])
 }
 
+func TestInstallJobPlacement(t *testing.T) {
+	assert := assert.New(t)
+	chart := NewChart()
+	chart.Spec.Bootstrap = true
+	chart.Spec.NodeSelector = map[string]string{"node-pool": "system"}
+	chart.Spec.Tolerations = []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}}
+	chart.Spec.PriorityClassName = "low-priority"
+	oldDefaultJobTolerations := JobTolerations
+	defer func() { JobTolerations = oldDefaultJobTolerations }()
+	JobTolerations = []corev1.Toleration{{Key: "custom-taint", Operator: corev1.TolerationOpExists}}
+
+	job, _, _ := job(chart, "6443")
+	assert.Equal(map[string]string{
+		corev1.LabelOSStable:                          "linux",
+		LabelNodeRolePrefix + LabelControlPlaneSuffix: "true",
+		"node-pool": "system",
+	}, job.Spec.Template.Spec.NodeSelector)
+	assert.Contains(job.Spec.Template.Spec.Tolerations, JobTolerations[0])
+	assert.Contains(job.Spec.Template.Spec.Tolerations, chart.Spec.Tolerations[0])
+	assert.Equal("low-priority", job.Spec.Template.Spec.PriorityClassName)
+	assert.Nil(job.Spec.Template.Spec.Affinity)
+
+	// settings from the HelmChartConfig are merged over those of the HelmChart
+	affinity := &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+		NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "zone", Operator: corev1.NodeSelectorOpIn, Values: []string{"a"}}}}},
+	}}}
+	setPlacement(job, map[string]string{"node-pool": "helm"}, affinity, []corev1.Toleration{{Key: "config", Operator: corev1.TolerationOpExists}}, "", nil)
+	assert.Equal("helm", job.Spec.Template.Spec.NodeSelector["node-pool"])
+	assert.Equal(affinity, job.Spec.Template.Spec.Affinity)
+	assert.Contains(job.Spec.Template.Spec.Tolerations, chart.Spec.Tolerations[0])
+	assert.Contains(job.Spec.Template.Spec.Tolerations, corev1.Toleration{Key: "config", Operator: corev1.TolerationOpExists})
+	assert.Equal("low-priority", job.Spec.Template.Spec.PriorityClassName)
+
+	// changes to placement change the job template
+	newJob := job.DeepCopy()
+	setPlacement(newJob, nil, nil, nil, "system-node-critical", nil)
+	assert.True(templateChanged(job, newJob))
+}
+
 func TestInstallArgs(t *testing.T) {
 	assert := assert.New(t)
 	stringArgs := strings.Join(args(NewChart()), " ")
@@ -308,6 +482,40 @@ func TestInstallArgs(t *testing.T) {
 		stringArgs)
 }
 
+func TestDryRunArgs(t *testing.T) {
+	assert := assert.New(t)
+	chart := NewChart()
+	chart.Spec.Set = nil
+	chart.Spec.Version = "1.2.3"
+	chart.Spec.DryRun = true
+	assert.Equal("install --version 1.2.3 --dry-run", strings.Join(args(chart), " "))
+	assert.Equal("helm-dryrun-traefik", jobName(chart))
+}
+
+func TestDryRunJob(t *testing.T) {
+	assert := assert.New(t)
+	chart := NewChart()
+	chart.Spec.Set = nil
+	chart.Spec.Version = "1.2.3"
+	chart.Spec.DryRun = true
+	dryRunJob, secret, _ := job(chart, "6443")
+	configureJob(chart, dryRunJob, secret, nil)
+	container := dryRunJob.Spec.Template.Spec.Containers[0]
+	assert.Equal([]string{"/bin/sh", "-c", dryRunScript, "helm-dryrun"}, container.Command)
+	assert.Equal("install --version 1.2.3 --dry-run", strings.Join(container.Args, " "))
+	for _, env := range container.Env {
+		assert.NotEqual("FAILURE_POLICY", env.Name)
+	}
+
+	// the install job keeps the entrypoint and failure policy
+	chart.Spec.DryRun = false
+	installJob, secret, _ := job(chart, "6443")
+	configureJob(chart, installJob, secret, nil)
+	container = installJob.Spec.Template.Spec.Containers[0]
+	assert.Empty(container.Command)
+	assert.Contains(container.Env, corev1.EnvVar{Name: "FAILURE_POLICY", Value: string(v1.FailurePolicyReinstall)})
+}
+
 func TestDeleteArgs(t *testing.T) {
 	assert := assert.New(t)
 	chart := NewChart()
@@ -370,6 +578,16 @@ func TestMaxReleaseRevision(t *testing.T) {
 			{Labels: map[string]string{"owner": "helm"}},
 			{Labels: map[string]string{"version": "5"}},
 		}, release{revision: 5}},
+		{"last deployed revision before failed upgrade", []metav1.ObjectMeta{
+			{Labels: map[string]string{"version": "1", "status": "superseded"}},
+			{Labels: map[string]string{"version": "2", "status": "deployed"}},
+			{Labels: map[string]string{"version": "3", "status": "failed"}},
+		}, release{revision: 3, status: "failed", lastDeployedRevision: 2}},
+		{"last deployed revision before deployed release", []metav1.ObjectMeta{
+			{Labels: map[string]string{"version": "4", "status": "deployed"}},
+			{Labels: map[string]string{"version": "3", "status": "failed"}},
+			{Labels: map[string]string{"version": "2", "status": "superseded"}},
+		}, release{revision: 4, status: "deployed", lastDeployedRevision: 2}},
 	}
 
 	for _, tt := range tests {
@@ -382,6 +600,42 @@ func TestMaxReleaseRevision(t *testing.T) {
 	}
 }
 
+func TestRollbackRevision(t *testing.T) {
+	rel := release{revision: 4, status: "failed", lastDeployedRevision: 3}
+	tests := []struct {
+		name       string
+		rollbackTo int64
+		status     int64
+		expected   int64
+	}{
+		{"explicit revision", 1, 3, 1},
+		{"last deployed revision", 0, 0, 3},
+		{"previously resolved revision", 0, 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := NewChart()
+			chart.Spec.RollbackTo = ptr.To(tt.rollbackTo)
+			chart.Status.RolledBackRevision = tt.status
+			assert.Equal(t, tt.expected, rollbackRevision(chart, rel))
+		})
+	}
+}
+
+func TestSetRollbackStatus(t *testing.T) {
+	assert := assert.New(t)
+	status := v1.HelmChartStatus{RolledBackRevision: 2}
+
+	setRollbackStatus(&status, release{revision: 3, status: "failed"}, false)
+	assert.False(rolledBack(status))
+	assert.Equal("Rolling back", status.Conditions[0].Reason)
+
+	setRollbackStatus(&status, release{revision: 4, status: "deployed"}, true)
+	assert.True(rolledBack(status))
+	assert.Equal("Release rolled back to revision 2 as revision 4", status.Conditions[0].Message)
+}
+
 func TestGetChartReleaseRevision(t *testing.T) {
 	t.Run("configmap driver uses configmap storage", func(t *testing.T) {
 		assert := assert.New(t)
@@ -410,7 +664,8 @@ func TestGetChartReleaseRevision(t *testing.T) {
 		rel, err := c.getChartRelease(chart)
 		assert.NoError(err)
 		assert.True(called)
-		assert.Equal(release{revision: 3, status: "deployed", hash: "ABC"}, rel)
+		assert.Equal(release{revision: 3, status: "deployed", hash: "ABC"}, release{revision: rel.revision, status: rel.status, hash: rel.hash})
+		assert.Len(rel.history, 2)
 	})
 
 	t.Run("default driver uses secret storage", func(t *testing.T) {
@@ -439,10 +694,425 @@ func TestGetChartReleaseRevision(t *testing.T) {
 		rel, err := c.getChartRelease(chart)
 		assert.NoError(err)
 		assert.True(called)
-		assert.Equal(release{revision: 5, status: "deployed", hash: "ABC"}, rel)
+		assert.Equal(release{revision: 5, status: "deployed", hash: "ABC"}, release{revision: rel.revision, status: rel.status, hash: rel.hash})
+		assert.Len(rel.history, 2)
 	})
 }
 
+func TestReleaseHistory(t *testing.T) {
+	assert := assert.New(t)
+	c := &Controller{}
+	chart := NewChart()
+
+	encode := func(version, description string) string {
+		return base64.StdEncoding.EncodeToString(fmt.Appendf(nil, `{"info":{"last_deployed":"2024-01-02T03:04:05.123456789Z","description":%q},"chart":{"metadata":{"version":%q}}}`, description, version))
+	}
+	var objects []metav1.ObjectMeta
+	var data []string
+	for i := 1; i <= maxReleaseHistory+2; i++ {
+		objects = append(objects, metav1.ObjectMeta{
+			UID:    types.UID(fmt.Sprint(i)),
+			Labels: map[string]string{"version": fmt.Sprint(i), "status": "superseded", KeyConfigHash: fmt.Sprint("HASH", i)},
+		})
+		data = append(data, encode(fmt.Sprintf("1.%d.0", i), "Upgrade complete"))
+	}
+	objects[len(objects)-1].Labels["status"] = "deployed"
+	decoded := 0
+	dataFunc := func(i int) string {
+		decoded++
+		return data[i]
+	}
+
+	history := c.releaseHistory(chart, objects, dataFunc)
+	assert.Len(history, maxReleaseHistory)
+	assert.Equal(maxReleaseHistory, decoded, "only listed revisions should be decoded")
+	assert.Equal(int64(maxReleaseHistory+2), history[0].Revision)
+	assert.Equal("deployed", history[0].Status)
+	assert.Equal(fmt.Sprint("HASH", maxReleaseHistory+2), history[0].ConfigHash)
+	assert.Equal(fmt.Sprintf("1.%d.0", maxReleaseHistory+2), history[0].ChartVersion)
+	assert.Equal("Upgrade complete", history[0].Description)
+	if assert.NotNil(history[0].Deployed) {
+		assert.Equal(2024, history[0].Deployed.Year())
+	}
+	assert.Equal(int64(3), history[maxReleaseHistory-1].Revision)
+
+	// records are only decoded once, and records that cannot be decoded are listed with info from their labels
+	objects = append(objects, metav1.ObjectMeta{UID: "new", Labels: map[string]string{"version": fmt.Sprint(maxReleaseHistory + 3), "status": "failed"}})
+	data = append(data, "not base64!")
+	decoded = 0
+	history = c.releaseHistory(chart, objects, dataFunc)
+	assert.Equal(1, decoded)
+	assert.Len(history, maxReleaseHistory)
+	assert.Equal("failed", history[0].Status)
+	assert.Empty(history[0].ChartVersion)
+	assert.Nil(history[0].Deployed)
+	assert.Equal("Upgrade complete", history[1].Description)
+
+	assert.Nil(c.releaseHistory(chart, nil, dataFunc))
+}
+
+func TestLatestReleaseInfo(t *testing.T) {
+	assert := assert.New(t)
+	c := &Controller{}
+	chart := NewChart()
+
+	manifest := "---\n# Source: traefik/templates/deployment.yaml\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: traefik\n  namespace: kube-system\n---\n# Source: traefik/templates/rbac.yaml\napiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: traefik\n"
+	record, _ := json.Marshal(map[string]any{
+		"manifest": manifest,
+		"info":     map[string]any{"notes": "  Traefik has been installed.\n"},
+		"chart":    map[string]any{"metadata": map[string]any{"name": "traefik", "version": "27.0.0", "appVersion": "v2.11.0"}},
+	})
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(record)
+	w.Close()
+
+	objects := []metav1.ObjectMeta{
+		{UID: "1", Labels: map[string]string{"version": "1", "status": "superseded"}},
+		{UID: "2", Labels: map[string]string{"version": "2", "status": "deployed"}},
+	}
+	data := []string{"not base64!", base64.StdEncoding.EncodeToString(buf.Bytes())}
+	decoded := 0
+	dataFunc := func(i int) string {
+		decoded++
+		return data[i]
+	}
+
+	expected := &v1.HelmChartReleaseInfo{
+		ChartName:    "traefik",
+		ChartVersion: "27.0.0",
+		AppVersion:   "v2.11.0",
+		Notes:        "Traefik has been installed.",
+		Resources: []v1.HelmChartResource{
+			{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "kube-system", Name: "traefik"},
+			{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "traefik"},
+		},
+	}
+	assert.Equal(expected, c.latestReleaseInfo(chart, objects, dataFunc))
+	// the record is only decoded again if the latest release changes
+	assert.Equal(expected, c.latestReleaseInfo(chart, objects, dataFunc))
+	assert.Equal(1, decoded)
+
+	// records that cannot be decoded are ignored
+	objects = append(objects, metav1.ObjectMeta{UID: "3", Labels: map[string]string{"version": "3", "status": "failed"}})
+	data = append(data, "not base64!")
+	assert.Nil(c.latestReleaseInfo(chart, objects, dataFunc))
+	assert.Nil(c.latestReleaseInfo(chart, nil, dataFunc))
+
+	// the deployed chart version is recorded, if no version is specified
+	job, _, _ := job(chart, "6443")
+	chartStatus := v1.HelmChartStatus{}
+	setReleaseStatus(chart, &chartStatus, job, release{revision: 2, status: "deployed", hash: configHash(job), info: expected})
+	assert.Equal("27.0.0", chartStatus.ChartVersion)
+	assert.Equal(expected, chartStatus.Release)
+}
+
+func TestTrimReleaseNotes(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("notes", trimReleaseNotes("\nnotes\n"))
+	notes := trimReleaseNotes(strings.Repeat("a", maxReleaseNotesBytes+1))
+	assert.Equal(strings.Repeat("a", maxReleaseNotesBytes)+"...", notes)
+}
+
+func TestDependencyCycle(t *testing.T) {
+	newChart := func(namespace, name string, deps ...v1.HelmChartReference) *v1.HelmChart {
+		return v1.NewHelmChart(namespace, name, v1.HelmChart{Spec: v1.HelmChartSpec{DependsOn: deps}})
+	}
+
+	tests := []struct {
+		name     string
+		charts   []*v1.HelmChart
+		expected []string
+	}{
+		{"no dependencies", []*v1.HelmChart{
+			newChart("kube-system", "a"),
+		}, nil},
+		{"linear dependencies", []*v1.HelmChart{
+			newChart("kube-system", "a", v1.HelmChartReference{Name: "b"}),
+			newChart("kube-system", "b", v1.HelmChartReference{Name: "c"}),
+			newChart("kube-system", "c"),
+		}, nil},
+		{"missing dependency", []*v1.HelmChart{
+			newChart("kube-system", "a", v1.HelmChartReference{Name: "b"}),
+		}, nil},
+		{"self dependency", []*v1.HelmChart{
+			newChart("kube-system", "a", v1.HelmChartReference{Name: "a"}),
+		}, []string{"kube-system/a", "kube-system/a"}},
+		{"indirect cycle", []*v1.HelmChart{
+			newChart("kube-system", "a", v1.HelmChartReference{Name: "b"}),
+			newChart("kube-system", "b", v1.HelmChartReference{Namespace: "other", Name: "c"}),
+			newChart("other", "c", v1.HelmChartReference{Namespace: "kube-system", Name: "a"}),
+		}, []string{"kube-system/a", "kube-system/b", "other/c", "kube-system/a"}},
+		{"cycle not including chart", []*v1.HelmChart{
+			newChart("kube-system", "a", v1.HelmChartReference{Name: "b"}),
+			newChart("kube-system", "b", v1.HelmChartReference{Name: "c"}),
+			newChart("kube-system", "c", v1.HelmChartReference{Name: "b"}),
+		}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			get := func(namespace, name string) (*v1.HelmChart, error) {
+				for _, chart := range tt.charts {
+					if chart.Namespace == namespace && chart.Name == name {
+						return chart, nil
+					}
+				}
+				return nil, apierrors.NewNotFound(v1.Resource("helmchart"), name)
+			}
+			assert.Equal(tt.expected, dependencyCycle(tt.charts[0], get))
+		})
+	}
+}
+
+func TestChartByDependency(t *testing.T) {
+	assert := assert.New(t)
+	chart := NewChart()
+	chart.Spec.DependsOn = []v1.HelmChartReference{
+		{Name: "cert-manager"},
+		{Namespace: "crds", Name: "cert-manager-crds"},
+	}
+	keys, err := chartByDependency(chart)
+	assert.NoError(err)
+	assert.ElementsMatch([]string{"kube-system.cert-manager", "crds.cert-manager-crds"}, keys)
+}
+
+func TestSetConditions(t *testing.T) {
+	assert := assert.New(t)
+	then := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	existing := []v1.HelmChartCondition{
+		{Type: v1.HelmChartJobCreated, Status: corev1.ConditionTrue, LastTransitionTime: then},
+		{Type: v1.HelmChartFailed, Status: corev1.ConditionFalse, LastTransitionTime: then},
+		{Type: v1.HelmChartWaiting, Status: corev1.ConditionTrue, LastTransitionTime: then},
+	}
+
+	conditions := setConditions(existing,
+		v1.HelmChartCondition{Type: v1.HelmChartJobCreated, Status: corev1.ConditionTrue, Reason: "Job created"},
+		v1.HelmChartCondition{Type: v1.HelmChartFailed, Status: corev1.ConditionTrue, Reason: "Job failed"},
+		v1.HelmChartCondition{Type: v1.HelmChartReady, Status: corev1.ConditionFalse},
+	)
+	conditions = removeCondition(conditions, v1.HelmChartWaiting)
+
+	assert.Len(conditions, 3)
+	assert.Equal(v1.HelmChartJobCreated, conditions[0].Type)
+	assert.Equal("Job created", conditions[0].Reason)
+	assert.Equal(then, conditions[0].LastTransitionTime, "LastTransitionTime should not change if status is unchanged")
+	assert.Equal(v1.HelmChartFailed, conditions[1].Type)
+	assert.True(then.Before(&conditions[1].LastTransitionTime), "LastTransitionTime should be updated if status has changed")
+	assert.Equal(v1.HelmChartReady, conditions[2].Type)
+	assert.False(conditions[2].LastTransitionTime.IsZero(), "LastTransitionTime should be set on new conditions")
+
+	assert.Len(existing, 3, "existing conditions should not be modified")
+	assert.Equal(corev1.ConditionFalse, existing[1].Status, "existing conditions should not be modified")
+}
+
+func TestSetReleaseStatus(t *testing.T) {
+	chart := NewChart()
+	chart.Spec.Version = "1.2.3"
+	job, _, _ := job(chart, "6443")
+	hashObjects(job)
+	hash := configHash(job)
+
+	tests := []struct {
+		name         string
+		release      release
+		ready        corev1.ConditionStatus
+		reason       string
+		chartVersion string
+		configHash   string
+		revision     int64
+	}{
+		{"no release", release{}, corev1.ConditionFalse, "Not installed", "", "", 0},
+		{"failed release", release{revision: 2, status: "failed", hash: hash}, corev1.ConditionFalse, "Release not deployed", "", hash, 2},
+		{"outdated release", release{revision: 3, status: "deployed", hash: "ABC"}, corev1.ConditionFalse, "Release outdated", "", "ABC", 3},
+		{"deployed release", release{revision: 4, status: "deployed", hash: hash}, corev1.ConditionTrue, "Deployed", "1.2.3", hash, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			status := v1.HelmChartStatus{}
+			setReleaseStatus(chart, &status, job, tt.release)
+			assert.Equal(tt.revision, status.ReleaseRevision)
+			assert.Equal(tt.configHash, status.ConfigHash)
+			assert.Equal(tt.chartVersion, status.ChartVersion)
+			if assert.Len(status.Conditions, 1) {
+				assert.Equal(v1.HelmChartReady, status.Conditions[0].Type)
+				assert.Equal(tt.ready, status.Conditions[0].Status)
+				assert.Equal(tt.reason, status.Conditions[0].Reason)
+			}
+		})
+	}
+}
+
+func TestOnChangeSuspended(t *testing.T) {
+	assert := assert.New(t)
+	helms := &fakeHelmChartController{}
+	c := &Controller{
+		managedBy:      "helm-controller",
+		helms:          helms,
+		configMapCache: fakeConfigMapCache{},
+		recorder:       record.NewFakeRecorder(10),
+	}
+	chart := NewChart()
+	chart.Annotations = map[string]string{AnnotationManagedBy: "helm-controller"}
+	chart.Spec.Suspend = true
+
+	objs, _, err := c.OnChange(chart, chart.Status)
+	assert.ErrorIs(err, generic.ErrSkip)
+	assert.Nil(objs)
+	if assert.NotNil(helms.status) {
+		assert.True(suspended(*helms.status))
+	}
+
+	// the status is not rewritten while the chart remains suspended
+	chart.Status = *helms.status
+	helms.status = nil
+	_, _, err = c.OnChange(chart, chart.Status)
+	assert.ErrorIs(err, generic.ErrSkip)
+	assert.Nil(helms.status)
+}
+
+func TestOnChangeInvalidJobResources(t *testing.T) {
+	assert := assert.New(t)
+	helms := &fakeHelmChartController{}
+	c := &Controller{
+		managedBy:      "helm-controller",
+		helms:          helms,
+		apply:          &fakeApply{},
+		jobs:           fakeJobController{},
+		jobCache:       fakeJobCache{},
+		confCache:      fakeConfigCache{},
+		configMapCache: fakeConfigMapCache{},
+		recorder:       record.NewFakeRecorder(10),
+	}
+	chart := NewChart()
+	chart.Annotations = map[string]string{AnnotationManagedBy: "helm-controller"}
+	chart.Spec.JobResources = &corev1.ResourceRequirements{
+		Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")},
+		Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+	}
+
+	// invalid resources are reported in the status, without returning an error that would be retried
+	objs, _, err := c.OnChange(chart, chart.Status)
+	assert.ErrorIs(err, generic.ErrSkip)
+	assert.Nil(objs)
+	if assert.NotNil(helms.status) {
+		for _, condition := range helms.status.Conditions {
+			if condition.Type == v1.HelmChartFailed {
+				assert.Equal(corev1.ConditionTrue, condition.Status)
+				assert.Equal("Job create failed", condition.Reason)
+				assert.Contains(condition.Message, "jobResources.requests")
+			}
+		}
+	}
+}
+
+func TestNotifyJob(t *testing.T) {
+	assert := assert.New(t)
+	received := make(chan notify.CloudEvent, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := notify.CloudEvent{}
+		assert.NoError(json.NewDecoder(r.Body).Decode(&event))
+		received <- event
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	notifier := notify.New(notify.Options{URLs: []string{server.URL}})
+	notifier.Start(ctx)
+
+	chart := NewChart()
+	c := &Controller{
+		helmCache: fakeChartCache{charts: []*v1.HelmChart{chart}},
+		notifier:  notifier,
+		started:   time.Now().Add(-time.Minute),
+	}
+	job, _, _ := job(chart, "6443")
+	job.UID = "1"
+
+	// running jobs are not notified
+	_, err := c.notifyJob(job.Namespace+"/"+job.Name, job)
+	assert.NoError(err)
+
+	// finished jobs are notified once
+	job.Status.Conditions = []batch.JobCondition{{Type: batch.JobFailed, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded", LastTransitionTime: metav1.Now()}}
+	for range 2 {
+		_, err = c.notifyJob(job.Namespace+"/"+job.Name, job)
+		assert.NoError(err)
+	}
+	select {
+	case event := <-received:
+		assert.Equal("io.cattle.helm.chart.failed", event.Type)
+		assert.Equal("Job kube-system/helm-install-traefik failed: BackoffLimitExceeded", event.Data.Message)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for notification")
+	}
+
+	// jobs that finished before the controller started are not notified
+	job.UID = "2"
+	job.Status.Conditions[0].LastTransitionTime = metav1.NewTime(time.Now().Add(-time.Hour))
+	_, err = c.notifyJob(job.Namespace+"/"+job.Name, job)
+	assert.NoError(err)
+
+	job.UID = "3"
+	job.Status.Conditions = []batch.JobCondition{{Type: batch.JobComplete, Status: corev1.ConditionTrue, LastTransitionTime: metav1.Now()}}
+	_, err = c.notifyJob(job.Namespace+"/"+job.Name, job)
+	assert.NoError(err)
+	select {
+	case event := <-received:
+		assert.Equal("io.cattle.helm.chart.succeeded", event.Type)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for notification")
+	}
+
+	// new jobs are notified as applying once, when they are first observed
+	job.UID = "4"
+	job.CreationTimestamp = metav1.Now()
+	job.Status.Conditions = nil
+	for range 2 {
+		_, err = c.notifyJob(job.Namespace+"/"+job.Name, job)
+		assert.NoError(err)
+	}
+	select {
+	case event := <-received:
+		assert.Equal("io.cattle.helm.chart.applying", event.Type)
+		assert.Equal("helm-install-traefik", event.Data.Job)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for notification")
+	}
+	assert.Empty(received)
+}
+
+type fakeHelmChartController struct {
+	helmcontroller.HelmChartController
+	status   *v1.HelmChartStatus
+	enqueued chan string
+}
+
+func (f *fakeHelmChartController) Enqueue(namespace, name string) {
+	if f.enqueued != nil {
+		f.enqueued <- namespace + "/" + name
+	}
+}
+
+func (f *fakeHelmChartController) EnqueueAfter(namespace, name string, duration time.Duration) {}
+
+func (f *fakeHelmChartController) UpdateStatus(chart *v1.HelmChart) (*v1.HelmChart, error) {
+	f.status = chart.Status.DeepCopy()
+	return chart, nil
+}
+
+type fakeConfigMapCache struct {
+	corecontroller.ConfigMapCache
+}
+
+func (f fakeConfigMapCache) Get(namespace, name string) (*corev1.ConfigMap, error) {
+	return nil, apierrors.NewNotFound(corev1.Resource("configmaps"), name)
+}
+
 type fakeConfigMapLister struct {
 	list func(namespace string, opts metav1.ListOptions) (*corev1.ConfigMapList, error)
 }
@@ -473,3 +1143,25 @@ func NewChart() *v1.HelmChart {
 		},
 	})
 }
+
+func TestUpdateJobMetrics(t *testing.T) {
+	assert := assert.New(t)
+	c := &Controller{started: time.Now().Add(-time.Minute)}
+	newJob := func(uid string, finished time.Time) *batch.Job {
+		job, _, _ := job(NewChart(), "6443")
+		job.UID = types.UID(uid)
+		job.Status.Conditions = []batch.JobCondition{{Type: batch.JobComplete, Status: corev1.ConditionTrue, LastTransitionTime: metav1.NewTime(finished)}}
+		return job
+	}
+
+	// jobs that finished before the controller started are not counted again
+	_, err := c.updateJobMetrics("kube-system/helm-install-traefik", newJob("old", time.Now().Add(-time.Hour)))
+	assert.NoError(err)
+	_, ok := c.jobOutcomes.Load("kube-system/helm-install-traefik")
+	assert.False(ok)
+
+	_, err = c.updateJobMetrics("kube-system/helm-install-traefik", newJob("new", time.Now()))
+	assert.NoError(err)
+	uid, _ := c.jobOutcomes.Load("kube-system/helm-install-traefik")
+	assert.Equal(types.UID("new"), uid)
+}
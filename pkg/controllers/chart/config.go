@@ -0,0 +1,112 @@
+package chart
+
+import (
+	"cmp"
+	"slices"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// clusterConfigNamespace returns the namespace in which HelmChartConfigs may select HelmCharts in all namespaces.
+func (c *Controller) clusterConfigNamespace() string {
+	if c.systemNamespace != "" {
+		return c.systemNamespace
+	}
+	return metav1.NamespaceSystem
+}
+
+// configSelects returns true if the HelmChartConfig applies to the chart, either by name or by its chart selector.
+func (c *Controller) configSelects(conf *v1.HelmChartConfig, chart *v1.HelmChart) bool {
+	if conf.Namespace == chart.Namespace && conf.Name == chart.Name {
+		return true
+	}
+	if conf.Spec.ChartSelector == nil {
+		return false
+	}
+	if conf.Namespace != chart.Namespace && conf.Namespace != c.clusterConfigNamespace() {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(conf.Spec.ChartSelector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(chart.Labels))
+}
+
+// getChartConfigs returns the HelmChartConfigs that apply to the chart, in the order that they should be applied.
+// Configs are sorted by priority; at equal priority, configs that select the chart by label come before the config
+// with the same name as the chart, and are otherwise sorted by namespace and name.
+func (c *Controller) getChartConfigs(chart *v1.HelmChart) ([]*v1.HelmChartConfig, error) {
+	confs, err := c.confCache.List("", labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	return c.selectChartConfigs(chart, confs), nil
+}
+
+// selectChartConfigs returns the HelmChartConfigs from the provided list that apply to the chart, in the order that they should be applied.
+func (c *Controller) selectChartConfigs(chart *v1.HelmChart, confs []*v1.HelmChartConfig) []*v1.HelmChartConfig {
+	var configs []*v1.HelmChartConfig
+	for _, conf := range confs {
+		if conf.DeletionTimestamp == nil && c.configSelects(conf, chart) {
+			configs = append(configs, conf)
+		}
+	}
+	named := func(conf *v1.HelmChartConfig) bool {
+		return conf.Namespace == chart.Namespace && conf.Name == chart.Name
+	}
+	slices.SortFunc(configs, func(a, b *v1.HelmChartConfig) int {
+		if n := cmp.Compare(a.Spec.Priority, b.Spec.Priority); n != 0 {
+			return n
+		}
+		if named(a) != named(b) {
+			if named(a) {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Or(cmp.Compare(a.Namespace, b.Namespace), cmp.Compare(a.Name, b.Name))
+	})
+	return configs
+}
+
+func (c *Controller) resolveHelmChartFromHelmChartConfig(namespace, name string, obj runtime.Object) ([]relatedresource.Key, error) {
+	if len(c.systemNamespace) > 0 && namespace != c.systemNamespace {
+		// do nothing if it's not in the namespace this controller was registered with
+		return nil, nil
+	}
+	// Charts that the config previously applied to must also be updated, in case
+	// the config was deleted or its selector no longer matches them.
+	var keys []relatedresource.Key
+	if prev, ok := c.configCharts.Load(namespace + "/" + name); ok {
+		keys = append(keys, prev.([]relatedresource.Key)...)
+	}
+	conf, ok := obj.(*v1.HelmChartConfig)
+	if !ok || conf.DeletionTimestamp != nil {
+		c.configCharts.Delete(namespace + "/" + name)
+		return keys, nil
+	}
+
+	// See if there is a HelmChart with the same name/namespace as this HelmChartConfig,
+	// or HelmCharts that are selected by this HelmChartConfig
+	chartNamespace := conf.Namespace
+	if conf.Spec.ChartSelector != nil && conf.Namespace == c.clusterConfigNamespace() {
+		chartNamespace = ""
+	}
+	charts, err := c.helmCache.List(chartNamespace, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var selected []relatedresource.Key
+	for _, chart := range charts {
+		if c.configSelects(conf, chart) {
+			selected = append(selected, relatedresource.Key{Namespace: chart.Namespace, Name: chart.Name})
+		}
+	}
+	c.configCharts.Store(namespace+"/"+name, selected)
+	return append(keys, selected...), nil
+}
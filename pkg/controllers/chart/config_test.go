@@ -0,0 +1,155 @@
+package chart
+
+import (
+	"testing"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	helmcontroller "github.com/k3s-io/helm-controller/pkg/generated/controllers/helm.cattle.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/ptr"
+)
+
+type fakeConfigCache struct {
+	helmcontroller.HelmChartConfigCache
+	configs []*v1.HelmChartConfig
+}
+
+func (f fakeConfigCache) List(namespace string, selector labels.Selector) ([]*v1.HelmChartConfig, error) {
+	var configs []*v1.HelmChartConfig
+	for _, conf := range f.configs {
+		if namespace == "" || conf.Namespace == namespace {
+			configs = append(configs, conf)
+		}
+	}
+	return configs, nil
+}
+
+type fakeChartCache struct {
+	helmcontroller.HelmChartCache
+	charts []*v1.HelmChart
+}
+
+func (f fakeChartCache) Get(namespace, name string) (*v1.HelmChart, error) {
+	for _, chart := range f.charts {
+		if chart.Namespace == namespace && chart.Name == name {
+			return chart, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(v1.Resource("helmcharts"), name)
+}
+
+func (f fakeChartCache) List(namespace string, selector labels.Selector) ([]*v1.HelmChart, error) {
+	var charts []*v1.HelmChart
+	for _, chart := range f.charts {
+		if namespace == "" || chart.Namespace == namespace {
+			charts = append(charts, chart)
+		}
+	}
+	return charts, nil
+}
+
+func newConfig(namespace, name string, priority int32, selector map[string]string) *v1.HelmChartConfig {
+	conf := v1.NewHelmChartConfig(namespace, name, v1.HelmChartConfig{
+		Spec: v1.HelmChartConfigSpec{
+			Priority:      priority,
+			ValuesContent: "config: " + name + "\n",
+		},
+	})
+	if selector != nil {
+		conf.Spec.ChartSelector = &metav1.LabelSelector{MatchLabels: selector}
+	}
+	return conf
+}
+
+func TestGetChartConfigs(t *testing.T) {
+	assert := assert.New(t)
+	c := &Controller{
+		confCache: fakeConfigCache{configs: []*v1.HelmChartConfig{
+			newConfig("kube-system", "traefik", 0, nil),
+			newConfig("kube-system", "baseline", 0, map[string]string{}),
+			newConfig("kube-system", "overlay", 10, map[string]string{"env": "prod"}),
+			newConfig("kube-system", "staging", 20, map[string]string{"env": "staging"}),
+			newConfig("default", "other", 30, map[string]string{}),
+		}},
+	}
+
+	chart := NewChart()
+	chart.Labels = map[string]string{"env": "prod"}
+	configs, err := c.getChartConfigs(chart)
+	assert.NoError(err)
+	var names []string
+	for _, conf := range configs {
+		names = append(names, conf.Name)
+	}
+	assert.Equal([]string{"baseline", "traefik", "overlay"}, names)
+
+	// configs in the system namespace select charts in all namespaces; others only select charts in their own namespace
+	other := v1.NewHelmChart("apps", "podinfo", v1.HelmChart{})
+	configs, err = c.getChartConfigs(other)
+	assert.NoError(err)
+	if assert.Len(configs, 1) {
+		assert.Equal("baseline", configs[0].Name)
+	}
+
+	// values from each config are projected in order, after the chart values
+	c.confCache = fakeConfigCache{configs: []*v1.HelmChartConfig{
+		newConfig("kube-system", "traefik", 0, nil),
+		newConfig("kube-system", "overlay", 10, map[string]string{"env": "prod"}),
+	}}
+	configs, err = c.getChartConfigs(chart)
+	assert.NoError(err)
+	helmJob, secret, _ := job(chart, "6443")
+	item := 0
+	for i, conf := range configs {
+		item = valuesSecretAddConfig(helmJob, secret, conf, i, item)
+	}
+	assert.Equal(4, item)
+	assert.Equal("config: traefik\n", string(secret.Data["HelmChartConfigValuesContent"]))
+	assert.Equal("config: overlay\n", string(secret.Data["HelmChartConfigValuesContent-1"]))
+	for _, volume := range helmJob.Spec.Template.Spec.Volumes {
+		if volume.Name == "values" {
+			assert.Equal([]corev1.KeyToPath{
+				{Key: "HelmChartConfigValuesContent", Path: "values-1-000-HelmChartConfig-ValuesContent.yaml"},
+				{Key: "HelmChartConfigValuesContent-1", Path: "values-1-002-HelmChartConfig-ValuesContent.yaml"},
+			}, volume.Projected.Sources[0].Secret.Items)
+		}
+	}
+}
+
+func TestResolveHelmChartFromHelmChartConfig(t *testing.T) {
+	assert := assert.New(t)
+	chart := NewChart()
+	chart.Labels = map[string]string{"env": "prod"}
+	c := &Controller{
+		helmCache: fakeChartCache{charts: []*v1.HelmChart{
+			chart,
+			v1.NewHelmChart("kube-system", "coredns", v1.HelmChart{}),
+			v1.NewHelmChart("apps", "podinfo", v1.HelmChart{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"env": "prod"}}}),
+		}},
+	}
+
+	overlay := newConfig("kube-system", "overlay", 10, map[string]string{"env": "prod"})
+	keys, err := c.resolveHelmChartFromHelmChartConfig(overlay.Namespace, overlay.Name, overlay)
+	assert.NoError(err)
+	assert.ElementsMatch([]relatedresource.Key{{Namespace: "kube-system", Name: "traefik"}, {Namespace: "apps", Name: "podinfo"}}, keys)
+
+	// charts that were previously selected are resolved when the selector changes, or the config is deleted
+	overlay.Spec.ChartSelector.MatchLabels = map[string]string{"env": "staging"}
+	keys, err = c.resolveHelmChartFromHelmChartConfig(overlay.Namespace, overlay.Name, overlay)
+	assert.NoError(err)
+	assert.Len(keys, 2)
+	keys, err = c.resolveHelmChartFromHelmChartConfig(overlay.Namespace, overlay.Name, nil)
+	assert.NoError(err)
+	assert.Empty(keys)
+
+	named := newConfig("kube-system", "traefik", 0, nil)
+	named.DeletionTimestamp = ptr.To(metav1.Now())
+	keys, err = c.resolveHelmChartFromHelmChartConfig(named.Namespace, named.Name, named)
+	assert.NoError(err)
+	assert.Empty(keys)
+}
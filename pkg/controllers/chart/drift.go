@@ -0,0 +1,308 @@
+package chart
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/yaml"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// maxListedResources limits the number of resources listed in condition messages.
+	maxListedResources = 5
+	// resyncIneffectiveMessage is appended to the Drifted condition message when a resync did not correct the drift.
+	resyncIneffectiveMessage = "; the release was resynced, but the resources still differ"
+)
+
+// driftIgnoredFields are top-level fields that are not compared when checking for drift,
+// as they are either managed by the apiserver or controllers, or are write-only.
+var driftIgnoredFields = []string{"metadata", "status", "stringData"}
+
+// driftDetectionInterval returns the interval between drift checks for the chart,
+// or zero if drift detection is not enabled.
+func driftDetectionInterval(chart *v1.HelmChart) time.Duration {
+	if chart.Spec.DriftDetection == nil {
+		return 0
+	}
+	if chart.Spec.DriftDetection.Interval != nil {
+		return chart.Spec.DriftDetection.Interval.Duration
+	}
+	return DefaultDriftDetectionInterval
+}
+
+// checkDrift compares the live resources of the deployed release against the release manifest
+// once the drift detection interval has elapsed, and updates the Drifted condition. The chart is
+// re-enqueued for the next check. If resync is enabled and drift is detected, the completed job
+// is deleted, so that it will be recreated to re-apply the release; this is done once for the same drift.
+func (c *Controller) checkDrift(chart *v1.HelmChart, chartStatus *v1.HelmChartStatus) error {
+	interval := driftDetectionInterval(chart)
+	if interval <= 0 || c.dynamic == nil || !deployed(*chartStatus) {
+		return nil
+	}
+	if last := chartStatus.LastDriftCheckTime; last != nil {
+		if remaining := interval - time.Since(last.Time); remaining > 0 {
+			c.helms.EnqueueAfter(chart.Namespace, chart.Name, remaining)
+			return nil
+		}
+	}
+
+	rel, err := c.getDeployedRelease(chart)
+	if err != nil {
+		return fmt.Errorf("failed to get deployed release: %w", err)
+	}
+	if rel == nil {
+		return nil
+	}
+	drifted, err := c.driftedResources(chart, rel.Manifest)
+	if err != nil {
+		return fmt.Errorf("failed to check release resources for drift: %w", err)
+	}
+
+	now := metav1.Now()
+	chartStatus.LastDriftCheckTime = &now
+	c.helms.EnqueueAfter(chart.Namespace, chart.Name, interval)
+
+	if len(drifted) == 0 {
+		chartStatus.Conditions = setConditions(chartStatus.Conditions, v1.HelmChartCondition{
+			Type:    v1.HelmChartDrifted,
+			Status:  corev1.ConditionFalse,
+			Reason:  "No drift",
+			Message: "Live resources match the release manifest",
+		})
+		return nil
+	}
+
+	message := fmt.Sprintf("%d resources differ from the release manifest: %s", len(drifted), listResources(drifted))
+	condition := v1.HelmChartCondition{
+		Type:    v1.HelmChartDrifted,
+		Status:  corev1.ConditionTrue,
+		Reason:  "Drift detected",
+		Message: message,
+	}
+	// the release is only resynced once for the same drift; if re-applying the release did not correct it,
+	// the resources are likely modified by another controller, and resyncing again would loop forever.
+	resync := chart.Spec.DriftDetection.Resync
+	if resync && driftResynced(*chartStatus, message) {
+		resync = false
+		condition.Reason = "Resync ineffective"
+		condition.Message = message + resyncIneffectiveMessage
+	} else if resync {
+		condition.Reason = "Resyncing"
+	}
+	chartStatus.Conditions = setConditions(chartStatus.Conditions, condition)
+	c.recorder.Event(chart, corev1.EventTypeWarning, "DriftDetected", message)
+
+	if resync {
+		name := jobName(chart)
+		err := c.jobs.Delete(chart.Namespace, name, &metav1.DeleteOptions{PropagationPolicy: ptr.To(metav1.DeletePropagationBackground)})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete job to resync release: %w", err)
+		}
+		c.recorder.Eventf(chart, corev1.EventTypeNormal, "DriftResync", "Deleted Job %s/%s to resync drifted release", chart.Namespace, name)
+	}
+	return nil
+}
+
+// driftResynced returns true if the status has a True Drifted condition for the same drift, which was
+// set when the release was resynced, or after a resync failed to correct the drift.
+func driftResynced(chartStatus v1.HelmChartStatus, message string) bool {
+	for _, condition := range chartStatus.Conditions {
+		if condition.Type == v1.HelmChartDrifted {
+			return condition.Status == corev1.ConditionTrue && strings.TrimSuffix(condition.Message, resyncIneffectiveMessage) == message &&
+				(condition.Reason == "Resyncing" || condition.Reason == "Resync ineffective")
+		}
+	}
+	return false
+}
+
+// listResources returns a comma-separated list of the resource descriptions, truncated to maxListedResources.
+func listResources(resources []string) string {
+	list := strings.Join(resources[:min(len(resources), maxListedResources)], ", ")
+	if len(resources) > maxListedResources {
+		list += ", ..."
+	}
+	return list
+}
+
+// driftedResources returns a description of each resource in the manifest that is missing,
+// or whose live state does not match the manifest.
+func (c *Controller) driftedResources(chart *v1.HelmChart, manifest string) ([]string, error) {
+	objs, err := yaml.ToObjects(strings.NewReader(manifest))
+	if err != nil {
+		return nil, err
+	}
+
+	var drifted []string
+	for _, obj := range objs {
+		desired, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		client, description, err := c.resourceClient(chart, desired)
+		if err != nil {
+			return nil, err
+		}
+
+		live, err := client.Get(context.TODO(), desired.GetName(), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			drifted = append(drifted, description+" (missing)")
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if objectDrifted(desired.Object, live.Object) {
+			drifted = append(drifted, description)
+		}
+	}
+	return drifted, nil
+}
+
+// resourceClient returns a dynamic client for the resource of an object from the release manifest, along with a
+// description of the object. Namespaced objects that do not specify a namespace are in the chart's target namespace.
+func (c *Controller) resourceClient(chart *v1.HelmChart, obj *unstructured.Unstructured) (dynamic.ResourceInterface, string, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		c.mapper.Reset()
+		mapping, err = c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		namespace := obj.GetNamespace()
+		if namespace == "" {
+			namespace = targetNamespace(chart)
+		}
+		return c.dynamic.Resource(mapping.Resource).Namespace(namespace), fmt.Sprintf("%s %s/%s", gvk.Kind, namespace, obj.GetName()), nil
+	}
+	return c.dynamic.Resource(mapping.Resource), fmt.Sprintf("%s %s", gvk.Kind, obj.GetName()), nil
+}
+
+// objectDrifted returns true if the live object does not contain all of the fields set in the
+// desired object. Fields that are not set in the desired object are ignored, as they may be
+// defaulted by the apiserver or set by other controllers. Of the object metadata, only labels
+// and annotations are compared.
+func objectDrifted(desired, live map[string]interface{}) bool {
+	for _, field := range []string{"labels", "annotations"} {
+		want, _, _ := unstructured.NestedStringMap(desired, "metadata", field)
+		got, _, _ := unstructured.NestedStringMap(live, "metadata", field)
+		for k, v := range want {
+			if got[k] != v {
+				return true
+			}
+		}
+	}
+	for k, v := range desired {
+		if slices.Contains(driftIgnoredFields, k) {
+			continue
+		}
+		if valueDrifted(v, live[k]) {
+			return true
+		}
+	}
+	return false
+}
+
+// valueDrifted recursively compares a desired value against the live value.
+// Maps are compared as a subset; lists must be the same length, with each
+// item compared as a subset; other values must be equal, after normalizing
+// the values that the apiserver rewrites when storing an object.
+func valueDrifted(desired, live interface{}) bool {
+	switch want := desired.(type) {
+	case map[string]interface{}:
+		got, ok := live.(map[string]interface{})
+		if !ok {
+			return len(want) > 0
+		}
+		for k, v := range want {
+			if valueDrifted(v, got[k]) {
+				return true
+			}
+		}
+		return false
+	case []interface{}:
+		got, ok := live.([]interface{})
+		if !ok || len(got) != len(want) {
+			return len(want) > 0 || len(got) > 0
+		}
+		for i := range want {
+			if valueDrifted(want[i], got[i]) {
+				return true
+			}
+		}
+		return false
+	case nil:
+		return false
+	default:
+		return !reflect.DeepEqual(desired, live) && !normalizedEqual(desired, live)
+	}
+}
+
+// normalizedEqual returns true if the values are numbers of equal value, such as an integer and a float,
+// or are resource quantities of equal value, such as `0.5` and `500m`, or `1024Mi` and `1Gi`.
+func normalizedEqual(desired, live interface{}) bool {
+	if a, ok := number(desired); ok {
+		if b, ok := number(live); ok {
+			return a == b
+		}
+	}
+	a, ok := quantity(desired)
+	if !ok {
+		return false
+	}
+	b, ok := quantity(live)
+	return ok && a.Cmp(b) == 0
+}
+
+// number returns the value as a float, if it is a number.
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// quantity returns the value as a resource quantity, if it is a number, or a string that parses as a quantity.
+func quantity(value interface{}) (resource.Quantity, bool) {
+	s, ok := value.(string)
+	if !ok {
+		f, ok := number(value)
+		if !ok {
+			return resource.Quantity{}, false
+		}
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	q, err := resource.ParseQuantity(s)
+	return q, err == nil
+}
+
+// deployed returns true if the status has a True Ready condition for a deployed release.
+func deployed(chartStatus v1.HelmChartStatus) bool {
+	for _, condition := range chartStatus.Conditions {
+		if condition.Type == v1.HelmChartReady {
+			return condition.Status == corev1.ConditionTrue && condition.Reason == "Deployed"
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package chart
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDriftDetectionInterval(t *testing.T) {
+	assert := assert.New(t)
+	chart := NewChart()
+	assert.Zero(driftDetectionInterval(chart))
+
+	chart.Spec.DriftDetection = &v1.DriftDetection{}
+	assert.Equal(DefaultDriftDetectionInterval, driftDetectionInterval(chart))
+
+	chart.Spec.DriftDetection.Interval = &metav1.Duration{Duration: time.Minute}
+	assert.Equal(time.Minute, driftDetectionInterval(chart))
+}
+
+func TestObjectDrifted(t *testing.T) {
+	desired := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":   "traefik",
+			"labels": map[string]interface{}{"app": "traefik"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "traefik", "image": "traefik:v3"},
+					},
+				},
+			},
+		},
+	}
+
+	live := func(mutate func(obj map[string]interface{})) map[string]interface{} {
+		obj := map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name":            "traefik",
+				"resourceVersion": "12345",
+				"labels":          map[string]interface{}{"app": "traefik", "extra": "true"},
+			},
+			"spec": map[string]interface{}{
+				"replicas":             int64(1),
+				"revisionHistoryLimit": int64(10),
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": []interface{}{
+							map[string]interface{}{"name": "traefik", "image": "traefik:v3", "imagePullPolicy": "IfNotPresent"},
+						},
+					},
+				},
+			},
+			"status": map[string]interface{}{"readyReplicas": int64(1)},
+		}
+		if mutate != nil {
+			mutate(obj)
+		}
+		return obj
+	}
+
+	tests := []struct {
+		name     string
+		live     map[string]interface{}
+		expected bool
+	}{
+		{"defaulted fields ignored", live(nil), false},
+		{"changed scalar", live(func(obj map[string]interface{}) {
+			obj["spec"].(map[string]interface{})["replicas"] = int64(3)
+		}), true},
+		{"changed list item", live(func(obj map[string]interface{}) {
+			obj["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"] = []interface{}{
+				map[string]interface{}{"name": "traefik", "image": "traefik:v2"},
+			}
+		}), true},
+		{"removed label", live(func(obj map[string]interface{}) {
+			obj["metadata"].(map[string]interface{})["labels"] = map[string]interface{}{"extra": "true"}
+		}), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, objectDrifted(desired, tt.live))
+		})
+	}
+}
+
+func TestValueDriftedNormalized(t *testing.T) {
+	tests := []struct {
+		name     string
+		desired  interface{}
+		live     interface{}
+		expected bool
+	}{
+		{"cpu as decimal", "0.5", "500m", false},
+		{"cpu as number", 0.5, "500m", false},
+		{"whole cpu as number", int64(2), "2", false},
+		{"memory in larger unit", "1024Mi", "1Gi", false},
+		{"changed memory", "512Mi", "1Gi", true},
+		{"int and float", int64(1), float64(1), false},
+		{"changed number", int64(1), float64(1.5), true},
+		{"changed string", "traefik:v3", "traefik:v2", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, valueDrifted(tt.desired, tt.live))
+		})
+	}
+}
+
+func TestDriftResynced(t *testing.T) {
+	assert := assert.New(t)
+	message := "1 resources differ from the release manifest: Deployment kube-system/traefik"
+	status := v1.HelmChartStatus{}
+	assert.False(driftResynced(status, message))
+
+	// drift is resynced once; the same drift is not resynced again
+	status.Conditions = []v1.HelmChartCondition{{Type: v1.HelmChartDrifted, Status: corev1.ConditionTrue, Reason: "Resyncing", Message: message}}
+	assert.True(driftResynced(status, message))
+	status.Conditions[0].Reason = "Resync ineffective"
+	status.Conditions[0].Message = message + resyncIneffectiveMessage
+	assert.True(driftResynced(status, message))
+
+	// different drift is resynced
+	assert.False(driftResynced(status, "1 resources differ from the release manifest: Deployment kube-system/traefik-2"))
+	status.Conditions[0].Reason = "Drift detected"
+	status.Conditions[0].Message = message
+	assert.False(driftResynced(status, message))
+}
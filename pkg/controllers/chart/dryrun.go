@@ -0,0 +1,351 @@
+package chart
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/pmezard/go-difflib/difflib"
+	batch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// maxDryRunLogBytes limits the amount of dry-run job output retrieved from the pod log.
+	maxDryRunLogBytes = 4 * 1024 * 1024
+	// maxDiffBytes limits the size of the diff stored in the ConfigMap, to stay well under the max object size.
+	maxDiffBytes = 512 * 1024
+	// redactedValue replaces the contents of Secrets in the manifests stored in the ConfigMap.
+	redactedValue = "[redacted]"
+)
+
+// secretKindPattern matches the kind of a Secret document, for documents that cannot be decoded.
+var secretKindPattern = regexp.MustCompile(`(?m)^kind:\s*Secret\s*$`)
+
+// dryRunScript runs helm upgrade --install --dry-run directly, instead of through the job entrypoint, so that the
+// dry-run job cannot uninstall or roll back the release as the entrypoint does when handling failed or pending
+// releases. The chart, values, credentials and CA bundle are read from the same paths and environment variables
+// as the entrypoint uses; args(chart) is passed as positional parameters, following the leading install action.
+const dryRunScript = `set -e
+shift
+chart="${CHART}"
+if [ -f "/chart/${NAME}.tgz.base64" ]; then
+  base64 -d "/chart/${NAME}.tgz.base64" > "/tmp/${NAME}.tgz"
+  chart="/tmp/${NAME}.tgz"
+elif [ -n "${REPO}" ]; then
+  set -- "$@" --repo "${REPO}"
+  chart="${CHART#*/}"
+fi
+for values in /config/values-*.yaml; do
+  if [ -f "${values}" ]; then
+    set -- "$@" --values "${values}"
+  fi
+done
+if [ -f /auth/username ] && [ -f /auth/password ]; then
+  set -- "$@" --username "$(cat /auth/username)" --password "$(cat /auth/password)"
+fi
+for ca in /config/ca-file.pem /ca-files/*; do
+  if [ -f "${ca}" ]; then
+    cat "${ca}" >> /tmp/ca-file.pem
+  fi
+done
+if [ -f /tmp/ca-file.pem ]; then
+  set -- "$@" --ca-file /tmp/ca-file.pem
+fi
+if [ -f /home/klipper-helm/.docker/config.json ]; then
+  export HELM_REGISTRY_CONFIG=/home/klipper-helm/.docker/config.json
+fi
+if [ "${AUTH_PASS_CREDENTIALS}" = "true" ]; then
+  set -- "$@" --pass-credentials
+fi
+if [ "${INSECURE_SKIP_TLS_VERIFY}" = "true" ]; then
+  set -- "$@" --insecure-skip-tls-verify
+fi
+if [ "${PLAIN_HTTP}" = "true" ]; then
+  set -- "$@" --plain-http
+fi
+if [ -n "${TIMEOUT}" ]; then
+  set -- "$@" --timeout "${TIMEOUT}"
+fi
+exec helm_v3 upgrade --install "${NAME}" "${chart}" --namespace "${TARGET_NAMESPACE}" "$@"
+`
+
+// setDryRun replaces the job's entrypoint with dryRunScript. The install arguments for the chart are
+// passed to the script, which adds the flags for the chart source, values and credentials.
+func setDryRun(job *batch.Job, chart *v1.HelmChart) {
+	job.Spec.Template.Spec.Containers[0].Command = []string{"/bin/sh", "-c", dryRunScript, "helm-dryrun"}
+	job.Spec.Template.Spec.Containers[0].Args = args(chart)
+}
+
+func dryRunConfigMapName(chart *v1.HelmChart) string {
+	return fmt.Sprintf("chart-diff-%s", chart.Name)
+}
+
+// dryRunRecorded returns true if the diff ConfigMap for the chart has already been
+// written with the results of the dry-run job with the current config hash.
+func (c *Controller) dryRunRecorded(chart *v1.HelmChart, job *batch.Job) bool {
+	cm, err := c.configMapCache.Get(chart.Namespace, dryRunConfigMapName(chart))
+	if err != nil {
+		return false
+	}
+	return cm.Annotations[KeyConfigHash] == job.Spec.Template.ObjectMeta.Annotations[KeyConfigHash]
+}
+
+// recordDryRun retrieves the manifest rendered by the completed dry-run job, compares it
+// against the manifest of the deployed release, and writes the diff to a ConfigMap owned
+// by the chart. The returned condition summarizes the differences.
+func (c *Controller) recordDryRun(chart *v1.HelmChart, job *batch.Job) (v1.HelmChartCondition, error) {
+	logs, err := c.jobLogs(job)
+	if err != nil {
+		return v1.HelmChartCondition{}, fmt.Errorf("failed to get dry-run job logs: %w", err)
+	}
+
+	var deployed string
+	if rel, err := c.getDeployedRelease(chart); err != nil {
+		return v1.HelmChartCondition{}, fmt.Errorf("failed to get deployed release: %w", err)
+	} else if rel != nil {
+		deployed = rel.Manifest
+	}
+
+	rendered := dryRunManifest(logs)
+	cm := dryRunConfigMap(chart, job, deployed, rendered)
+
+	if existing, err := c.configMapCache.Get(cm.Namespace, cm.Name); err == nil {
+		existing = existing.DeepCopy()
+		existing.Annotations = cm.Annotations
+		existing.Labels = cm.Labels
+		existing.OwnerReferences = cm.OwnerReferences
+		existing.Data = cm.Data
+		_, err = c.configMapClient.Update(existing)
+		if err != nil {
+			return v1.HelmChartCondition{}, err
+		}
+	} else if apierrors.IsNotFound(err) {
+		if _, err := c.configMapClient.Create(cm); err != nil {
+			return v1.HelmChartCondition{}, err
+		}
+	} else {
+		return v1.HelmChartCondition{}, err
+	}
+
+	added, changed, removed := summarizeManifests(deployed, rendered)
+	condition := v1.HelmChartCondition{
+		Type:    v1.HelmChartDryRun,
+		Status:  corev1.ConditionTrue,
+		Reason:  "No changes",
+		Message: fmt.Sprintf("Dry run matches the deployed release; see ConfigMap %s/%s", cm.Namespace, cm.Name),
+	}
+	if added+changed+removed > 0 {
+		condition.Reason = "Changes pending"
+		condition.Message = fmt.Sprintf("Dry run found %d added, %d changed, and %d removed templates; see ConfigMap %s/%s", added, changed, removed, cm.Namespace, cm.Name)
+	}
+	c.recorder.Event(chart, corev1.EventTypeNormal, "DryRun", condition.Message)
+	return condition, nil
+}
+
+// dryRunConfigMap returns the ConfigMap holding the rendered manifest and its diff against the deployed manifest.
+// The contents of Secrets are redacted from both, as the ConfigMap may be readable by users who cannot read Secrets.
+func dryRunConfigMap(chart *v1.HelmChart, job *batch.Job, deployed, rendered string) *corev1.ConfigMap {
+	deployed, rendered = redactSecrets(deployed), redactSecrets(rendered)
+	diff := diffManifests(deployed, rendered)
+	if len(diff) > maxDiffBytes {
+		diff = diff[:maxDiffBytes] + "\n# diff truncated\n"
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dryRunConfigMapName(chart),
+			Namespace: chart.Namespace,
+			Annotations: map[string]string{
+				KeyConfigHash: job.Spec.Template.ObjectMeta.Annotations[KeyConfigHash],
+			},
+			Labels: map[string]string{
+				LabelChartName: chart.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(chart, v1.SchemeGroupVersion.WithKind("HelmChart")),
+			},
+		},
+		Data: map[string]string{
+			"diff":     diff,
+			"manifest": rendered,
+		},
+	}
+	if len(rendered) > maxDiffBytes {
+		delete(cm.Data, "manifest")
+	}
+	return cm
+}
+
+// removeDryRun deletes the diff ConfigMap for the chart, if one exists.
+func (c *Controller) removeDryRun(chart *v1.HelmChart) error {
+	if _, err := c.configMapCache.Get(chart.Namespace, dryRunConfigMapName(chart)); err != nil {
+		return nil
+	}
+	if err := c.configMapClient.Delete(chart.Namespace, dryRunConfigMapName(chart), &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// jobLogs returns the helm container log of the most recent successful pod for the job.
+func (c *Controller) jobLogs(job *batch.Job) (string, error) {
+	return c.podLogs(job, corev1.PodSucceeded, &corev1.PodLogOptions{
+		Container:  "helm",
+		LimitBytes: ptr.To[int64](maxDryRunLogBytes),
+	})
+}
+
+// podLogs returns the log of the most recent pod for the job that is in the specified phase.
+func (c *Controller) podLogs(job *batch.Job, phase corev1.PodPhase, opts *corev1.PodLogOptions) (string, error) {
+	pod, err := c.jobPod(job, func(pod *corev1.Pod) bool { return pod.Status.Phase == phase })
+	if err != nil {
+		return "", err
+	}
+	if pod == nil {
+		return "", fmt.Errorf("no %s pods found for job %s/%s", strings.ToLower(string(phase)), job.Namespace, job.Name)
+	}
+	b, err := c.pods.Pods(pod.Namespace).GetLogs(pod.Name, opts).DoRaw(context.TODO())
+	return string(b), err
+}
+
+// jobPod returns the most recent pod for the job that matches the filter, or nil if there is none.
+func (c *Controller) jobPod(job *batch.Job, filter func(*corev1.Pod) bool) (*corev1.Pod, error) {
+	ls := labels.Set{"job-name": job.Name}.AsSelector()
+	podList, err := c.pods.Pods(job.Namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: ls.String()})
+	if err != nil {
+		return nil, err
+	}
+	var pod *corev1.Pod
+	for i := range podList.Items {
+		p := &podList.Items[i]
+		if !filter(p) {
+			continue
+		}
+		if pod == nil || pod.CreationTimestamp.Before(&p.CreationTimestamp) {
+			pod = p
+		}
+	}
+	return pod, nil
+}
+
+// dryRunManifest extracts the rendered manifest from the output of helm install or upgrade
+// with --dry-run. The manifest follows the MANIFEST: heading, and ends at the NOTES: heading,
+// or at the first shell trace line emitted by the job entrypoint.
+func dryRunManifest(logs string) string {
+	_, manifest, found := strings.Cut(logs, "\nMANIFEST:\n")
+	if !found {
+		return ""
+	}
+	lines := strings.Split(manifest, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "NOTES:") || strings.HasPrefix(line, "+ ") {
+			lines = lines[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")) + "\n"
+}
+
+// redactSecrets returns the manifest with the values in the data and stringData of each Secret replaced by
+// redactedValue. Secret documents are re-encoded, but are preceded by their original Source comment; other
+// documents are not modified. Secret documents that cannot be decoded are replaced entirely.
+func redactSecrets(manifest string) string {
+	docs := strings.Split(manifest, "\n---")
+	for i, doc := range docs {
+		docs[i] = redactSecret(doc)
+	}
+	return strings.Join(docs, "\n---")
+}
+
+// redactSecret returns the document with the contents of the Secret redacted, if it is a Secret.
+func redactSecret(doc string) string {
+	lines := strings.Split(doc, "\n")
+	header := 0
+	for header < len(lines) && (lines[header] == "" || lines[header] == "---" || strings.HasPrefix(lines[header], "#")) {
+		header++
+	}
+	obj := map[string]any{}
+	if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+		if !secretKindPattern.MatchString(doc) {
+			return doc
+		}
+		obj = map[string]any{"kind": "Secret", "data": redactedValue}
+	} else if obj["kind"] != "Secret" {
+		return doc
+	}
+	for _, field := range []string{"data", "stringData"} {
+		if values, ok := obj[field].(map[string]any); ok {
+			for key := range values {
+				values[key] = redactedValue
+			}
+		}
+	}
+	b, err := yaml.Marshal(obj)
+	if err != nil {
+		return strings.Join(lines[:header], "\n")
+	}
+	redacted := strings.Join(append(lines[:header:header], strings.TrimSuffix(string(b), "\n")), "\n")
+	if strings.HasSuffix(doc, "\n") {
+		redacted += "\n"
+	}
+	return redacted
+}
+
+// diffManifests returns a unified diff between the deployed and rendered manifests.
+func diffManifests(deployed, rendered string) string {
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(deployed),
+		B:        difflib.SplitLines(rendered),
+		FromFile: "deployed",
+		ToFile:   "dry-run",
+		Context:  3,
+	})
+	return diff
+}
+
+// summarizeManifests returns the number of templates added, changed, and removed
+// between the deployed and rendered manifests. Templates are identified by the
+// Source comment that helm adds to each rendered document.
+func summarizeManifests(deployed, rendered string) (added, changed, removed int) {
+	oldTemplates := manifestTemplates(deployed)
+	newTemplates := manifestTemplates(rendered)
+	for source, doc := range newTemplates {
+		if oldDoc, ok := oldTemplates[source]; !ok {
+			added++
+		} else if oldDoc != doc {
+			changed++
+		}
+	}
+	for source := range oldTemplates {
+		if _, ok := newTemplates[source]; !ok {
+			removed++
+		}
+	}
+	return added, changed, removed
+}
+
+// manifestTemplates splits a manifest into documents, grouped by their Source comment.
+func manifestTemplates(manifest string) map[string]string {
+	templates := map[string]string{}
+	for _, doc := range strings.Split(manifest, "\n---") {
+		doc = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(doc), "---"))
+		if doc == "" {
+			continue
+		}
+		source, _, _ := strings.Cut(doc, "\n")
+		if !strings.HasPrefix(source, "# Source: ") {
+			source = ""
+		}
+		templates[source] = strings.Join(slices.DeleteFunc([]string{templates[source], doc}, func(s string) bool { return s == "" }), "\n---\n")
+	}
+	return templates
+}
@@ -0,0 +1,136 @@
+package chart
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const dryRunLogs = `+ helm_v3 upgrade traefik traefik/traefik --dry-run
+Release "traefik" has been upgraded. Happy Helming!
+NAME: traefik
+STATUS: pending-upgrade
+REVISION: 3
+HOOKS:
+MANIFEST:
+---
+# Source: traefik/templates/sa.yaml
+kind: ServiceAccount
+---
+# Source: traefik/templates/deployment.yaml
+kind: Deployment
+replicas: 2
+
+NOTES:
+Traefik is running.
++ exit
+`
+
+func TestDryRunManifest(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("---\n"+
+		"# Source: traefik/templates/sa.yaml\n"+
+		"kind: ServiceAccount\n"+
+		"---\n"+
+		"# Source: traefik/templates/deployment.yaml\n"+
+		"kind: Deployment\n"+
+		"replicas: 2\n",
+		dryRunManifest(dryRunLogs))
+	assert.Empty(dryRunManifest("Error: failed to render chart"))
+}
+
+func TestSummarizeManifests(t *testing.T) {
+	deployed := "---\n" +
+		"# Source: traefik/templates/deployment.yaml\n" +
+		"kind: Deployment\n" +
+		"replicas: 1\n" +
+		"---\n" +
+		"# Source: traefik/templates/service.yaml\n" +
+		"kind: Service\n"
+	rendered := dryRunManifest(dryRunLogs)
+
+	tests := []struct {
+		name     string
+		deployed string
+		rendered string
+		expected [3]int
+	}{
+		{"not installed", "", rendered, [3]int{2, 0, 0}},
+		{"no changes", rendered, rendered, [3]int{0, 0, 0}},
+		{"changes", deployed, rendered, [3]int{1, 1, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, changed, removed := summarizeManifests(tt.deployed, tt.rendered)
+			assert.Equal(t, tt.expected, [3]int{added, changed, removed})
+		})
+	}
+}
+
+func TestDiffManifests(t *testing.T) {
+	assert := assert.New(t)
+	rendered := dryRunManifest(dryRunLogs)
+	assert.Empty(diffManifests(rendered, rendered))
+	assert.Contains(diffManifests("", rendered), "+kind: Deployment\n")
+}
+
+func TestDecodeRelease(t *testing.T) {
+	assert := assert.New(t)
+	payload := []byte(`{"name":"traefik","manifest":"kind: Deployment\n"}`)
+
+	rel, err := decodeRelease(base64.StdEncoding.EncodeToString(payload))
+	assert.NoError(err)
+	assert.Equal("kind: Deployment\n", rel.Manifest)
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, _ = w.Write(payload)
+	_ = w.Close()
+	rel, err = decodeRelease(base64.StdEncoding.EncodeToString(buf.Bytes()))
+	assert.NoError(err)
+	assert.Equal("kind: Deployment\n", rel.Manifest)
+
+	_, err = decodeRelease("not base64!")
+	assert.Error(err)
+}
+
+func TestDryRunConfigMapRedactsSecrets(t *testing.T) {
+	assert := assert.New(t)
+	chart := NewChart()
+	installJob, _, _ := job(chart, "6443")
+	deployed := "---\n" +
+		"# Source: traefik/templates/secret.yaml\n" +
+		"apiVersion: v1\n" +
+		"kind: Secret\n" +
+		"metadata:\n" +
+		"  name: traefik-auth\n" +
+		"data:\n" +
+		"  password: ZGVwbG95ZWQtcGFzc3dvcmQ=\n"
+	rendered := dryRunManifest(dryRunLogs) +
+		"---\n" +
+		"# Source: traefik/templates/secret.yaml\n" +
+		"apiVersion: v1\n" +
+		"kind: Secret\n" +
+		"metadata:\n" +
+		"  name: traefik-auth\n" +
+		"stringData:\n" +
+		"  password: |\n" +
+		"    rendered-password\n"
+
+	cm := dryRunConfigMap(chart, installJob, deployed, rendered)
+	for key, value := range cm.Data {
+		assert.NotContains(value, "ZGVwbG95ZWQtcGFzc3dvcmQ=", key)
+		assert.NotContains(value, "rendered-password", key)
+	}
+	assert.Contains(cm.Data["manifest"], "# Source: traefik/templates/secret.yaml\napiVersion: v1\nkind: Secret\n")
+	assert.Contains(cm.Data["manifest"], "password: '[redacted]'")
+	assert.Contains(cm.Data["manifest"], "# Source: traefik/templates/deployment.yaml\nkind: Deployment\nreplicas: 2\n")
+	assert.Contains(cm.Data["diff"], "+kind: Deployment\n")
+
+	// secrets that cannot be decoded are replaced entirely
+	assert.Equal("# Source: a.yaml\ndata: '[redacted]'\nkind: Secret", redactSecret("# Source: a.yaml\nkind: Secret\ndata: [unterminated"))
+}
@@ -0,0 +1,128 @@
+package chart
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	batch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// maxFailureHistory limits the number of job failures kept in the chart status.
+	maxFailureHistory = 10
+	// maxFailureLogLines limits the number of lines of pod log retrieved, if the pod has no termination message.
+	maxFailureLogLines = 20
+	// maxFailureMessageBytes limits the length of the failure message recorded in the chart status.
+	maxFailureMessageBytes = 1024
+)
+
+// failedJob returns the job for the given chart, along with its Failed condition,
+// if the job controller has added a True Failed condition to the job.
+func (c *Controller) failedJob(chart *v1.HelmChart) (*batch.Job, *batch.JobCondition) {
+	job, _ := c.jobCache.Get(chart.Namespace, jobName(chart))
+	if job == nil {
+		return nil, nil
+	}
+	for i, condition := range job.Status.Conditions {
+		if condition.Type == batch.JobFailed {
+			if condition.Status != corev1.ConditionTrue {
+				return nil, nil
+			}
+			return job, &job.Status.Conditions[i]
+		}
+	}
+	return nil, nil
+}
+
+// recordJobFailure adds the failure of the job to the status history, and returns it along with whether it
+// was newly recorded. The pod of a failed job is only inspected once; if the failure has already been
+// recorded, the existing record is returned.
+func (c *Controller) recordJobFailure(job *batch.Job, condition *batch.JobCondition, chartStatus *v1.HelmChartStatus) (v1.HelmChartFailure, bool) {
+	failure := v1.HelmChartFailure{
+		Time:       condition.LastTransitionTime,
+		JobName:    job.Name,
+		ConfigHash: configHash(job),
+		Reason:     condition.Reason,
+	}
+	if history := chartStatus.History; len(history) > 0 {
+		last := history[0]
+		if last.JobName == failure.JobName && last.ConfigHash == failure.ConfigHash && last.Time.Equal(&failure.Time) {
+			return last, false
+		}
+	}
+	failure.Message = c.jobFailureMessage(job, condition)
+	addFailure(chartStatus, failure)
+	return failure, true
+}
+
+// addFailure adds the failure to the start of the status history, limited to maxFailureHistory entries.
+func addFailure(chartStatus *v1.HelmChartStatus, failure v1.HelmChartFailure) {
+	chartStatus.History = append([]v1.HelmChartFailure{failure}, chartStatus.History...)
+	if len(chartStatus.History) > maxFailureHistory {
+		chartStatus.History = chartStatus.History[:maxFailureHistory]
+	}
+}
+
+// jobFailureMessage returns the termination message of the helm container in the most recent pod for the
+// job, or the tail of its log if it has no termination message. Shell trace lines are removed, as they may
+// include credentials passed to helm. If the pod is no longer available, the message from the Failed
+// condition of the job is returned.
+func (c *Controller) jobFailureMessage(job *batch.Job, condition *batch.JobCondition) string {
+	pod, err := c.jobPod(job, func(*corev1.Pod) bool { return true })
+	if err != nil || pod == nil {
+		return trimFailureMessage(condition.Message)
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != "helm" {
+			continue
+		}
+		// the log of the previous container is retrieved if the container has restarted since it failed
+		terminated, previous := status.State.Terminated, false
+		if terminated == nil {
+			terminated, previous = status.LastTerminationState.Terminated, true
+		}
+		if terminated == nil {
+			break
+		}
+		if message := stripShellTrace(terminated.Message); message != "" {
+			return trimFailureMessage(message)
+		}
+		b, err := c.pods.Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+			Container: "helm",
+			Previous:  previous,
+			TailLines: ptr.To[int64](maxFailureLogLines),
+		}).DoRaw(context.TODO())
+		if logs := stripShellTrace(string(b)); err == nil && logs != "" {
+			return trimFailureMessage(logs)
+		}
+	}
+	return trimFailureMessage(condition.Message)
+}
+
+// stripShellTrace returns the output with the lines traced by `set -x` removed. Traced commands are
+// prefixed by one `+` for each level of nesting, followed by a space.
+func stripShellTrace(output string) string {
+	lines := strings.Split(output, "\n")
+	lines = slices.DeleteFunc(lines, func(line string) bool {
+		return strings.HasPrefix(line, "+") && strings.HasPrefix(strings.TrimLeft(line, "+"), " ")
+	})
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// trimFailureMessage returns the end of the message, limited to maxFailureMessageBytes. Errors are usually
+// reported at the end of the output, so the start of the message is dropped, up to the next full line.
+func trimFailureMessage(message string) string {
+	message = strings.TrimSpace(message)
+	if len(message) <= maxFailureMessageBytes {
+		return message
+	}
+	message = message[len(message)-maxFailureMessageBytes:]
+	if _, rest, found := strings.Cut(message, "\n"); found {
+		message = rest
+	}
+	return "..." + strings.ToValidUTF8(message, "")
+}
@@ -0,0 +1,102 @@
+package chart
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	batch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRecordJobFailure(t *testing.T) {
+	assert := assert.New(t)
+	chart := NewChart()
+	failed, _, _ := job(chart, "6443")
+	failed.Status.Conditions = []batch.JobCondition{{
+		Type:               batch.JobFailed,
+		Status:             corev1.ConditionTrue,
+		Reason:             "BackoffLimitExceeded",
+		Message:            "Job has reached the specified backoff limit",
+		LastTransitionTime: metav1.Now(),
+	}}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "helm-install-traefik-abcde", Namespace: chart.Namespace, Labels: map[string]string{"job-name": failed.Name}},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "helm",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Message: "Error: INSTALLATION FAILED: timed out\n"}},
+			}},
+		},
+	}
+	c := &Controller{
+		jobCache: fakeJobCache{jobs: []*batch.Job{failed}},
+		pods:     fake.NewClientset(pod).CoreV1(),
+	}
+
+	job, condition := c.failedJob(chart)
+	if !assert.NotNil(job) {
+		return
+	}
+	chartStatus := v1.HelmChartStatus{}
+	failure, recorded := c.recordJobFailure(job, condition, &chartStatus)
+	assert.True(recorded)
+	assert.Equal("Error: INSTALLATION FAILED: timed out", failure.Message)
+	assert.Equal("BackoffLimitExceeded", failure.Reason)
+	assert.Equal(configHash(job), failure.ConfigHash)
+	assert.Len(chartStatus.History, 1)
+
+	// the failure is only recorded once
+	_, recorded = c.recordJobFailure(job, condition, &chartStatus)
+	assert.False(recorded)
+	assert.Len(chartStatus.History, 1)
+
+	// the log tail is used if the container has no termination message, and the history is bounded
+	pod.Status.ContainerStatuses[0].State = corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	pod.Status.ContainerStatuses[0].LastTerminationState = corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1}}
+	c.pods = fake.NewClientset(pod).CoreV1()
+	for i := range maxFailureHistory {
+		condition.LastTransitionTime = metav1.NewTime(time.Now().Add(time.Duration(i+1) * time.Minute))
+		failure, recorded = c.recordJobFailure(job, condition, &chartStatus)
+		assert.True(recorded)
+	}
+	assert.Equal("fake logs", failure.Message)
+	assert.Len(chartStatus.History, maxFailureHistory)
+	assert.Equal(failure, chartStatus.History[0])
+
+	// the condition message is used if the pod is gone
+	c.pods = fake.NewClientset().CoreV1()
+	condition.LastTransitionTime = metav1.NewTime(time.Now().Add(time.Hour))
+	failure, _ = c.recordJobFailure(job, condition, &chartStatus)
+	assert.Equal("Job has reached the specified backoff limit", failure.Message)
+
+	// jobs that have not failed are ignored
+	condition.Status = corev1.ConditionFalse
+	job, _ = c.failedJob(chart)
+	assert.Nil(job)
+}
+
+func TestTrimFailureMessage(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("error", trimFailureMessage("  error\n"))
+
+	message := trimFailureMessage(strings.Repeat("line\n", maxFailureMessageBytes) + "Error: failed")
+	assert.LessOrEqual(len(message), maxFailureMessageBytes+3)
+	assert.True(strings.HasPrefix(message, "...line\n"))
+	assert.True(strings.HasSuffix(message, "Error: failed"))
+}
+
+func TestStripShellTrace(t *testing.T) {
+	assert := assert.New(t)
+	output := "+ helm_v3 repo add stable https://charts.example.com --username admin --password secret\n" +
+		"++ echo values.yaml\n" +
+		"Installing chart\n" +
+		"+++\n" +
+		"Error: INSTALLATION FAILED: timed out waiting for the condition\n"
+	assert.Equal("Installing chart\n+++\nError: INSTALLATION FAILED: timed out waiting for the condition", stripShellTrace(output))
+	assert.Empty(stripShellTrace("+ exit 1\n"))
+}
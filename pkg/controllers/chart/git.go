@@ -0,0 +1,410 @@
+package chart
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"golang.org/x/crypto/ssh"
+	batch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// defaultGitInterval is the interval at which git refs are polled for new commits, if not specified.
+	defaultGitInterval = 5 * time.Minute
+	// gitTimeout limits the time spent listing refs and fetching the repository, so that an
+	// unresponsive git server does not hold up polling of the chart indefinitely.
+	gitTimeout = 2 * time.Minute
+	// gitRetryInterval is the time for which a failure to fetch a chart from git is cached, before the
+	// repository is fetched again.
+	gitRetryInterval = time.Minute
+)
+
+// errGitPending is returned while the chart is fetched from its git source for the first time.
+var errGitPending = errors.New("waiting for the chart to be fetched from git")
+
+// gitChart holds the chart packaged from a git source at a resolved commit. If the most recent fetch
+// failed, the error is held along with the chart packaged by the last successful fetch, if any.
+type gitChart struct {
+	source  v1.GitSource
+	auth    string
+	commit  string
+	content string
+	checked time.Time
+	err     error
+}
+
+// gitInterval returns the interval at which the chart's git ref is polled for new commits.
+func gitInterval(chart *v1.HelmChart) time.Duration {
+	if chart.Spec.Git.Interval != nil {
+		return chart.Spec.Git.Interval.Duration
+	}
+	return defaultGitInterval
+}
+
+// resolveGitChart returns a copy of the chart with the chart content set to the chart packaged from
+// the chart's git source, along with the resolved commit. The repository is fetched in the background,
+// so that a slow repository does not block the reconcile worker; errGitPending is returned until the
+// chart has been packaged for the first time. The packaged chart is cached, and the repository is only
+// fetched again if the source or auth secret changes, or polling finds that the ref has moved. Failed
+// fetches are cached, and retried once the retry interval has passed.
+func (c *Controller) resolveGitChart(chart *v1.HelmChart) (*v1.HelmChart, string, error) {
+	key := chart.Namespace + "/" + chart.Name
+	source := chart.Spec.Git
+	interval := gitInterval(chart)
+
+	auth, authVersion, err := c.gitAuth(chart)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var cached *gitChart
+	if obj, ok := c.gitCharts.Load(key); ok {
+		cached = obj.(*gitChart)
+		if !equality.Semantic.DeepEqual(cached.source, *source) || cached.auth != authVersion {
+			cached = nil
+		}
+	}
+
+	// the chart is enqueued when polling is next due, or the failed fetch should be retried
+	next := time.Duration(0)
+	if cached != nil && cached.err != nil {
+		next = gitRetryInterval
+	} else if source.Commit == "" && interval > 0 {
+		next = interval
+	}
+	if cached == nil || (next > 0 && time.Since(cached.checked) >= next) {
+		c.fetchGitChart(chart, cached, auth, authVersion)
+		if cached == nil || cached.content == "" {
+			return nil, "", errGitPending
+		}
+	} else if next > 0 {
+		c.helms.EnqueueAfter(chart.Namespace, chart.Name, next-time.Since(cached.checked))
+	}
+	if cached.content == "" {
+		return nil, "", cached.err
+	}
+
+	chart = chart.DeepCopy()
+	chart.Spec.ChartContent = cached.content
+	return chart, cached.commit, nil
+}
+
+// fetchGitChart fetches and packages the chart from its git source in the background, unless a fetch
+// is already in progress for the chart. The result is stored in the cache, and the chart is enqueued
+// once the fetch completes.
+func (c *Controller) fetchGitChart(chart *v1.HelmChart, cached *gitChart, auth transport.AuthMethod, authVersion string) {
+	key := chart.Namespace + "/" + chart.Name
+	if _, loaded := c.gitFetches.LoadOrStore(key, true); loaded {
+		return
+	}
+	chart = chart.DeepCopy()
+	go func() {
+		defer c.gitFetches.Delete(key)
+		result := c.pullGitChart(chart, cached, auth, authVersion)
+		// the result is discarded if the chart was removed while it was being fetched
+		if _, err := c.helmCache.Get(chart.Namespace, chart.Name); apierrors.IsNotFound(err) {
+			return
+		}
+		c.gitCharts.Store(key, result)
+		c.helms.Enqueue(chart.Namespace, chart.Name)
+	}()
+}
+
+// pullGitChart resolves the commit for the chart's git source, and packages the chart if the commit has
+// changed since the cached chart was packaged. If the fetch fails, the error is returned along with the
+// previously packaged chart, so that the job is not changed until the chart can be fetched again.
+func (c *Controller) pullGitChart(chart *v1.HelmChart, cached *gitChart, auth transport.AuthMethod, authVersion string) *gitChart {
+	key := chart.Namespace + "/" + chart.Name
+	source := chart.Spec.Git
+	result := &gitChart{source: *source.DeepCopy(), auth: authVersion, checked: time.Now()}
+	if cached != nil {
+		result.commit, result.content = cached.commit, cached.content
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+	commit := source.Commit
+	refName := plumbing.HEAD
+	if commit == "" {
+		ref, err := resolveGitRef(ctx, source.URL, source.Ref, auth)
+		if err != nil {
+			result.err = err
+		} else {
+			commit = ref.Hash().String()
+			refName = ref.Name()
+		}
+	}
+	if result.err == nil && (result.content == "" || result.commit != commit) {
+		content, err := packageGitChart(ctx, chart, source, refName, commit, auth)
+		if err != nil {
+			result.err = err
+		} else {
+			result.commit, result.content = commit, content
+			c.logger.V(1).Info("Packaged chart from git repository",
+				"chart.name", key,
+				"git.url", source.URL,
+				"git.commit", commit,
+			)
+		}
+	}
+	if result.err != nil {
+		c.logger.Error(result.err, "Failed to fetch chart from git repository",
+			"chart.name", key,
+			"git.url", source.URL,
+		)
+	}
+	return result
+}
+
+// gitAuth returns the auth method for the chart's git source, based on the contents of the auth secret,
+// along with the resource version of the secret, so that cached charts can be refreshed when it changes.
+// The SSH host key must be verified against known_hosts from the secret, unless explicitly disabled.
+func (c *Controller) gitAuth(chart *v1.HelmChart) (transport.AuthMethod, string, error) {
+	if chart.Spec.Git.AuthSecret == nil || chart.Spec.Git.AuthSecret.Name == "" {
+		return nil, "", nil
+	}
+	secret, err := c.secretCache.Get(chart.Namespace, chart.Spec.Git.AuthSecret.Name)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get git auth secret: %w", err)
+	}
+
+	if key, ok := secret.Data[corev1.SSHAuthPrivateKey]; ok {
+		auth, err := gitssh.NewPublicKeys("git", key, "")
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to parse git ssh private key: %w", err)
+		}
+		if knownHosts, ok := secret.Data["known_hosts"]; ok {
+			if auth.HostKeyCallback, err = knownHostsCallback(knownHosts); err != nil {
+				return nil, "", err
+			}
+		} else if chart.Spec.Git.InsecureIgnoreHostKey {
+			auth.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+		} else {
+			return nil, "", fmt.Errorf("git auth secret %s does not contain known_hosts, and insecureIgnoreHostKey is not set", secret.Name)
+		}
+		return auth, secret.ResourceVersion, nil
+	}
+
+	username := string(secret.Data[corev1.BasicAuthUsernameKey])
+	if username == "" {
+		username = "git"
+	}
+	password := string(secret.Data[corev1.BasicAuthPasswordKey])
+	if token, ok := secret.Data["token"]; ok {
+		password = string(token)
+	}
+	return &githttp.BasicAuth{Username: username, Password: password}, secret.ResourceVersion, nil
+}
+
+// knownHostsCallback returns a host key callback that accepts any of the keys in the provided known_hosts data.
+func knownHostsCallback(data []byte) (ssh.HostKeyCallback, error) {
+	var keys [][]byte
+	for len(data) > 0 {
+		_, _, key, _, rest, err := ssh.ParseKnownHosts(data)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse known_hosts: %w", err)
+		}
+		keys = append(keys, key.Marshal())
+		data = rest
+	}
+	return func(hostname string, _ net.Addr, key ssh.PublicKey) error {
+		for _, k := range keys {
+			if bytes.Equal(k, key.Marshal()) {
+				return nil
+			}
+		}
+		return fmt.Errorf("host key for %s not found in known_hosts", hostname)
+	}, nil
+}
+
+// resolveGitRef lists the refs of the remote repository, and returns the one matching the requested ref.
+// Branches are preferred over tags, and annotated tags are resolved to the tagged commit.
+func resolveGitRef(ctx context.Context, url, ref string, auth transport.AuthMethod) (*plumbing.Reference, error) {
+	remote := git.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{Name: "origin", URLs: []string{url}})
+	refs, err := remote.ListContext(ctx, &git.ListOptions{Auth: auth, PeelingOption: git.AppendPeeled})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refs for %s: %w", url, err)
+	}
+
+	byName := map[plumbing.ReferenceName]*plumbing.Reference{}
+	for _, r := range refs {
+		byName[r.Name()] = r
+	}
+
+	candidates := []plumbing.ReferenceName{plumbing.HEAD}
+	if ref != "" {
+		candidates = []plumbing.ReferenceName{
+			plumbing.ReferenceName(ref),
+			plumbing.NewBranchReferenceName(ref),
+			plumbing.NewTagReferenceName(ref),
+		}
+	}
+	for _, name := range candidates {
+		r, ok := byName[name]
+		if !ok {
+			continue
+		}
+		if r.Type() == plumbing.SymbolicReference {
+			if r, ok = byName[r.Target()]; !ok {
+				continue
+			}
+		}
+		if peeled, ok := byName[plumbing.ReferenceName(r.Name().String()+"^{}")]; ok {
+			return plumbing.NewHashReference(r.Name(), peeled.Hash()), nil
+		}
+		return r, nil
+	}
+	if ref == "" {
+		ref = string(plumbing.HEAD)
+	}
+	return nil, fmt.Errorf("ref %s not found in %s", ref, url)
+}
+
+// packageGitChart fetches the repository, and returns the base64-encoded tarball of the chart
+// directory at the requested commit.
+func packageGitChart(ctx context.Context, chart *v1.HelmChart, source *v1.GitSource, refName plumbing.ReferenceName, commit string, auth transport.AuthMethod) (string, error) {
+	repo, err := fetchGitCommit(ctx, source, refName, commit, auth)
+	if err != nil {
+		return "", fmt.Errorf("failed to clone %s: %w", source.URL, err)
+	}
+	obj, err := repo.CommitObject(plumbing.NewHash(commit))
+	if err != nil {
+		return "", fmt.Errorf("failed to find commit %s: %w", commit, err)
+	}
+	tree, err := obj.Tree()
+	if err != nil {
+		return "", err
+	}
+	chartPath := strings.Trim(path.Clean("/"+source.Path), "/")
+	if chartPath != "" {
+		if tree, err = tree.Tree(chartPath); err != nil {
+			return "", fmt.Errorf("failed to find path %s at commit %s: %w", source.Path, commit, err)
+		}
+	}
+
+	b, err := tarGitTree(tree, chart.Name)
+	if err != nil {
+		return "", err
+	}
+	if len(b) > maxChartContentBytes {
+		return "", fmt.Errorf("packaged chart is %d bytes, which exceeds the maximum of %d bytes", len(b), maxChartContentBytes)
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// fetchGitCommit fetches the requested commit into an in-memory repository. Only the tip of the ref is
+// fetched when tracking a ref. A specific commit is fetched by hash if the server allows it; otherwise
+// the full history must be cloned to find the commit.
+func fetchGitCommit(ctx context.Context, source *v1.GitSource, refName plumbing.ReferenceName, commit string, auth transport.AuthMethod) (*git.Repository, error) {
+	opts := &git.CloneOptions{
+		URL:        source.URL,
+		Auth:       auth,
+		NoCheckout: true,
+		Tags:       git.NoTags,
+	}
+	if source.Commit == "" {
+		opts.ReferenceName = refName
+		opts.SingleBranch = true
+		opts.Depth = 1
+		return git.CloneContext(ctx, memory.NewStorage(), nil, opts)
+	}
+
+	repo, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := repo.CreateRemote(&gitconfig.RemoteConfig{Name: "origin", URLs: []string{source.URL}})
+	if err != nil {
+		return nil, err
+	}
+	err = remote.FetchContext(ctx, &git.FetchOptions{
+		Auth:     auth,
+		Depth:    1,
+		Tags:     git.NoTags,
+		RefSpecs: []gitconfig.RefSpec{gitconfig.RefSpec(commit + ":refs/heads/commit")},
+	})
+	if errors.Is(err, git.ErrExactSHA1NotSupported) {
+		return git.CloneContext(ctx, memory.NewStorage(), nil, opts)
+	}
+	return repo, err
+}
+
+// tarGitTree writes the regular files of the tree to a gzipped tarball, under the provided directory name.
+func tarGitTree(tree *object.Tree, dir string) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	err := tree.Files().ForEach(func(f *object.File) error {
+		if f.Mode != filemode.Regular && f.Mode != filemode.Executable {
+			return nil
+		}
+		r, err := f.Reader()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		if err := tw.WriteHeader(&tar.Header{
+			Name: path.Join(dir, f.Name),
+			Mode: 0644,
+			Size: f.Size,
+		}); err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, r)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to package chart: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// setGitCommit adds the resolved git commit to the job env, so that it is included in the config hash.
+func setGitCommit(job *batch.Job, commit string) {
+	job.Spec.Template.Spec.Containers[0].Env = append(job.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+		Name:  "GIT_COMMIT",
+		Value: commit,
+	})
+}
+
+// gitCommit returns the git commit from the job env, if set.
+func gitCommit(job *batch.Job) string {
+	for _, env := range job.Spec.Template.Spec.Containers[0].Env {
+		if env.Name == "GIT_COMMIT" {
+			return env.Value
+		}
+	}
+	return ""
+}
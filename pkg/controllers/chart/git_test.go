@@ -0,0 +1,186 @@
+package chart
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// newGitRepo creates a local git repository containing a chart in the charts/traefik directory.
+func newGitRepo(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"README.md":                        "# charts\n",
+		"charts/traefik/Chart.yaml":        "apiVersion: v2\nname: traefik\nversion: 1.0.0\n",
+		"charts/traefik/templates/sa.yaml": "kind: ServiceAccount\n",
+		"charts/traefik/values.yaml":       "replicas: 1\n",
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash, err := wt.Commit("initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, hash.String()
+}
+
+func TestResolveGitRef(t *testing.T) {
+	assert := assert.New(t)
+	dir, commit := newGitRepo(t)
+
+	ref, err := resolveGitRef(context.Background(), dir, "", nil)
+	assert.NoError(err)
+	assert.Equal(commit, ref.Hash().String())
+
+	ref, err = resolveGitRef(context.Background(), dir, "master", nil)
+	assert.NoError(err)
+	assert.Equal("refs/heads/master", ref.Name().String())
+	assert.Equal(commit, ref.Hash().String())
+
+	_, err = resolveGitRef(context.Background(), dir, "missing", nil)
+	assert.Error(err)
+}
+
+func TestPackageGitChart(t *testing.T) {
+	assert := assert.New(t)
+	dir, commit := newGitRepo(t)
+	chart := NewChart()
+	source := &v1.GitSource{URL: dir, Path: "/charts/traefik/"}
+
+	ref, err := resolveGitRef(context.Background(), source.URL, source.Ref, nil)
+	assert.NoError(err)
+	content, err := packageGitChart(context.Background(), chart, source, ref.Name(), commit, nil)
+	assert.NoError(err)
+
+	b, err := base64.StdEncoding.DecodeString(content)
+	assert.NoError(err)
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	assert.NoError(err)
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(err)
+		names = append(names, hdr.Name)
+	}
+	assert.ElementsMatch([]string{"traefik/Chart.yaml", "traefik/templates/sa.yaml", "traefik/values.yaml"}, names)
+
+	source.Path = "missing"
+	_, err = packageGitChart(context.Background(), chart, source, ref.Name(), commit, nil)
+	assert.Error(err)
+
+	// a pinned commit is fetched without resolving a ref
+	source.Path = "charts/traefik"
+	source.Commit = commit
+	_, err = packageGitChart(context.Background(), chart, source, "", commit, nil)
+	assert.NoError(err)
+}
+
+func TestResolveGitChart(t *testing.T) {
+	assert := assert.New(t)
+	dir, commit := newGitRepo(t)
+	chart := NewChart()
+	chart.Spec.Git = &v1.GitSource{URL: dir, Path: "charts/traefik"}
+	helms := &fakeHelmChartController{enqueued: make(chan string, 1)}
+	c := &Controller{
+		logger:    klog.Background(),
+		helms:     helms,
+		helmCache: fakeChartCache{charts: []*v1.HelmChart{chart}},
+	}
+	waitForFetch := func() {
+		select {
+		case key := <-helms.enqueued:
+			assert.Equal("kube-system/traefik", key)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for git fetch")
+		}
+	}
+
+	// the chart is fetched in the background, and enqueued once it has been packaged
+	_, _, err := c.resolveGitChart(chart)
+	assert.ErrorIs(err, errGitPending)
+	waitForFetch()
+	resolved, resolvedCommit, err := c.resolveGitChart(chart)
+	assert.NoError(err)
+	assert.Equal(commit, resolvedCommit)
+	assert.NotEmpty(resolved.Spec.ChartContent)
+
+	// failures are cached, and not fetched again until the retry interval has passed
+	chart.Spec.Git.Path = "missing"
+	_, _, err = c.resolveGitChart(chart)
+	assert.ErrorIs(err, errGitPending)
+	waitForFetch()
+	for range 2 {
+		_, _, err = c.resolveGitChart(chart)
+		assert.ErrorContains(err, "failed to find path missing")
+	}
+	assert.Empty(helms.enqueued)
+}
+
+func TestGitAuth(t *testing.T) {
+	assert := assert.New(t)
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(err)
+	block, err := ssh.MarshalPrivateKey(key, "")
+	assert.NoError(err)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "git-auth", ResourceVersion: "1"},
+		Type:       corev1.SecretTypeSSHAuth,
+		Data:       map[string][]byte{corev1.SSHAuthPrivateKey: pem.EncodeToMemory(block)},
+	}
+	c := &Controller{secretCache: fakeSecretCache{secrets: []*corev1.Secret{secret}}}
+	chart := NewChart()
+	chart.Spec.Git = &v1.GitSource{URL: "ssh://git@example.com/charts.git", AuthSecret: &corev1.LocalObjectReference{Name: "git-auth"}}
+
+	// host keys must be verified unless explicitly disabled
+	_, _, err = c.gitAuth(chart)
+	assert.ErrorContains(err, "known_hosts")
+
+	chart.Spec.Git.InsecureIgnoreHostKey = true
+	auth, version, err := c.gitAuth(chart)
+	assert.NoError(err)
+	assert.NotNil(auth)
+	assert.Equal("1", version)
+}
@@ -0,0 +1,224 @@
+package chart
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/yaml"
+	batch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// defaultHealthCheckTimeout is the time to wait for a release to become healthy, for charts that do not specify one.
+	defaultHealthCheckTimeout = 5 * time.Minute
+	// healthCheckInterval is the interval at which workloads are checked while waiting for a release to become healthy.
+	healthCheckInterval = 10 * time.Second
+	// healthCheckFailedReason is the reason recorded in the status history for failed health checks.
+	healthCheckFailedReason = "HealthCheckFailed"
+)
+
+// workloadKinds are the kinds of resources in the apps group that are checked for readiness.
+var workloadKinds = []string{"Deployment", "StatefulSet", "DaemonSet"}
+
+// healthCheckTimeout returns the time to wait for the chart's release to become healthy.
+func healthCheckTimeout(chart *v1.HelmChart) time.Duration {
+	if chart.Spec.HealthChecks != nil && chart.Spec.HealthChecks.Timeout != nil {
+		return chart.Spec.HealthChecks.Timeout.Duration
+	}
+	return defaultHealthCheckTimeout
+}
+
+// checkHealth verifies the health of the deployed release once the helm job has completed, and updates
+// the Healthy condition. While waiting for the release to become healthy, the chart is re-enqueued to
+// check again. If the checks fail or time out, the chart is marked as failed; unless the failure policy
+// is applied to failed health checks, the release is left as-is. The checks are not repeated until the
+// job completes again.
+func (c *Controller) checkHealth(chart *v1.HelmChart, job *batch.Job, chartStatus *v1.HelmChartStatus) error {
+	checks := chart.Spec.HealthChecks
+	if checks == nil {
+		chartStatus.LastHealthCheckTime = nil
+		chartStatus.Conditions = removeCondition(chartStatus.Conditions, v1.HelmChartHealthy)
+		return nil
+	}
+	if !deployed(*chartStatus) {
+		return nil
+	}
+	current, err := c.jobCache.Get(job.Namespace, job.Name)
+	if err != nil || current.Status.CompletionTime == nil {
+		return nil
+	}
+	if last := chartStatus.LastHealthCheckTime; last != nil && !last.Before(current.Status.CompletionTime) {
+		return nil
+	}
+
+	var unhealthy []string
+	var failed bool
+	if checks.Workloads && c.dynamic != nil {
+		rel, err := c.getDeployedRelease(chart)
+		if err != nil {
+			return fmt.Errorf("failed to get deployed release: %w", err)
+		}
+		if rel != nil {
+			unready, err := c.unreadyWorkloads(chart, rel.Manifest)
+			if err != nil {
+				return fmt.Errorf("failed to check release workloads: %w", err)
+			}
+			unhealthy = append(unhealthy, unready...)
+		}
+	}
+	if checks.HelmTest {
+		testJob, finished, err := c.runTests(chart, job, chartStatus.ReleaseRevision)
+		if err != nil {
+			return err
+		}
+		if finished == nil {
+			unhealthy = append(unhealthy, fmt.Sprintf("Job %s/%s (running)", testJob.Namespace, testJob.Name))
+		} else if finished.Type != batch.JobComplete {
+			unhealthy = append(unhealthy, fmt.Sprintf("Job %s/%s (failed)", testJob.Namespace, testJob.Name))
+			failed = true
+		}
+	}
+
+	now := metav1.Now()
+	if len(unhealthy) == 0 {
+		chartStatus.LastHealthCheckTime = &now
+		chartStatus.Conditions = setConditions(chartStatus.Conditions, v1.HelmChartCondition{
+			Type:    v1.HelmChartHealthy,
+			Status:  corev1.ConditionTrue,
+			Reason:  "Healthy",
+			Message: fmt.Sprintf("Release revision %d passed health checks", chartStatus.ReleaseRevision),
+		})
+		c.recorder.Eventf(chart, corev1.EventTypeNormal, "Healthy", "Release revision %d passed health checks", chartStatus.ReleaseRevision)
+		return nil
+	}
+
+	if remaining := healthCheckTimeout(chart) - now.Sub(current.Status.CompletionTime.Time); !failed && remaining > 0 {
+		chartStatus.Conditions = setConditions(chartStatus.Conditions, v1.HelmChartCondition{
+			Type:    v1.HelmChartHealthy,
+			Status:  corev1.ConditionUnknown,
+			Reason:  "Checking",
+			Message: fmt.Sprintf("Waiting up to %s for %d resources to become healthy: %s", remaining.Round(time.Second), len(unhealthy), listResources(unhealthy)),
+		})
+		c.helms.EnqueueAfter(chart.Namespace, chart.Name, min(remaining, healthCheckInterval))
+		return nil
+	}
+
+	message := fmt.Sprintf("Release revision %d failed health checks; %d resources are not healthy: %s", chartStatus.ReleaseRevision, len(unhealthy), listResources(unhealthy))
+	chartStatus.LastHealthCheckTime = &now
+	chartStatus.Conditions = setConditions(chartStatus.Conditions,
+		v1.HelmChartCondition{
+			Type:    v1.HelmChartHealthy,
+			Status:  corev1.ConditionFalse,
+			Reason:  "Unhealthy",
+			Message: message,
+		},
+		v1.HelmChartCondition{
+			Type:    v1.HelmChartFailed,
+			Status:  corev1.ConditionTrue,
+			Reason:  "Health check failed",
+			Message: message,
+		},
+	)
+	c.recorder.Event(chart, corev1.EventTypeWarning, "Unhealthy", message)
+	if checks.ApplyFailurePolicy {
+		return c.applyHealthFailurePolicy(chart, job, message, now, chartStatus)
+	}
+	return nil
+}
+
+// applyHealthFailurePolicy adds the failed health check to the status history, and applies the failure
+// policy of the job. With the reinstall policy, the job is deleted so that it is re-run; this is only done
+// once for each chart configuration, as re-running the job again is unlikely to correct the release.
+func (c *Controller) applyHealthFailurePolicy(chart *v1.HelmChart, job *batch.Job, message string, now metav1.Time, chartStatus *v1.HelmChartStatus) error {
+	failure := v1.HelmChartFailure{
+		Time:       now,
+		JobName:    job.Name,
+		ConfigHash: configHash(job),
+		Reason:     healthCheckFailedReason,
+		Message:    trimFailureMessage(message),
+	}
+	rerun := jobFailurePolicy(job) == v1.FailurePolicyReinstall && !slices.ContainsFunc(chartStatus.History, func(previous v1.HelmChartFailure) bool {
+		return previous.Reason == healthCheckFailedReason && previous.ConfigHash == failure.ConfigHash
+	})
+	addFailure(chartStatus, failure)
+	if !rerun {
+		return nil
+	}
+	err := c.jobs.Delete(job.Namespace, job.Name, &metav1.DeleteOptions{PropagationPolicy: ptr.To(metav1.DeletePropagationBackground)})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete job to reinstall unhealthy release: %w", err)
+	}
+	c.recorder.Eventf(chart, corev1.EventTypeNormal, "HealthCheckReinstall", "Deleted Job %s/%s to reinstall unhealthy release", job.Namespace, job.Name)
+	return nil
+}
+
+// unreadyWorkloads returns a description of each workload in the manifest that is missing, or is not ready.
+func (c *Controller) unreadyWorkloads(chart *v1.HelmChart, manifest string) ([]string, error) {
+	objs, err := yaml.ToObjects(strings.NewReader(manifest))
+	if err != nil {
+		return nil, err
+	}
+
+	var unready []string
+	for _, obj := range objs {
+		desired, ok := obj.(*unstructured.Unstructured)
+		if !ok || desired.GroupVersionKind().Group != "apps" || !slices.Contains(workloadKinds, desired.GetKind()) {
+			continue
+		}
+		client, description, err := c.resourceClient(chart, desired)
+		if err != nil {
+			return nil, err
+		}
+		live, err := client.Get(context.TODO(), desired.GetName(), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			unready = append(unready, description+" (missing)")
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if !workloadReady(live) {
+			unready = append(unready, description)
+		}
+	}
+	return unready, nil
+}
+
+// workloadReady returns true if the workload controller has observed the latest generation of the
+// workload, and all of its replicas have been updated and are ready.
+func workloadReady(obj *unstructured.Unstructured) bool {
+	observedGeneration, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if observedGeneration < obj.GetGeneration() {
+		return false
+	}
+	var desired, ready, updated int64
+	switch obj.GetKind() {
+	case "Deployment", "StatefulSet":
+		replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+		if !found {
+			replicas = 1
+		}
+		readyField := "readyReplicas"
+		if obj.GetKind() == "Deployment" {
+			readyField = "availableReplicas"
+		}
+		desired = replicas
+		ready, _, _ = unstructured.NestedInt64(obj.Object, "status", readyField)
+		updated, _, _ = unstructured.NestedInt64(obj.Object, "status", "updatedReplicas")
+	case "DaemonSet":
+		desired, _, _ = unstructured.NestedInt64(obj.Object, "status", "desiredNumberScheduled")
+		ready, _, _ = unstructured.NestedInt64(obj.Object, "status", "numberReady")
+		updated, _, _ = unstructured.NestedInt64(obj.Object, "status", "updatedNumberScheduled")
+	default:
+		return true
+	}
+	return ready >= desired && updated >= desired
+}
@@ -0,0 +1,204 @@
+package chart
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	batchcontroller "github.com/rancher/wrangler/v3/pkg/generated/controllers/batch/v1"
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	"github.com/stretchr/testify/assert"
+	batch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+)
+
+type fakeJobCache struct {
+	batchcontroller.JobCache
+	jobs []*batch.Job
+}
+
+func (f fakeJobCache) Get(namespace, name string) (*batch.Job, error) {
+	for _, job := range f.jobs {
+		if job.Namespace == namespace && job.Name == name {
+			return job, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(batch.Resource("jobs"), name)
+}
+
+type fakeJobController struct {
+	batchcontroller.JobController
+	cache   fakeJobCache
+	deleted *[]string
+}
+
+func (f fakeJobController) Cache() generic.CacheInterface[*batch.Job] {
+	return f.cache
+}
+
+func (f fakeJobController) Delete(namespace, name string, _ *metav1.DeleteOptions) error {
+	*f.deleted = append(*f.deleted, namespace+"/"+name)
+	return nil
+}
+
+func TestWorkloadReady(t *testing.T) {
+	workload := func(kind string, spec, status map[string]interface{}) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       kind,
+			"metadata":   map[string]interface{}{"name": "traefik", "generation": int64(2)},
+			"spec":       spec,
+			"status":     status,
+		}}
+		return obj
+	}
+
+	tests := []struct {
+		name  string
+		obj   *unstructured.Unstructured
+		ready bool
+	}{
+		{"deployment available", workload("Deployment", map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{"observedGeneration": int64(2), "availableReplicas": int64(2), "updatedReplicas": int64(2)}), true},
+		{"deployment default replicas", workload("Deployment", map[string]interface{}{}, map[string]interface{}{"observedGeneration": int64(2), "availableReplicas": int64(1), "updatedReplicas": int64(1)}), true},
+		{"deployment scaled to zero", workload("Deployment", map[string]interface{}{"replicas": int64(0)}, map[string]interface{}{"observedGeneration": int64(2)}), true},
+		{"deployment not observed", workload("Deployment", map[string]interface{}{"replicas": int64(1)}, map[string]interface{}{"observedGeneration": int64(1), "availableReplicas": int64(1), "updatedReplicas": int64(1)}), false},
+		{"deployment rolling out", workload("Deployment", map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{"observedGeneration": int64(2), "availableReplicas": int64(2), "updatedReplicas": int64(1)}), false},
+		{"statefulset not ready", workload("StatefulSet", map[string]interface{}{"replicas": int64(3)}, map[string]interface{}{"observedGeneration": int64(2), "readyReplicas": int64(2), "updatedReplicas": int64(3)}), false},
+		{"daemonset ready", workload("DaemonSet", map[string]interface{}{}, map[string]interface{}{"observedGeneration": int64(2), "desiredNumberScheduled": int64(3), "numberReady": int64(3), "updatedNumberScheduled": int64(3)}), true},
+		{"daemonset not ready", workload("DaemonSet", map[string]interface{}{}, map[string]interface{}{"observedGeneration": int64(2), "desiredNumberScheduled": int64(3), "numberReady": int64(1), "updatedNumberScheduled": int64(3)}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.ready, workloadReady(tt.obj))
+		})
+	}
+}
+
+func TestTestJob(t *testing.T) {
+	assert := assert.New(t)
+	chart := NewChart()
+	installJob, _, _ := job(chart, "6443")
+	installJob.Spec.Template.Spec.ServiceAccountName = "configured"
+	job := testJob(chart, installJob, 3)
+	assert.Equal("helm-test-traefik", job.Name)
+	assert.Nil(job.Spec.Suspend)
+	assert.Equal(int32(0), *job.Spec.BackoffLimit)
+	assert.Equal(int64(360), *job.Spec.ActiveDeadlineSeconds)
+	assert.Equal("configured", job.Spec.Template.Spec.ServiceAccountName)
+	assert.Empty(installJob.Spec.Template.Annotations[KeyReleaseRevision])
+	assert.Equal(corev1.RestartPolicyNever, job.Spec.Template.Spec.RestartPolicy)
+	assert.Equal([]string{"helm_v3"}, job.Spec.Template.Spec.Containers[0].Command)
+	assert.Equal([]string{"test", "traefik", "--namespace", "kube-system", "--timeout", "5m0s", "--logs"}, job.Spec.Template.Spec.Containers[0].Args)
+	assert.Equal("3", job.Spec.Template.Annotations[KeyReleaseRevision])
+
+	// the test timeout takes precedence over the health check timeout
+	chart.Spec.HealthChecks = &v1.HealthChecks{HelmTest: true, Timeout: &metav1.Duration{Duration: time.Minute}}
+	assert.Equal(time.Minute, testTimeout(chart))
+	chart.Spec.Test = &v1.ReleaseTest{Timeout: &metav1.Duration{Duration: 2 * time.Minute}}
+	assert.Equal(2*time.Minute, testTimeout(chart))
+}
+
+func TestCheckHealth(t *testing.T) {
+	assert := assert.New(t)
+	chart := NewChart()
+	chart.Spec.HealthChecks = &v1.HealthChecks{HelmTest: true}
+	helmJob, _, _ := job(chart, "6443")
+	setFailurePolicy(helmJob, v1.FailurePolicyAbort)
+	completed := helmJob.DeepCopy()
+	completed.Status.CompletionTime = &metav1.Time{Time: time.Now().Add(-time.Minute)}
+	tested := testJob(chart, helmJob, 2)
+	tested.Status.Conditions = []batch.JobCondition{{Type: batch.JobFailed, Status: corev1.ConditionTrue}}
+
+	applied := &fakeApply{}
+	c := &Controller{
+		apply:    applied,
+		jobCache: fakeJobCache{jobs: []*batch.Job{completed, tested}},
+		recorder: record.NewFakeRecorder(10),
+	}
+	chartStatus := v1.HelmChartStatus{
+		ReleaseRevision: 2,
+		Conditions: []v1.HelmChartCondition{
+			{Type: v1.HelmChartReady, Status: corev1.ConditionTrue, Reason: "Deployed"},
+		},
+	}
+	assert.NoError(c.checkHealth(chart, helmJob, &chartStatus))
+	assert.Equal("helm-chart-test", applied.setID)
+	assert.NotNil(chartStatus.LastHealthCheckTime)
+	if assert.Len(chartStatus.Conditions, 3) {
+		assert.Equal(v1.HelmChartHealthy, chartStatus.Conditions[1].Type)
+		assert.Equal(corev1.ConditionFalse, chartStatus.Conditions[1].Status)
+		assert.Equal(v1.HelmChartFailed, chartStatus.Conditions[2].Type)
+		assert.Equal(corev1.ConditionTrue, chartStatus.Conditions[2].Status)
+	}
+
+	// checks are not repeated until the job completes again
+	applied.setID = ""
+	assert.NoError(c.checkHealth(chart, helmJob, &chartStatus))
+	assert.Empty(applied.setID)
+
+	// the test job for the new revision passes
+	completed.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+	chartStatus.ReleaseRevision = 3
+	tested.Spec.Template.Annotations[KeyReleaseRevision] = "3"
+	tested.Status.Conditions = []batch.JobCondition{{Type: batch.JobComplete, Status: corev1.ConditionTrue}}
+	assert.NoError(c.checkHealth(chart, helmJob, &chartStatus))
+	assert.Equal(corev1.ConditionTrue, chartStatus.Conditions[1].Status)
+
+	// removing the health checks removes the condition
+	chart.Spec.HealthChecks = nil
+	assert.NoError(c.checkHealth(chart, helmJob, &chartStatus))
+	assert.Nil(chartStatus.LastHealthCheckTime)
+	assert.Len(chartStatus.Conditions, 2)
+}
+
+func TestCheckHealthFailurePolicy(t *testing.T) {
+	assert := assert.New(t)
+	chart := NewChart()
+	chart.Spec.HealthChecks = &v1.HealthChecks{HelmTest: true, ApplyFailurePolicy: true}
+	helmJob, _, _ := job(chart, "6443")
+	setFailurePolicy(helmJob, v1.FailurePolicyReinstall)
+	completed := helmJob.DeepCopy()
+	completed.Status.CompletionTime = &metav1.Time{Time: time.Now().Add(-time.Minute)}
+	tested := testJob(chart, helmJob, 2)
+	tested.Status.Conditions = []batch.JobCondition{{Type: batch.JobFailed, Status: corev1.ConditionTrue}}
+
+	var deleted []string
+	c := &Controller{
+		apply:    &fakeApply{},
+		jobs:     fakeJobController{deleted: &deleted},
+		jobCache: fakeJobCache{jobs: []*batch.Job{completed, tested}},
+		recorder: record.NewFakeRecorder(10),
+	}
+	chartStatus := v1.HelmChartStatus{
+		ReleaseRevision: 2,
+		Conditions: []v1.HelmChartCondition{
+			{Type: v1.HelmChartReady, Status: corev1.ConditionTrue, Reason: "Deployed"},
+		},
+	}
+	assert.NoError(c.checkHealth(chart, helmJob, &chartStatus))
+	assert.Equal([]string{helmJob.Namespace + "/" + helmJob.Name}, deleted)
+	if assert.Len(chartStatus.History, 1) {
+		assert.Equal(healthCheckFailedReason, chartStatus.History[0].Reason)
+		assert.Equal(configHash(helmJob), chartStatus.History[0].ConfigHash)
+		assert.Contains(chartStatus.History[0].Message, "failed health checks")
+	}
+
+	// the job is only re-run once for the same configuration
+	completed.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+	assert.NoError(c.checkHealth(chart, helmJob, &chartStatus))
+	assert.Len(deleted, 1)
+	assert.Len(chartStatus.History, 2)
+
+	// other failure policies only record the failure
+	completed.Status.CompletionTime = &metav1.Time{Time: time.Now().Add(time.Second)}
+	helmJob.Spec.Template.Spec.Containers[0].Env = nil
+	setFailurePolicy(helmJob, v1.FailurePolicyAbort)
+	chartStatus.History = nil
+	assert.NoError(c.checkHealth(chart, helmJob, &chartStatus))
+	assert.Len(deleted, 1)
+	assert.Len(chartStatus.History, 1)
+}
@@ -0,0 +1,206 @@
+package chart
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	batch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// maxTestLogLines limits the number of lines of test output included in the Tested condition message.
+	maxTestLogLines = 20
+	// testDeadlinePadding is added to the test timeout to allow for the test pod to start, when setting the
+	// deadline of the test job.
+	testDeadlinePadding = time.Minute
+)
+
+// testJobName returns the name of the job that runs the tests of the chart's release.
+func testJobName(chart *v1.HelmChart) string {
+	return fmt.Sprintf("helm-test-%s", chart.Name)
+}
+
+// testJobNeeded returns true if the chart's release is tested, either by `.spec.test` or as a health check.
+func testJobNeeded(chart *v1.HelmChart) bool {
+	return chart.Spec.Test != nil || (chart.Spec.HealthChecks != nil && chart.Spec.HealthChecks.HelmTest)
+}
+
+// testTimeout returns the time to wait for the chart's tests to complete. If the tests are only run as a
+// health check, the health check timeout is used.
+func testTimeout(chart *v1.HelmChart) time.Duration {
+	if chart.Spec.Test != nil {
+		if chart.Spec.Test.Timeout != nil {
+			return chart.Spec.Test.Timeout.Duration
+		}
+		return defaultHealthCheckTimeout
+	}
+	return healthCheckTimeout(chart)
+}
+
+// testJob returns a job that runs the tests defined by the chart against the specified revision of its release.
+// The job is derived from the configured install job, so that it uses the same image, service account, and
+// pod settings. The revision is added to the pod template, so that the job is replaced when the release is
+// upgraded. Unlike the install job, the test job is not created suspended, and is not retried if the tests
+// fail. The job is given a deadline, so that it is marked as failed if the tests do not complete in time.
+func testJob(chart *v1.HelmChart, installJob *batch.Job, revision int64) *batch.Job {
+	job := installJob.DeepCopy()
+	job.Name = testJobName(chart)
+	job.Spec.Suspend = nil
+	job.Spec.BackoffLimit = ptr.To(int32(0))
+	job.Spec.ActiveDeadlineSeconds = ptr.To(int64((testTimeout(chart) + testDeadlinePadding).Seconds()))
+	job.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+	job.Spec.Template.Spec.Containers[0].Command = []string{"helm_v3"}
+	job.Spec.Template.Spec.Containers[0].Args = []string{"test", chart.Name, "--namespace", targetNamespace(chart), "--timeout", testTimeout(chart).String(), "--logs"}
+	job.Spec.Template.ObjectMeta.Annotations[KeyReleaseRevision] = strconv.FormatInt(revision, 10)
+	return job
+}
+
+// runTests applies the test job for the specified revision of the chart's release, and returns the condition
+// that the job finished with, or nil if the tests are still running.
+func (c *Controller) runTests(chart *v1.HelmChart, installJob *batch.Job, revision int64) (*batch.Job, *batch.JobCondition, error) {
+	job := testJob(chart, installJob, revision)
+	if err := c.applyTestJob(chart, job); err != nil {
+		return job, nil, err
+	}
+	return job, c.testJobResult(chart, revision), nil
+}
+
+// testJobResult returns the condition that the test job for the specified revision of the release finished
+// with, or nil if the job has not finished.
+func (c *Controller) testJobResult(chart *v1.HelmChart, revision int64) *batch.JobCondition {
+	job, err := c.jobCache.Get(chart.Namespace, testJobName(chart))
+	if err != nil || job.Spec.Template.Annotations[KeyReleaseRevision] != strconv.FormatInt(revision, 10) {
+		return nil
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Status == corev1.ConditionTrue && (condition.Type == batch.JobComplete || condition.Type == batch.JobFailed) {
+			return &condition
+		}
+	}
+	return nil
+}
+
+// checkTest runs the tests defined by the chart against the deployed release, once for each revision, and
+// updates the Tested condition with the result and the tail of the test output. If the tests fail and
+// rollback on failure is enabled, the config hash of the install job is recorded in the status, so that
+// the release is rolled back until the chart configuration changes.
+func (c *Controller) checkTest(chart *v1.HelmChart, job *batch.Job, chartStatus *v1.HelmChartStatus) error {
+	if !testJobNeeded(chart) {
+		if err := c.removeTestJob(chart); err != nil {
+			return err
+		}
+	}
+	if chart.Spec.Test == nil {
+		chartStatus.TestedRevision = 0
+		chartStatus.Conditions = removeCondition(chartStatus.Conditions, v1.HelmChartTested)
+		return nil
+	}
+	revision := chartStatus.ReleaseRevision
+	if !deployed(*chartStatus) || chartStatus.TestedRevision == revision {
+		return nil
+	}
+
+	testJob, finished, err := c.runTests(chart, job, revision)
+	if err != nil {
+		return err
+	}
+	if finished == nil {
+		chartStatus.Conditions = setConditions(chartStatus.Conditions, v1.HelmChartCondition{
+			Type:    v1.HelmChartTested,
+			Status:  corev1.ConditionUnknown,
+			Reason:  "Testing",
+			Message: fmt.Sprintf("Testing release revision %d using Job %s/%s", revision, testJob.Namespace, testJob.Name),
+		})
+		return nil
+	}
+
+	passed := finished.Type == batch.JobComplete
+	phase := corev1.PodSucceeded
+	if !passed {
+		phase = corev1.PodFailed
+	}
+	logs, err := c.podLogs(testJob, phase, &corev1.PodLogOptions{
+		Container: "helm",
+		TailLines: ptr.To[int64](maxTestLogLines),
+	})
+	if finished.Reason == batch.JobReasonDeadlineExceeded {
+		logs = fmt.Sprintf("tests did not complete within %s", testTimeout(chart))
+	} else if err != nil {
+		logs = fmt.Sprintf("failed to get test output: %v", err)
+	}
+
+	chartStatus.TestedRevision = revision
+	condition := v1.HelmChartCondition{
+		Type:    v1.HelmChartTested,
+		Status:  corev1.ConditionTrue,
+		Reason:  "Passed",
+		Message: fmt.Sprintf("Release revision %d passed tests:\n%s", revision, strings.TrimSpace(logs)),
+	}
+	if passed {
+		chartStatus.Conditions = setConditions(chartStatus.Conditions, condition)
+		c.recorder.Event(chart, corev1.EventTypeNormal, "TestPassed", condition.Message)
+		return nil
+	}
+	condition.Status = corev1.ConditionFalse
+	condition.Reason = "Failed"
+	condition.Message = fmt.Sprintf("Release revision %d failed tests:\n%s", revision, strings.TrimSpace(logs))
+	chartStatus.Conditions = setConditions(chartStatus.Conditions, condition)
+	c.recorder.Event(chart, corev1.EventTypeWarning, "TestFailed", condition.Message)
+
+	if chart.Spec.Test.RollbackOnFailure {
+		chartStatus.TestRollbackConfigHash = configHash(job)
+		c.recorder.Eventf(chart, corev1.EventTypeNormal, "TestRollback", "Rolling back release revision %d after failed tests", revision)
+	}
+	return nil
+}
+
+// testRollback returns a copy of the chart that rolls back its release, if the release failed its tests
+// and rollback on failure is enabled, as recorded in the status. The rollback is not applied if
+// `.spec.rollbackTo` is already set. Whether the chart configuration has changed since the tests failed is
+// only known once the install job has been generated; see testRollbackRetained.
+func testRollback(chart *v1.HelmChart) (*v1.HelmChart, bool) {
+	if chart.Status.TestRollbackConfigHash == "" || chart.Spec.RollbackTo != nil || chart.Spec.Test == nil || !chart.Spec.Test.RollbackOnFailure {
+		return chart, false
+	}
+	chart = chart.DeepCopy()
+	chart.Spec.RollbackTo = ptr.To[int64](0)
+	return chart, true
+}
+
+// testRollbackRetained returns true if the install job generated for the chart has the configuration that
+// failed its tests. If the install job could not be generated, the rollback is retained.
+func testRollbackRetained(chart *v1.HelmChart, installJob *batch.Job, err error) bool {
+	if err != nil && !errors.Is(err, generic.ErrSkip) {
+		return true
+	}
+	return configHash(installJob) == chart.Status.TestRollbackConfigHash
+}
+
+// applyTestJob applies the test job for the chart. The test job is applied separately from the install job,
+// so that it is not replaced or removed when the install job is.
+func (c *Controller) applyTestJob(chart *v1.HelmChart, objs ...runtime.Object) error {
+	err := generic.ConfigureApplyForObject(c.apply, chart, &generic.GeneratingHandlerOptions{}).
+		WithOwner(chart).
+		WithSetID("helm-chart-test").
+		ApplyObjects(objs...)
+	if err != nil {
+		return fmt.Errorf("failed to apply test job for HelmChart %s/%s: %w", chart.Namespace, chart.Name, err)
+	}
+	return nil
+}
+
+// removeTestJob removes the test job for the chart, if one exists.
+func (c *Controller) removeTestJob(chart *v1.HelmChart) error {
+	if _, err := c.jobCache.Get(chart.Namespace, testJobName(chart)); err != nil {
+		return nil
+	}
+	return c.applyTestJob(chart)
+}
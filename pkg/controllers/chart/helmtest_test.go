@@ -0,0 +1,140 @@
+package chart
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	batch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestCheckTest(t *testing.T) {
+	assert := assert.New(t)
+	chart := NewChart()
+	chart.Spec.Test = &v1.ReleaseTest{}
+	installJob, _, _ := job(chart, "6443")
+	hashObjects(installJob)
+	tested := testJob(chart, installJob, 2)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "helm-test-traefik-abcde", Namespace: chart.Namespace, Labels: map[string]string{"job-name": tested.Name}},
+		Status:     corev1.PodStatus{Phase: corev1.PodFailed},
+	}
+
+	applied := &fakeApply{}
+	recorder := record.NewFakeRecorder(10)
+	jobCache := fakeJobCache{jobs: []*batch.Job{tested}}
+	c := &Controller{
+		apply:    applied,
+		jobCache: jobCache,
+		pods:     fake.NewClientset(pod).CoreV1(),
+		recorder: recorder,
+	}
+	chartStatus := v1.HelmChartStatus{
+		ReleaseRevision: 2,
+		Conditions: []v1.HelmChartCondition{
+			{Type: v1.HelmChartReady, Status: corev1.ConditionTrue, Reason: "Deployed"},
+		},
+	}
+
+	// the test job is applied, and the chart waits for it to finish
+	assert.NoError(c.checkTest(chart, installJob, &chartStatus))
+	assert.Equal("helm-chart-test", applied.setID)
+	assert.Zero(chartStatus.TestedRevision)
+	if assert.Len(chartStatus.Conditions, 2) {
+		assert.Equal(corev1.ConditionUnknown, chartStatus.Conditions[1].Status)
+	}
+
+	// the failed tests and their output are recorded once for the revision
+	tested.Status.Conditions = []batch.JobCondition{{Type: batch.JobFailed, Status: corev1.ConditionTrue}}
+	assert.NoError(c.checkTest(chart, installJob, &chartStatus))
+	assert.Equal(int64(2), chartStatus.TestedRevision)
+	assert.Equal(corev1.ConditionFalse, chartStatus.Conditions[1].Status)
+	assert.Equal("Failed", chartStatus.Conditions[1].Reason)
+	assert.Contains(chartStatus.Conditions[1].Message, "fake logs")
+	assert.True(strings.HasPrefix(<-recorder.Events, "Warning TestFailed"))
+
+	assert.Empty(chartStatus.TestRollbackConfigHash)
+
+	applied.setID = ""
+	assert.NoError(c.checkTest(chart, installJob, &chartStatus))
+	assert.Empty(applied.setID)
+
+	// tests that exceed the job deadline are reported as timed out, and the release is marked for rollback
+	chart.Spec.Test.RollbackOnFailure = true
+	chartStatus.ReleaseRevision = 3
+	tested.Spec.Template.Annotations[KeyReleaseRevision] = "3"
+	tested.Status.Conditions = []batch.JobCondition{{Type: batch.JobFailed, Status: corev1.ConditionTrue, Reason: batch.JobReasonDeadlineExceeded}}
+	assert.NoError(c.checkTest(chart, installJob, &chartStatus))
+	assert.Equal(int64(3), chartStatus.TestedRevision)
+	assert.Contains(chartStatus.Conditions[1].Message, "tests did not complete within 5m0s")
+	assert.Equal(configHash(installJob), chartStatus.TestRollbackConfigHash)
+	assert.True(strings.HasPrefix(<-recorder.Events, "Warning TestFailed"))
+	assert.True(strings.HasPrefix(<-recorder.Events, "Normal TestRollback"))
+	chart.Spec.Test.RollbackOnFailure = false
+
+	// clearing the test field removes the condition, and the test job if it is not used for health checks
+	applied.setID = ""
+	chart.Spec.Test = nil
+	chart.Spec.HealthChecks = &v1.HealthChecks{HelmTest: true}
+	assert.NoError(c.checkTest(chart, installJob, &chartStatus))
+	assert.Empty(applied.setID)
+	assert.Zero(chartStatus.TestedRevision)
+	assert.Len(chartStatus.Conditions, 1)
+
+	chart.Spec.HealthChecks = nil
+	applied.objects = []runtime.Object{tested}
+	assert.NoError(c.checkTest(chart, installJob, &chartStatus))
+	assert.Equal("helm-chart-test", applied.setID)
+	assert.Empty(applied.objects)
+}
+
+func TestTestRollback(t *testing.T) {
+	assert := assert.New(t)
+	c := &Controller{
+		apply:          &fakeApply{},
+		jobs:           fakeJobController{},
+		jobCache:       fakeJobCache{},
+		confCache:      fakeConfigCache{},
+		configMapCache: fakeConfigMapCache{},
+		secrets: fakeSecretLister{
+			list: func(namespace string, opts metav1.ListOptions) (*corev1.SecretList, error) {
+				return &corev1.SecretList{}, nil
+			},
+		},
+	}
+	chart := NewChart()
+	chart.Spec.Test = &v1.ReleaseTest{RollbackOnFailure: true}
+	installJob, _, _, err := c.getJobAndRelatedResources(chart)
+	assert.NoError(err)
+
+	// the chart is not rolled back unless its tests failed
+	rollback, rollingBack := testRollback(chart)
+	assert.False(rollingBack)
+	assert.Same(chart, rollback)
+
+	// the chart is rolled back in memory while the configuration that failed its tests is unchanged
+	chart.Status.TestRollbackConfigHash = configHash(installJob)
+	rollback, rollingBack = testRollback(chart)
+	assert.True(rollingBack)
+	assert.Equal(int64(0), *rollback.Spec.RollbackTo)
+	assert.Nil(chart.Spec.RollbackTo)
+	assert.Equal("helm-rollback-traefik", jobName(rollback))
+	assert.True(testRollbackRetained(chart, installJob, nil))
+
+	// the rollback is retained if the install job cannot be generated
+	assert.True(testRollbackRetained(chart, nil, errors.New("failed to retrieve chart")))
+
+	// changing the configuration resumes upgrades
+	chart.Spec.Set = map[string]intstr.IntOrString{"replicas": intstr.FromInt32(2)}
+	installJob, _, _, err = c.getJobAndRelatedResources(chart)
+	assert.NoError(err)
+	assert.False(testRollbackRetained(chart, installJob, nil))
+}
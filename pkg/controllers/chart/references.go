@@ -0,0 +1,267 @@
+package chart
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	kindSecret    = "Secret"
+	kindConfigMap = "ConfigMap"
+)
+
+// errReferenceNotPermitted indicates that a cross-namespace reference is not permitted by any HelmChartReferenceGrant.
+var errReferenceNotPermitted = errors.New("not permitted by a HelmChartReferenceGrant")
+
+// referenceNamespace returns the namespace of a reference from the chart, defaulting to the namespace of the chart.
+func referenceNamespace(chart *v1.HelmChart, namespace string) string {
+	if namespace == "" {
+		return chart.Namespace
+	}
+	return namespace
+}
+
+// referencesOtherNamespace returns true if the reference namespace is set, and is not the namespace of the referencing resource.
+// HelmChartConfigs may not reference resources in other namespaces.
+func referencesOtherNamespace(namespace, refNamespace string) bool {
+	return refNamespace != "" && refNamespace != namespace
+}
+
+// referenceGranted returns true if a HelmChartReferenceGrant permits HelmCharts in the from namespace
+// to reference the resource of the given kind, namespace, and name.
+func (c *Controller) referenceGranted(from, kind, namespace, name string) (bool, error) {
+	if from == namespace {
+		return true, nil
+	}
+	if c.grantCache == nil {
+		return false, nil
+	}
+	grants, err := c.grantCache.List(namespace, labels.Everything())
+	if err != nil {
+		return false, err
+	}
+	for _, grant := range grants {
+		fromPermitted := false
+		for _, f := range grant.Spec.From {
+			if f.Namespace == from {
+				fromPermitted = true
+				break
+			}
+		}
+		if !fromPermitted {
+			continue
+		}
+		for _, to := range grant.Spec.To {
+			if to.Kind == kind && (to.Name == "" || to.Name == name) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// referencedSecret returns the Secret referenced by the chart, if the reference is permitted.
+func (c *Controller) referencedSecret(chart *v1.HelmChart, namespace, name string) (*corev1.Secret, error) {
+	namespace = referenceNamespace(chart, namespace)
+	if granted, err := c.referenceGranted(chart.Namespace, kindSecret, namespace, name); err != nil {
+		return nil, err
+	} else if !granted {
+		return nil, fmt.Errorf("reference to Secret %s/%s %w", namespace, name, errReferenceNotPermitted)
+	}
+	return c.secretCache.Get(namespace, name)
+}
+
+// referencedConfigMap returns the ConfigMap referenced by the chart, if the reference is permitted.
+func (c *Controller) referencedConfigMap(chart *v1.HelmChart, namespace, name string) (*corev1.ConfigMap, error) {
+	namespace = referenceNamespace(chart, namespace)
+	if granted, err := c.referenceGranted(chart.Namespace, kindConfigMap, namespace, name); err != nil {
+		return nil, err
+	} else if !granted {
+		return nil, fmt.Errorf("reference to ConfigMap %s/%s %w", namespace, name, errReferenceNotPermitted)
+	}
+	return c.configMapCache.Get(namespace, name)
+}
+
+// referenceCopyName returns the name of the copy of a referenced resource in the chart namespace.
+func referenceCopyName(chart *v1.HelmChart, kind, namespace, name string) string {
+	hash := sha256.Sum256([]byte(kind + "/" + namespace + "/" + name))
+	return fmt.Sprintf("chart-ref-%s-%x", chart.Name, hash[:4])
+}
+
+// resolveReferences returns a copy of the chart with references to Secrets and ConfigMaps in other namespaces replaced
+// by references to copies in the chart namespace. The copies are applied, so that they are kept in sync with the referenced
+// resources, and copies that are no longer referenced or permitted are removed. Copies of values Secrets and ConfigMaps that
+// do not ignore updates are returned, so that they can be included in the config hash. While the chart is being deleted,
+// the references are replaced without copying the referenced resources, as the uninstall job does not use them.
+func (c *Controller) resolveReferences(chart *v1.HelmChart) (*v1.HelmChart, []metav1.Object, error) {
+	var copies []runtime.Object
+	var hashed []metav1.Object
+	var errs []error
+	copyNeeded := chart.DeletionTimestamp == nil
+	chart = chart.DeepCopy()
+	spec := &chart.Spec
+
+	crossNamespace := func(namespace string) bool {
+		return referencesOtherNamespace(chart.Namespace, namespace)
+	}
+	copySecret := func(namespace, name string, optional bool) *corev1.Secret {
+		if !copyNeeded {
+			return nil
+		}
+		secret, err := c.referencedSecret(chart, namespace, name)
+		if err != nil {
+			if !(optional && apierrors.IsNotFound(err)) {
+				errs = append(errs, err)
+			}
+			return nil
+		}
+		secretCopy := &corev1.Secret{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "v1",
+				Kind:       "Secret",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      referenceCopyName(chart, kindSecret, namespace, name),
+				Namespace: chart.Namespace,
+				Labels:    map[string]string{LabelChartName: chart.Name},
+			},
+			Type: secret.Type,
+			Data: secret.Data,
+		}
+		copies = append(copies, secretCopy)
+		return secretCopy
+	}
+	copyConfigMap := func(namespace, name string, optional bool) *corev1.ConfigMap {
+		if !copyNeeded {
+			return nil
+		}
+		configMap, err := c.referencedConfigMap(chart, namespace, name)
+		if err != nil {
+			if !(optional && apierrors.IsNotFound(err)) {
+				errs = append(errs, err)
+			}
+			return nil
+		}
+		configMapCopy := &corev1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "v1",
+				Kind:       "ConfigMap",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      referenceCopyName(chart, kindConfigMap, namespace, name),
+				Namespace: chart.Namespace,
+				Labels:    map[string]string{LabelChartName: chart.Name},
+			},
+			Data:       configMap.Data,
+			BinaryData: configMap.BinaryData,
+		}
+		copies = append(copies, configMapCopy)
+		return configMapCopy
+	}
+
+	if ref := spec.AuthSecret; ref != nil && crossNamespace(spec.AuthSecretNamespace) {
+		copySecret(spec.AuthSecretNamespace, ref.Name, false)
+		spec.AuthSecret = &corev1.LocalObjectReference{Name: referenceCopyName(chart, kindSecret, spec.AuthSecretNamespace, ref.Name)}
+		spec.AuthSecretNamespace = ""
+	}
+	if ref := spec.DockerRegistrySecret; ref != nil && crossNamespace(spec.DockerRegistrySecretNamespace) {
+		copySecret(spec.DockerRegistrySecretNamespace, ref.Name, false)
+		spec.DockerRegistrySecret = &corev1.LocalObjectReference{Name: referenceCopyName(chart, kindSecret, spec.DockerRegistrySecretNamespace, ref.Name)}
+		spec.DockerRegistrySecretNamespace = ""
+	}
+	if ref := spec.RepoCAConfigMap; ref != nil && crossNamespace(spec.RepoCAConfigMapNamespace) {
+		copyConfigMap(spec.RepoCAConfigMapNamespace, ref.Name, false)
+		spec.RepoCAConfigMap = &corev1.LocalObjectReference{Name: referenceCopyName(chart, kindConfigMap, spec.RepoCAConfigMapNamespace, ref.Name)}
+		spec.RepoCAConfigMapNamespace = ""
+	}
+	// the copies of values Secrets and ConfigMaps are marked as ignoring updates, as they may not be in the cache yet;
+	// the copies are hashed instead of the cached objects, if the original references do not ignore updates.
+	for i, ref := range spec.ValuesSecrets {
+		if !crossNamespace(ref.Namespace) {
+			continue
+		}
+		if secret := copySecret(ref.Namespace, ref.Name, ref.IgnoreUpdates); secret != nil && !ref.IgnoreUpdates {
+			hashed = append(hashed, secret)
+		}
+		spec.ValuesSecrets[i] = v1.SecretSpec{Name: referenceCopyName(chart, kindSecret, ref.Namespace, ref.Name), Keys: ref.Keys, IgnoreUpdates: true}
+	}
+	for i, ref := range spec.ValuesConfigMaps {
+		if !crossNamespace(ref.Namespace) {
+			continue
+		}
+		if configMap := copyConfigMap(ref.Namespace, ref.Name, ref.IgnoreUpdates); configMap != nil && !ref.IgnoreUpdates {
+			hashed = append(hashed, configMap)
+		}
+		spec.ValuesConfigMaps[i] = v1.ConfigMapSpec{Name: referenceCopyName(chart, kindConfigMap, ref.Namespace, ref.Name), Keys: ref.Keys, IgnoreUpdates: true}
+	}
+
+	if copyNeeded {
+		if err := c.applyReferences(chart, copies...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return chart, hashed, errors.Join(errs...)
+}
+
+// applyReferences applies the copies of referenced resources for the chart, removing any that are no longer referenced.
+func (c *Controller) applyReferences(chart *v1.HelmChart, copies ...runtime.Object) error {
+	err := generic.ConfigureApplyForObject(c.apply, chart, &generic.GeneratingHandlerOptions{}).
+		WithOwner(chart).
+		WithSetID("helm-chart-references").
+		ApplyObjects(copies...)
+	if err != nil {
+		return fmt.Errorf("failed to copy referenced resources for HelmChart %s/%s: %w", chart.Namespace, chart.Name, err)
+	}
+	return nil
+}
+
+// chartByReferencedNamespace indexes charts by the namespaces of Secrets and ConfigMaps that they reference in other namespaces.
+func chartByReferencedNamespace(chart *v1.HelmChart) ([]string, error) {
+	var keys []string
+	add := func(namespace string) {
+		if referencesOtherNamespace(chart.Namespace, namespace) {
+			keys = append(keys, namespace)
+		}
+	}
+	if chart.Spec.AuthSecret != nil {
+		add(chart.Spec.AuthSecretNamespace)
+	}
+	if chart.Spec.DockerRegistrySecret != nil {
+		add(chart.Spec.DockerRegistrySecretNamespace)
+	}
+	if chart.Spec.RepoCAConfigMap != nil {
+		add(chart.Spec.RepoCAConfigMapNamespace)
+	}
+	for _, ref := range chart.Spec.ValuesSecrets {
+		add(ref.Namespace)
+	}
+	for _, ref := range chart.Spec.ValuesConfigMaps {
+		add(ref.Namespace)
+	}
+	return keys, nil
+}
+
+func (c *Controller) resolveHelmChartFromReferenceGrant(namespace, name string, obj runtime.Object) ([]relatedresource.Key, error) {
+	// See if there are HelmCharts that reference resources in the namespace of this grant. The grant
+	// may have been deleted, so charts are resolved by the namespace alone.
+	charts, err := c.helmCache.GetByIndex(chartByReferencedNamespaceIndex, namespace)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]relatedresource.Key, len(charts))
+	for i, chart := range charts {
+		keys[i].Name = chart.Name
+		keys[i].Namespace = chart.Namespace
+	}
+	return keys, nil
+}
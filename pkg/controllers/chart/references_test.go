@@ -0,0 +1,132 @@
+package chart
+
+import (
+	"testing"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	helmcontroller "github.com/k3s-io/helm-controller/pkg/generated/controllers/helm.cattle.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/apply"
+	corecontroller "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+)
+
+type fakeGrantCache struct {
+	helmcontroller.HelmChartReferenceGrantCache
+	grants []*v1.HelmChartReferenceGrant
+}
+
+func (f fakeGrantCache) List(namespace string, selector labels.Selector) ([]*v1.HelmChartReferenceGrant, error) {
+	var grants []*v1.HelmChartReferenceGrant
+	for _, grant := range f.grants {
+		if grant.Namespace == namespace {
+			grants = append(grants, grant)
+		}
+	}
+	return grants, nil
+}
+
+type fakeSecretCache struct {
+	corecontroller.SecretCache
+	secrets []*corev1.Secret
+}
+
+func (f fakeSecretCache) Get(namespace, name string) (*corev1.Secret, error) {
+	for _, secret := range f.secrets {
+		if secret.Namespace == namespace && secret.Name == name {
+			return secret, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(corev1.Resource("secrets"), name)
+}
+
+// applier allows apply.Apply to be embedded without the field name conflicting with its Apply method.
+type applier = apply.Apply
+
+type fakeApply struct {
+	applier
+	setID   string
+	objects []runtime.Object
+}
+
+func (f *fakeApply) WithRestrictClusterScoped() apply.Apply   { return f }
+func (f *fakeApply) WithOwner(obj runtime.Object) apply.Apply { return f }
+func (f *fakeApply) WithSetID(id string) apply.Apply {
+	f.setID = id
+	return f
+}
+func (f *fakeApply) ApplyObjects(objs ...runtime.Object) error {
+	f.objects = objs
+	return nil
+}
+
+func TestResolveReferences(t *testing.T) {
+	assert := assert.New(t)
+	applied := &fakeApply{}
+	c := &Controller{
+		apply: applied,
+		grantCache: fakeGrantCache{grants: []*v1.HelmChartReferenceGrant{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: "shared"},
+				Spec: v1.HelmChartReferenceGrantSpec{
+					From: []v1.ReferenceGrantFrom{{Namespace: "kube-system"}},
+					To:   []v1.ReferenceGrantTo{{Kind: "Secret", Name: "registry-auth"}, {Kind: "Secret", Name: "values"}},
+				},
+			},
+		}},
+		secretCache: fakeSecretCache{secrets: []*corev1.Secret{
+			{ObjectMeta: metav1.ObjectMeta{Name: "registry-auth", Namespace: "shared"}, Type: corev1.SecretTypeDockerConfigJson, Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte("{}")}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "values", Namespace: "shared"}, Data: map[string][]byte{"values.yaml": []byte("replicas: 2\n")}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "shared"}},
+		}},
+	}
+
+	chart := NewChart()
+	chart.Spec.DockerRegistrySecret = &corev1.LocalObjectReference{Name: "registry-auth"}
+	chart.Spec.DockerRegistrySecretNamespace = "shared"
+	chart.Spec.ValuesSecrets = []v1.SecretSpec{
+		{Name: "local", Keys: []string{"values.yaml"}},
+		{Name: "values", Namespace: "shared", Keys: []string{"values.yaml"}},
+	}
+	resolved, hashed, err := c.resolveReferences(chart)
+	assert.NoError(err)
+	assert.Equal("helm-chart-references", applied.setID)
+	if assert.Len(applied.objects, 2) {
+		secret := applied.objects[0].(*corev1.Secret)
+		assert.Equal(resolved.Spec.DockerRegistrySecret.Name, secret.Name)
+		assert.Equal("kube-system", secret.Namespace)
+		assert.Equal(corev1.SecretTypeDockerConfigJson, secret.Type)
+	}
+	assert.Equal("shared", chart.Spec.DockerRegistrySecretNamespace, "original chart should not be modified")
+	assert.Empty(resolved.Spec.DockerRegistrySecretNamespace)
+	assert.Equal(v1.SecretSpec{Name: "local", Keys: []string{"values.yaml"}}, resolved.Spec.ValuesSecrets[0])
+	assert.True(resolved.Spec.ValuesSecrets[1].IgnoreUpdates)
+	if assert.Len(hashed, 1) {
+		assert.Equal(resolved.Spec.ValuesSecrets[1].Name, hashed[0].GetName())
+	}
+
+	// references that are not permitted are reported, and their copies removed
+	chart.Spec.AuthSecret = &corev1.LocalObjectReference{Name: "other"}
+	chart.Spec.AuthSecretNamespace = "shared"
+	_, _, err = c.resolveReferences(chart)
+	assert.ErrorIs(err, errReferenceNotPermitted)
+	assert.Len(applied.objects, 2)
+
+	// references are replaced without copying while the chart is being deleted
+	applied.objects = nil
+	chart.DeletionTimestamp = ptr.To(metav1.Now())
+	resolved, _, err = c.resolveReferences(chart)
+	assert.NoError(err)
+	assert.Nil(applied.objects)
+	assert.Equal(referenceCopyName(chart, kindSecret, "shared", "other"), resolved.Spec.AuthSecret.Name)
+
+	keys, _ := chartByReferencedNamespace(chart)
+	assert.Equal([]string{"shared", "shared", "shared"}, keys)
+	keys, _ = chartBySecret(chart)
+	assert.ElementsMatch([]string{"kube-system.local", "shared.values", "shared.other", "shared.registry-auth"}, keys)
+}
@@ -0,0 +1,267 @@
+package chart
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/yaml"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// maxReleaseHistory limits the number of release revisions listed in the chart status.
+	maxReleaseHistory = 10
+	// maxReleaseNotesBytes limits the length of the release notes recorded in the chart status.
+	maxReleaseNotesBytes = 4096
+	// maxReleaseResources limits the number of release resources listed in the chart status.
+	maxReleaseResources = 256
+)
+
+var gzipMagic = []byte{0x1f, 0x8b, 0x08}
+
+// releaseData holds the fields of interest from a helm release record.
+type releaseData struct {
+	Manifest string       `json:"manifest,omitempty"`
+	Info     releaseInfo  `json:"info,omitempty"`
+	Chart    releaseChart `json:"chart,omitempty"`
+}
+
+// releaseInfo holds the fields of interest from the info of a helm release record.
+type releaseInfo struct {
+	LastDeployed metav1.Time `json:"last_deployed,omitempty"`
+	Description  string      `json:"description,omitempty"`
+	Notes        string      `json:"notes,omitempty"`
+}
+
+// releaseChart holds the fields of interest from the chart of a helm release record.
+type releaseChart struct {
+	Metadata struct {
+		Name       string `json:"name,omitempty"`
+		Version    string `json:"version,omitempty"`
+		AppVersion string `json:"appVersion,omitempty"`
+	} `json:"metadata,omitempty"`
+}
+
+// releaseSummary holds the info decoded from a release record that is listed in the release history.
+// Release records are not modified by helm once written, other than to change their status label,
+// so summaries are cached by UID to avoid decoding every record each time the chart is reconciled.
+type releaseSummary struct {
+	chartVersion string
+	description  string
+	deployed     *metav1.Time
+}
+
+// cachedReleaseInfo holds the info decoded from the latest release record of a chart, along with the UID of the record.
+type cachedReleaseInfo struct {
+	uid  types.UID
+	info *v1.HelmChartReleaseInfo
+}
+
+// decodeRelease decodes the release record stored by helm in the release Secret or ConfigMap.
+// Helm stores the release as base64-encoded JSON, which is usually gzipped.
+func decodeRelease(data string) (*releaseData, error) {
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode release: %w", err)
+	}
+	if bytes.HasPrefix(b, gzipMagic) {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress release: %w", err)
+		}
+		defer r.Close()
+		if b, err = io.ReadAll(r); err != nil {
+			return nil, fmt.Errorf("failed to decompress release: %w", err)
+		}
+	}
+	rel := &releaseData{}
+	if err := json.Unmarshal(b, rel); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal release: %w", err)
+	}
+	return rel, nil
+}
+
+// getDeployedRelease returns the decoded release record for the chart's deployed release,
+// or nil if there is no deployed release.
+func (c *Controller) getDeployedRelease(chart *v1.HelmChart) (*releaseData, error) {
+	ls := labels.Set{"owner": "helm", "name": chart.Name, "status": "deployed"}.AsSelector()
+
+	var revision int64
+	var data string
+	if helmDriver(chart) == "configmap" {
+		cmList, err := c.configMaps.List(chart.Spec.TargetNamespace, metav1.ListOptions{LabelSelector: ls.String()})
+		if err != nil {
+			return nil, err
+		}
+		for _, cm := range cmList.Items {
+			if sv, err := strconv.ParseInt(cm.Labels["version"], 10, 64); err == nil && sv > revision {
+				revision = sv
+				data = cm.Data["release"]
+			}
+		}
+	} else {
+		fs := fields.OneTermEqualSelector("type", ReleaseType)
+		secretList, err := c.secrets.List(chart.Spec.TargetNamespace, metav1.ListOptions{FieldSelector: fs.String(), LabelSelector: ls.String()})
+		if err != nil {
+			return nil, err
+		}
+		for _, secret := range secretList.Items {
+			if sv, err := strconv.ParseInt(secret.Labels["version"], 10, 64); err == nil && sv > revision {
+				revision = sv
+				data = string(secret.Data["release"])
+			}
+		}
+	}
+
+	if revision == 0 {
+		return nil, nil
+	}
+	return decodeRelease(data)
+}
+
+// releaseHistory returns the most recent revisions of the chart's release, newest first, from the
+// provided list of release objects. The data function returns the encoded release record for the
+// object at the given index. Only records that are listed in the history are decoded.
+func (c *Controller) releaseHistory(chart *v1.HelmChart, objects []metav1.ObjectMeta, data func(i int) string) []v1.HelmChartRelease {
+	type revision struct {
+		index   int
+		version int64
+	}
+	revisions := make([]revision, 0, len(objects))
+	for i, obj := range objects {
+		if sv, err := strconv.ParseInt(obj.Labels["version"], 10, 64); err == nil {
+			revisions = append(revisions, revision{index: i, version: sv})
+		}
+	}
+	slices.SortFunc(revisions, func(a, b revision) int { return cmp.Compare(b.version, a.version) })
+	if len(revisions) > maxReleaseHistory {
+		revisions = revisions[:maxReleaseHistory]
+	}
+	if len(revisions) == 0 {
+		c.releaseSummaries.Delete(chart.Namespace + "/" + chart.Name)
+		return nil
+	}
+
+	// the cache for this chart is replaced with only the summaries of the listed revisions, so that
+	// summaries of records removed by helm are pruned.
+	cached, _ := c.releaseSummaries.Load(chart.Namespace + "/" + chart.Name)
+	previous, _ := cached.(map[types.UID]releaseSummary)
+	summaries := make(map[types.UID]releaseSummary, len(revisions))
+
+	history := make([]v1.HelmChartRelease, 0, len(revisions))
+	for _, r := range revisions {
+		obj := objects[r.index]
+		summary, ok := previous[obj.UID]
+		if !ok {
+			if rel, err := decodeRelease(data(r.index)); err == nil {
+				summary.chartVersion = rel.Chart.Metadata.Version
+				summary.description = rel.Info.Description
+				if !rel.Info.LastDeployed.IsZero() {
+					summary.deployed = &rel.Info.LastDeployed
+				}
+			}
+		}
+		if obj.UID != "" {
+			summaries[obj.UID] = summary
+		}
+		history = append(history, v1.HelmChartRelease{
+			Revision:     r.version,
+			Status:       obj.Labels["status"],
+			ConfigHash:   obj.Labels[KeyConfigHash],
+			ChartVersion: summary.chartVersion,
+			Description:  summary.description,
+			Created:      obj.CreationTimestamp,
+			Deployed:     summary.deployed.DeepCopy(),
+		})
+	}
+	c.releaseSummaries.Store(chart.Namespace+"/"+chart.Name, summaries)
+	return history
+}
+
+// latestReleaseInfo returns info on the chart installed by the release with the highest version, from the
+// provided list of release objects. The data function returns the encoded release record for the object at
+// the given index. The record is only decoded when the latest release changes; nil is returned if there is
+// no release, or the record cannot be decoded.
+func (c *Controller) latestReleaseInfo(chart *v1.HelmChart, objects []metav1.ObjectMeta, data func(i int) string) *v1.HelmChartReleaseInfo {
+	key := chart.Namespace + "/" + chart.Name
+	latest, revision := -1, int64(0)
+	for i, obj := range objects {
+		if sv, err := strconv.ParseInt(obj.Labels["version"], 10, 64); err == nil && sv > revision {
+			latest, revision = i, sv
+		}
+	}
+	if latest < 0 {
+		c.releaseInfos.Delete(key)
+		return nil
+	}
+
+	uid := objects[latest].UID
+	if cached, ok := c.releaseInfos.Load(key); ok && uid != "" && cached.(cachedReleaseInfo).uid == uid {
+		return cached.(cachedReleaseInfo).info.DeepCopy()
+	}
+	var info *v1.HelmChartReleaseInfo
+	if rel, err := decodeRelease(data(latest)); err != nil {
+		c.logger.Error(err, "Failed to decode latest chart release",
+			"chart.name", key,
+			"release.revision", revision,
+		)
+	} else {
+		info = &v1.HelmChartReleaseInfo{
+			ChartName:    rel.Chart.Metadata.Name,
+			ChartVersion: rel.Chart.Metadata.Version,
+			AppVersion:   rel.Chart.Metadata.AppVersion,
+			Notes:        trimReleaseNotes(rel.Info.Notes),
+			Resources:    manifestResources(rel.Manifest),
+		}
+	}
+	c.releaseInfos.Store(key, cachedReleaseInfo{uid: uid, info: info})
+	return info.DeepCopy()
+}
+
+// manifestResources returns the resources in the release manifest, limited to maxReleaseResources.
+func manifestResources(manifest string) []v1.HelmChartResource {
+	objs, err := yaml.ToObjects(strings.NewReader(manifest))
+	if err != nil {
+		return nil
+	}
+	var resources []v1.HelmChartResource
+	for _, obj := range objs {
+		if len(resources) >= maxReleaseResources {
+			break
+		}
+		metadata, err := meta.Accessor(obj)
+		if err != nil {
+			continue
+		}
+		apiVersion, kind := obj.GetObjectKind().GroupVersionKind().ToAPIVersionAndKind()
+		resources = append(resources, v1.HelmChartResource{
+			APIVersion: apiVersion,
+			Kind:       kind,
+			Namespace:  metadata.GetNamespace(),
+			Name:       metadata.GetName(),
+		})
+	}
+	return resources
+}
+
+// trimReleaseNotes returns the start of the release notes, limited to maxReleaseNotesBytes.
+func trimReleaseNotes(notes string) string {
+	notes = strings.TrimSpace(notes)
+	if len(notes) <= maxReleaseNotesBytes {
+		return notes
+	}
+	return strings.ToValidUTF8(notes[:maxReleaseNotesBytes], "") + "..."
+}
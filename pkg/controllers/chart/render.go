@@ -0,0 +1,20 @@
+package chart
+
+import (
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	batch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Render returns the job and values secret that are generated for the chart, with the HelmChartConfigs that
+// apply to the chart merged in. Configs are selected from the provided list as they are by a controller
+// registered for the system namespace, which may be empty if the controller watches all namespaces.
+// Chart versions, git repositories, cached chart archives, and resources referenced from other namespaces
+// are not resolved, so the rendered job differs from the controller's job for charts that use them.
+func Render(chart *v1.HelmChart, confs []*v1.HelmChartConfig, systemNamespace string) (*batch.Job, *corev1.Secret) {
+	c := &Controller{systemNamespace: systemNamespace}
+	job, valuesSecret, contentConfigMap := job(chart, "6443")
+	configureJob(chart, job, valuesSecret, c.selectChartConfigs(chart, confs))
+	hashObjects(job, contentConfigMap, valuesSecret)
+	return job, valuesSecret
+}
@@ -0,0 +1,468 @@
+package chart
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	batch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// versionCheckInterval is the interval at which version ranges are resolved against the chart repository.
+	versionCheckInterval = 15 * time.Minute
+	// maxIndexBytes limits the size of the repository index that will be read.
+	maxIndexBytes = 64 * 1024 * 1024
+	// repoTimeout limits the total time spent on requests to a chart repository or OCI registry while
+	// reconciling a chart, so that an unresponsive server does not block the reconcile worker.
+	repoTimeout = time.Minute
+)
+
+var challengeParamRE = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// chartVersion holds the newest chart version resolved for a version range.
+type chartVersion struct {
+	chart   string
+	repo    string
+	version string
+	checked time.Time
+}
+
+// isVersionRange returns true if the version is a semver range, rather than a specific version.
+func isVersionRange(version string) bool {
+	if version == "" {
+		return false
+	}
+	if _, err := semver.StrictNewVersion(strings.TrimPrefix(version, "v")); err == nil {
+		return false
+	}
+	_, err := semver.NewConstraint(version)
+	return err == nil
+}
+
+// resolvesVersionRange returns true if the chart's version is a range that is resolved by the controller.
+// Only ranges for charts from a chart repository or OCI registry are resolved; the version of other charts
+// is passed to the job as-is.
+func resolvesVersionRange(chart *v1.HelmChart) bool {
+	if chart.Spec.ChartContent != "" || (chart.Spec.Repo == "" && !strings.HasPrefix(chart.Spec.Chart, "oci://")) {
+		return false
+	}
+	return isVersionRange(chart.Spec.Version)
+}
+
+// resolveChartVersion returns a copy of the chart with the version range replaced by the newest
+// matching version available from the chart repository or OCI registry, along with the resolved
+// version. Resolved versions are cached, and the repository is only checked again once the check
+// interval has elapsed. If the repository cannot be reached, the previously resolved version is used.
+func (c *Controller) resolveChartVersion(chart *v1.HelmChart) (*v1.HelmChart, string, error) {
+	if !resolvesVersionRange(chart) {
+		return chart, "", nil
+	}
+	key := chart.Namespace + "/" + chart.Name
+
+	var cached *chartVersion
+	if obj, ok := c.chartVersions.Load(key); ok {
+		cached = obj.(*chartVersion)
+		if cached.chart != chart.Spec.Chart || cached.repo != chart.Spec.Repo {
+			cached = nil
+		}
+	}
+
+	// the range itself may have changed, so always check that the cached version still matches
+	constraint, err := semver.NewConstraint(chart.Spec.Version)
+	if err != nil {
+		return nil, "", err
+	}
+	if cached != nil {
+		if v, err := semver.NewVersion(cached.version); err != nil || !constraint.Check(v) {
+			cached = nil
+		}
+	}
+
+	if cached == nil || time.Since(cached.checked) >= versionCheckInterval {
+		ctx, cancel := context.WithTimeout(context.Background(), repoTimeout)
+		versions, err := c.listChartVersions(ctx, chart)
+		cancel()
+		if err == nil {
+			var version string
+			if version, err = newestVersion(constraint, versions); err == nil {
+				if cached == nil || cached.version != version {
+					c.logger.V(1).Info("Resolved chart version range",
+						"chart.name", key,
+						"chart.version", chart.Spec.Version,
+						"resolved.version", version,
+					)
+				}
+				cached = &chartVersion{chart: chart.Spec.Chart, repo: chart.Spec.Repo, version: version, checked: time.Now()}
+				c.chartVersions.Store(key, cached)
+			}
+		}
+		if err != nil {
+			if cached == nil {
+				return nil, "", fmt.Errorf("failed to resolve version %s: %w", chart.Spec.Version, err)
+			}
+			c.logger.Error(err, "Failed to resolve chart version range, using previously resolved version",
+				"chart.name", key,
+				"resolved.version", cached.version,
+			)
+		}
+	}
+
+	c.helms.EnqueueAfter(chart.Namespace, chart.Name, max(versionCheckInterval-time.Since(cached.checked), time.Second))
+
+	chart = chart.DeepCopy()
+	chart.Spec.Version = cached.version
+	return chart, cached.version, nil
+}
+
+// newestVersion returns the newest of the provided versions that satisfies the constraint.
+func newestVersion(constraint *semver.Constraints, versions []string) (string, error) {
+	var newest *semver.Version
+	var original string
+	for _, version := range versions {
+		v, err := semver.NewVersion(version)
+		if err != nil || !constraint.Check(v) {
+			continue
+		}
+		if newest == nil || v.GreaterThan(newest) {
+			newest = v
+			original = version
+		}
+	}
+	if newest == nil {
+		return "", fmt.Errorf("no versions match %s", constraint)
+	}
+	return original, nil
+}
+
+// listChartVersions returns all versions of the chart available from the chart repository or OCI registry.
+func (c *Controller) listChartVersions(ctx context.Context, chart *v1.HelmChart) ([]string, error) {
+	client, err := c.repoClient(chart)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasPrefix(chart.Spec.Chart, "oci://"):
+		return c.listOCITags(ctx, chart, client)
+	case chart.Spec.Repo != "":
+		return c.listIndexVersions(ctx, chart, client)
+	default:
+		return nil, errors.New("version ranges are only supported for charts from a repository or OCI registry")
+	}
+}
+
+// repoClient returns an HTTP client that trusts the chart's repository CAs, and respects the chart's TLS verification setting.
+func (c *Controller) repoClient(chart *v1.HelmChart) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: chart.Spec.InsecureSkipTLSVerify,
+	}
+	var pems []string
+	if chart.Spec.RepoCA != "" {
+		pems = append(pems, chart.Spec.RepoCA)
+	}
+	if ref := chart.Spec.RepoCAConfigMap; ref != nil {
+		cm, err := c.referencedConfigMap(chart, chart.Spec.RepoCAConfigMapNamespace, ref.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get repo CA ConfigMap: %w", err)
+		}
+		for _, data := range cm.Data {
+			pems = append(pems, data)
+		}
+	}
+	if len(pems) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, pem := range pems {
+			pool.AppendCertsFromPEM([]byte(pem))
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: 30 * time.Second}, nil
+}
+
+// listIndexVersions returns the versions of the chart listed in the repository index.
+func (c *Controller) listIndexVersions(ctx context.Context, chart *v1.HelmChart, client *http.Client) ([]string, error) {
+	b, err := c.getIndex(ctx, chart, client)
+	if err != nil {
+		return nil, err
+	}
+	return indexVersions(b, chart.Spec.Chart)
+}
+
+// getIndex retrieves the chart repository index.
+func (c *Controller) getIndex(ctx context.Context, chart *v1.HelmChart, client *http.Client) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(chart.Spec.Repo, "/")+"/index.yaml", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.setRepoAuth(chart, req); err != nil {
+		return nil, err
+	}
+	return doRequest(client, req, maxIndexBytes)
+}
+
+// setRepoAuth adds the credentials from the chart's auth secret to the request, if any.
+func (c *Controller) setRepoAuth(chart *v1.HelmChart, req *http.Request) error {
+	ref := chart.Spec.AuthSecret
+	if ref == nil {
+		return nil
+	}
+	secret, err := c.referencedSecret(chart, chart.Spec.AuthSecretNamespace, ref.Name)
+	if err != nil {
+		return fmt.Errorf("failed to get auth secret: %w", err)
+	}
+	req.SetBasicAuth(string(secret.Data[corev1.BasicAuthUsernameKey]), string(secret.Data[corev1.BasicAuthPasswordKey]))
+	return nil
+}
+
+// indexEntry is a chart version listed in a repository index.
+type indexEntry struct {
+	Version string   `json:"version"`
+	URLs    []string `json:"urls"`
+	Digest  string   `json:"digest"`
+}
+
+// indexEntries returns the entries for the named chart from the provided repository index.
+func indexEntries(b []byte, name string) ([]indexEntry, error) {
+	index := struct {
+		Entries map[string][]indexEntry `json:"entries"`
+	}{}
+	if err := yaml.Unmarshal(b, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse repository index: %w", err)
+	}
+	entries, ok := index.Entries[name]
+	if !ok {
+		return nil, fmt.Errorf("chart %s not found in repository index", name)
+	}
+	return entries, nil
+}
+
+// indexVersions returns the versions of the named chart from the provided repository index.
+func indexVersions(b []byte, name string) ([]string, error) {
+	entries, err := indexEntries(b, name)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		versions = append(versions, entry.Version)
+	}
+	return versions, nil
+}
+
+// ociRepository returns the base URL of the registry API for the chart's OCI repository, along with the
+// registry host.
+func ociRepository(chart *v1.HelmChart) (string, string) {
+	host, repository, _ := strings.Cut(strings.TrimPrefix(chart.Spec.Chart, "oci://"), "/")
+	scheme := "https"
+	if chart.Spec.PlainHTTP {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/v2/%s", scheme, host, repository), host
+}
+
+// listOCITags returns the tags of the chart's OCI repository, as chart versions. Helm replaces the
+// `+` in semver build metadata with `_` when pushing tags, so this is reversed.
+func (c *Controller) listOCITags(ctx context.Context, chart *v1.HelmChart, client *http.Client) ([]string, error) {
+	base, host := ociRepository(chart)
+	username, password, err := c.registryCredentials(chart, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	next := base + "/tags/list"
+	var token string
+	for next != "" {
+		resp, err := registryGet(ctx, client, next, "", username, password, &token)
+		if err != nil {
+			return nil, err
+		}
+		b, err := readResponse(resp, maxIndexBytes)
+		if err != nil {
+			return nil, err
+		}
+		tags := struct {
+			Tags []string `json:"tags"`
+		}{}
+		if err := json.Unmarshal(b, &tags); err != nil {
+			return nil, fmt.Errorf("failed to parse tag list: %w", err)
+		}
+		for _, tag := range tags.Tags {
+			versions = append(versions, strings.ReplaceAll(tag, "_", "+"))
+		}
+		next = nextLink(resp.Request.URL, resp.Header.Get("Link"))
+	}
+	return versions, nil
+}
+
+// registryGet sends a GET request to the registry. If the registry responds with an auth challenge,
+// a bearer token is retrieved and the request is retried; the token is retained for subsequent requests.
+func registryGet(ctx context.Context, client *http.Client, url, accept, username, password string, token *string) (*http.Response, error) {
+	for challenged := false; ; challenged = true {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if *token != "" {
+			req.Header.Set("Authorization", "Bearer "+*token)
+		} else if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || *token != "" || challenged {
+			return resp, nil
+		}
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if *token, err = registryToken(ctx, client, challenge, username, password); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// registryCredentials returns the credentials for the registry host from the chart's docker registry secret, if any.
+func (c *Controller) registryCredentials(chart *v1.HelmChart, host string) (string, string, error) {
+	ref := chart.Spec.DockerRegistrySecret
+	if ref == nil {
+		return "", "", nil
+	}
+	secret, err := c.referencedSecret(chart, chart.Spec.DockerRegistrySecretNamespace, ref.Name)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get docker registry secret: %w", err)
+	}
+	config := struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}{}
+	if err := json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], &config); err != nil {
+		return "", "", fmt.Errorf("failed to parse docker registry secret: %w", err)
+	}
+	for server, auth := range config.Auths {
+		if server != host && !strings.HasPrefix(server, "https://"+host) && !strings.HasPrefix(server, "http://"+host) {
+			continue
+		}
+		if auth.Auth != "" {
+			if b, err := base64.StdEncoding.DecodeString(auth.Auth); err == nil {
+				username, password, _ := strings.Cut(string(b), ":")
+				return username, password, nil
+			}
+		}
+		return auth.Username, auth.Password, nil
+	}
+	return "", "", nil
+}
+
+// registryToken retrieves a bearer token from the realm specified in the registry's auth challenge.
+func registryToken(ctx context.Context, client *http.Client, challenge, username, password string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported registry auth challenge: %q", challenge)
+	}
+	values := url.Values{}
+	var realm string
+	for _, match := range challengeParamRE.FindAllStringSubmatch(params, -1) {
+		if match[1] == "realm" {
+			realm = match[2]
+		} else {
+			values.Set(match[1], match[2])
+		}
+	}
+	if realm == "" {
+		return "", fmt.Errorf("registry auth challenge is missing realm: %q", challenge)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm+"?"+values.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	b, err := doRequest(client, req, 1024*1024)
+	if err != nil {
+		return "", err
+	}
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.Unmarshal(b, &token); err != nil {
+		return "", fmt.Errorf("failed to parse registry token: %w", err)
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+// nextLink returns the absolute URL of the next page from an RFC5988 Link header, if present.
+func nextLink(base *url.URL, link string) string {
+	if !strings.Contains(link, `rel="next"`) {
+		return ""
+	}
+	start, end := strings.Index(link, "<"), strings.Index(link, ">")
+	if start == -1 || end < start {
+		return ""
+	}
+	next, err := base.Parse(link[start+1 : end])
+	if err != nil {
+		return ""
+	}
+	return next.String()
+}
+
+func doRequest(client *http.Client, req *http.Request, limit int64) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return readResponse(resp, limit)
+}
+
+func readResponse(resp *http.Response, limit int64) ([]byte, error) {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s from %s", resp.Status, resp.Request.URL.Redacted())
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+// resolvedVersion returns the chart version passed to the job, if the chart's version is a range.
+func resolvedVersion(chart *v1.HelmChart, job *batch.Job) string {
+	if !resolvesVersionRange(chart) {
+		return ""
+	}
+	for _, env := range job.Spec.Template.Spec.Containers[0].Env {
+		if env.Name == "VERSION" {
+			return env.Value
+		}
+	}
+	return ""
+}
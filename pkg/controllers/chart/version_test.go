@@ -0,0 +1,133 @@
+package chart
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsVersionRange(t *testing.T) {
+	tests := map[string]bool{
+		"":          false,
+		"1.2.3":     false,
+		"v1.2.3":    false,
+		"1.2.3-rc1": false,
+		"~1.4":      true,
+		">=2.0 <3":  true,
+		"1.x":       true,
+		"^1.2.3":    true,
+		"latest":    false,
+	}
+	for version, expected := range tests {
+		assert.Equal(t, expected, isVersionRange(version), version)
+	}
+}
+
+func TestResolveChartVersionPassThrough(t *testing.T) {
+	assert := assert.New(t)
+	c := &Controller{}
+
+	// ranges are passed through as-is for charts that are not from a repository or OCI registry
+	for _, spec := range []struct{ chart, content string }{
+		{chart: "https://example.com/charts/traefik-1.2.0.tgz"},
+		{content: "H4sIAAAAAAAAA+3BAQ0AAADCoPdPbQ8HFAAAAAAAAAAAAAAAAAAAAIA3A5reHScAKAAA"},
+	} {
+		chart := NewChart()
+		chart.Spec.Repo = ""
+		chart.Spec.Chart = spec.chart
+		chart.Spec.ChartContent = spec.content
+		chart.Spec.Version = "~1.2"
+		resolved, version, err := c.resolveChartVersion(chart)
+		assert.NoError(err)
+		assert.Empty(version)
+		assert.Same(chart, resolved)
+	}
+}
+
+func TestNewestVersion(t *testing.T) {
+	assert := assert.New(t)
+	versions := []string{"1.3.9", "1.4.0", "1.4.2", "1.5.0", "2.0.0", "2.1.0-rc.1", "invalid"}
+
+	tests := map[string]string{
+		"~1.4":     "1.4.2",
+		">=2.0 <3": "2.0.0",
+		"1.x":      "1.5.0",
+	}
+	for version, expected := range tests {
+		constraint, err := semver.NewConstraint(version)
+		assert.NoError(err)
+		got, err := newestVersion(constraint, versions)
+		assert.NoError(err)
+		assert.Equal(expected, got, version)
+	}
+
+	constraint, _ := semver.NewConstraint(">=3")
+	_, err := newestVersion(constraint, versions)
+	assert.Error(err)
+}
+
+func TestListIndexVersions(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/charts/index.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "apiVersion: v1\nentries:\n  traefik:\n  - version: 1.0.0\n  - version: 1.1.0\n  other:\n  - version: 9.0.0\n")
+	}))
+	defer server.Close()
+
+	c := &Controller{}
+	chart := NewChart()
+	chart.Spec.Repo = server.URL + "/charts/"
+	chart.Spec.Chart = "traefik"
+	versions, err := c.listChartVersions(context.Background(), chart)
+	assert.NoError(err)
+	assert.Equal([]string{"1.0.0", "1.1.0"}, versions)
+
+	chart.Spec.Chart = "missing"
+	_, err = c.listChartVersions(context.Background(), chart)
+	assert.Error(err)
+}
+
+func TestListOCITags(t *testing.T) {
+	assert := assert.New(t)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			assert.Equal("repository:charts/traefik:pull", r.URL.Query().Get("scope"))
+			fmt.Fprint(w, `{"token":"abc"}`)
+		case "/v2/charts/traefik/tags/list":
+			if r.Header.Get("Authorization") != "Bearer abc" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:charts/traefik:pull"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("last") == "" {
+				w.Header().Set("Link", `</v2/charts/traefik/tags/list?last=1.0.0>; rel="next"`)
+				fmt.Fprint(w, `{"tags":["1.0.0"]}`)
+				return
+			}
+			fmt.Fprint(w, `{"tags":["1.1.0_build.1"]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := &Controller{}
+	chart := NewChart()
+	chart.Spec.Repo = ""
+	chart.Spec.Chart = "oci://" + strings.TrimPrefix(server.URL, "http://") + "/charts/traefik"
+	chart.Spec.PlainHTTP = true
+	versions, err := c.listChartVersions(context.Background(), chart)
+	assert.NoError(err)
+	assert.Equal([]string{"1.0.0", "1.1.0+build.1"}, versions)
+}
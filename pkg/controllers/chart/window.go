@@ -0,0 +1,124 @@
+package chart
+
+import (
+	"fmt"
+	"time"
+	// embed the time zone database, as the controller image does not include one
+	_ "time/tzdata"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// upgradeWindow returns the upgrade window for the chart. The window set on the highest priority HelmChartConfig, if any, takes precedence.
+func (c *Controller) upgradeWindow(chart *v1.HelmChart) (*v1.UpgradeWindow, error) {
+	configs, err := c.getChartConfigs(chart)
+	if err != nil {
+		return nil, err
+	}
+	window := chart.Spec.UpgradeWindow
+	for _, config := range configs {
+		if config.Spec.UpgradeWindow != nil {
+			window = config.Spec.UpgradeWindow
+		}
+	}
+	return window, nil
+}
+
+// windowOpen returns true if the time falls within the upgrade window. If the window is not open,
+// the time at which it next opens is also returned; this is zero if the schedules never match.
+func windowOpen(window *v1.UpgradeWindow, now time.Time) (bool, time.Time, error) {
+	loc := time.UTC
+	if window.TimeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(window.TimeZone); err != nil {
+			return false, time.Time{}, fmt.Errorf("invalid upgrade window time zone %q: %w", window.TimeZone, err)
+		}
+	}
+	now = now.In(loc)
+
+	var next time.Time
+	for _, spec := range window.Schedules {
+		schedule, err := cron.ParseStandard(spec)
+		if err != nil {
+			return false, time.Time{}, fmt.Errorf("invalid upgrade window schedule %q: %w", spec, err)
+		}
+		// the window is open if the schedule started within the window duration preceding now
+		if start := schedule.Next(now.Add(-window.Duration.Duration)); !start.IsZero() && !start.After(now) {
+			return true, time.Time{}, nil
+		}
+		if start := schedule.Next(now); !start.IsZero() && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	}
+	return false, next, nil
+}
+
+// checkUpgradeWindow returns the conditions that should be set on the chart if changes to the release
+// are held until the upgrade window opens, or nil if the chart is free to proceed. Dry runs are not
+// held, nor are rollbacks unless the window defers them. The chart is enqueued to be processed again
+// when the window next opens.
+func (c *Controller) checkUpgradeWindow(chart *v1.HelmChart) ([]v1.HelmChartCondition, error) {
+	if chart.Spec.DryRun && chart.Spec.RollbackTo == nil {
+		return nil, nil
+	}
+	window, err := c.upgradeWindow(chart)
+	if err != nil || window == nil {
+		return nil, err
+	}
+	if chart.Spec.Bootstrap && !window.DeferBootstrap {
+		return nil, nil
+	}
+	if chart.Spec.RollbackTo != nil && !window.DeferRollback {
+		return nil, nil
+	}
+
+	open, next, err := windowOpen(window, time.Now())
+	if err != nil || open {
+		return nil, err
+	}
+
+	message := "Changes will not be applied until the upgrade window opens"
+	if !next.IsZero() {
+		c.helms.EnqueueAfter(chart.Namespace, chart.Name, time.Until(next))
+		message = fmt.Sprintf("Changes will be applied when the upgrade window opens at %s", next.Format(time.RFC3339))
+	}
+	return []v1.HelmChartCondition{
+		{
+			Type:   v1.HelmChartJobCreated,
+			Status: corev1.ConditionFalse,
+		},
+		{
+			Type:    v1.HelmChartPending,
+			Status:  corev1.ConditionTrue,
+			Reason:  "Outside upgrade window",
+			Message: message,
+		},
+	}, nil
+}
+
+// deletionHeld returns true if uninstalling the chart is deferred until the upgrade window opens.
+// The chart is enqueued to be processed again when the window next opens.
+func (c *Controller) deletionHeld(chart *v1.HelmChart) (bool, error) {
+	window, err := c.upgradeWindow(chart)
+	if err != nil || window == nil || !window.DeferDeletion {
+		return false, err
+	}
+	if chart.Spec.Bootstrap && !window.DeferBootstrap {
+		return false, nil
+	}
+
+	open, next, err := windowOpen(window, time.Now())
+	if err != nil || open {
+		return false, err
+	}
+	if !next.IsZero() {
+		c.helms.EnqueueAfter(chart.Namespace, chart.Name, time.Until(next))
+	}
+	c.logger.V(1).Info("Uninstall held until upgrade window opens",
+		"chart.name", fmt.Sprintf("%s/%s", chart.Namespace, chart.Name),
+		"window.next", next,
+	)
+	return true, nil
+}
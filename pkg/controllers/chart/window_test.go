@@ -0,0 +1,79 @@
+package chart
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestWindowOpen(t *testing.T) {
+	window := &v1.UpgradeWindow{
+		// Saturdays at 02:00, and Wednesdays at 22:00
+		Schedules: []string{"0 2 * * 6", "0 22 * * 3"},
+		Duration:  metav1.Duration{Duration: 2 * time.Hour},
+		TimeZone:  "Europe/Berlin",
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected bool
+		next     time.Time
+	}{
+		{"at start", time.Date(2026, 10, 17, 2, 0, 0, 0, berlin), true, time.Time{}},
+		{"within window", time.Date(2026, 10, 17, 3, 30, 0, 0, berlin), true, time.Time{}},
+		{"within window utc", time.Date(2026, 10, 17, 1, 30, 0, 0, time.UTC), true, time.Time{}},
+		{"after window", time.Date(2026, 10, 17, 4, 0, 0, 0, berlin), false, time.Date(2026, 10, 21, 22, 0, 0, 0, berlin)},
+		{"before window", time.Date(2026, 10, 21, 21, 0, 0, 0, berlin), false, time.Date(2026, 10, 21, 22, 0, 0, 0, berlin)},
+		{"overnight window", time.Date(2026, 10, 21, 23, 59, 0, 0, berlin), true, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			open, next, err := windowOpen(window, tt.now)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, open)
+			assert.True(t, tt.next.Equal(next), "expected next %s, got %s", tt.next, next)
+		})
+	}
+
+	_, _, err := windowOpen(&v1.UpgradeWindow{Schedules: []string{"0 2 * *"}}, time.Now())
+	assert.Error(t, err)
+	_, _, err = windowOpen(&v1.UpgradeWindow{Schedules: []string{"@daily"}, TimeZone: "Mars/Olympus"}, time.Now())
+	assert.Error(t, err)
+}
+
+func TestCheckUpgradeWindowRollback(t *testing.T) {
+	assert := assert.New(t)
+	c := &Controller{
+		helms:     &fakeHelmChartController{},
+		confCache: fakeConfigCache{},
+	}
+	// a window that opens for one minute a year, one hour from now
+	start := time.Now().UTC().Add(time.Hour)
+	chart := NewChart()
+	chart.Spec.UpgradeWindow = &v1.UpgradeWindow{
+		Schedules: []string{start.Format("4 15 2 1 *")},
+		Duration:  metav1.Duration{Duration: time.Minute},
+	}
+
+	conditions, err := c.checkUpgradeWindow(chart)
+	assert.NoError(err)
+	assert.Len(conditions, 2)
+
+	// rollbacks are not held by the window, unless it defers them
+	chart.Spec.RollbackTo = ptr.To[int64](0)
+	conditions, err = c.checkUpgradeWindow(chart)
+	assert.NoError(err)
+	assert.Nil(conditions)
+
+	chart.Spec.UpgradeWindow.DeferRollback = true
+	conditions, err = c.checkUpgradeWindow(chart)
+	assert.NoError(err)
+	assert.Len(conditions, 2)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/k3s-io/helm-controller/pkg/controllers/chart"
 	"github.com/k3s-io/helm-controller/pkg/generated/controllers/helm.cattle.io"
 	helmcontroller "github.com/k3s-io/helm-controller/pkg/generated/controllers/helm.cattle.io/v1"
+	"github.com/k3s-io/helm-controller/pkg/notify"
 	"github.com/rancher/lasso/pkg/cache"
 	"github.com/rancher/lasso/pkg/client"
 	"github.com/rancher/lasso/pkg/controller"
@@ -28,6 +29,7 @@ import (
 	"github.com/rancher/wrangler/v3/pkg/start"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	typedv1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
@@ -44,10 +46,11 @@ const (
 type appContext struct {
 	helmcontroller.Interface
 
-	K8s   kubernetes.Interface
-	Core  corecontroller.Interface
-	RBAC  rbaccontroller.Interface
-	Batch batchcontroller.Interface
+	K8s     kubernetes.Interface
+	Dynamic dynamic.Interface
+	Core    corecontroller.Interface
+	RBAC    rbaccontroller.Interface
+	Batch   batchcontroller.Interface
 
 	Apply            apply.Apply
 	EventBroadcaster record.EventBroadcaster
@@ -90,8 +93,12 @@ func Register(ctx context.Context, systemNamespace, controllerName string, cfg c
 	}
 	chart.JobResources = opts.JobResources
 	chart.JobTolerations = opts.JobTolerations
+	chart.DefaultDriftDetectionInterval = opts.DriftDetectionInterval
+	chart.CacheChartArchives = opts.CacheChartArchives
+	chart.Notifier = notify.New(opts.Notifications)
+	chart.Notifier.Start(ctx)
 
-	chart.Register(ctx,
+	chart.RegisterWithOptions(ctx,
 		systemNamespace,
 		controllerName,
 		opts.JobClusterRole,
@@ -110,6 +117,11 @@ func Register(ctx context.Context, systemNamespace, controllerName string, cfg c
 		appCtx.Core.ConfigMap(),
 		appCtx.Core.Secret(),
 		appCtx.Core.Secret().Cache(),
+		chart.Options{
+			Dynamic:         appCtx.Dynamic,
+			ReferenceGrants: appCtx.HelmChartReferenceGrant(),
+			RoleBindings:    appCtx.RBAC.RoleBinding(),
+		},
 	)
 
 	resources, _ := json.Marshal(chart.JobResources)
@@ -119,6 +131,9 @@ func Register(ctx context.Context, systemNamespace, controllerName string, cfg c
 	logger.Info("Using default image for jobs managing helm charts", "defaultJobImage", chart.DefaultJobImage)
 	logger.Info("Using resource limits for jobs managing helm charts", "jobResources", string(resources))
 	logger.Info("Using tolerations for jobs managing helm charts", "jobTolerationsCount", len(chart.JobTolerations))
+	logger.Info("Using default interval for drift detection", "driftDetectionInterval", chart.DefaultDriftDetectionInterval)
+	logger.Info("Using controller cache for chart archives", "cacheChartArchives", chart.CacheChartArchives)
+	logger.Info("Sending notifications for release lifecycle transitions", "notificationURLs", len(opts.Notifications.URLs))
 
 	if len(systemNamespace) == 0 {
 		systemNamespace = metav1.NamespaceSystem
@@ -170,6 +185,11 @@ func newContext(ctx context.Context, cfg clientcmd.ClientConfig, systemNamespace
 		return nil, err
 	}
 
+	dynamic, err := dynamic.NewForConfig(client)
+	if err != nil {
+		return nil, err
+	}
+
 	scf, err := controllerFactory(client)
 	if err != nil {
 		return nil, err
@@ -214,10 +234,11 @@ func newContext(ctx context.Context, cfg clientcmd.ClientConfig, systemNamespace
 	return &appContext{
 		Interface: helmv,
 
-		K8s:   k8s,
-		Core:  corev,
-		Batch: batchv,
-		RBAC:  rbacv,
+		K8s:     k8s,
+		Dynamic: dynamic,
+		Core:    corev,
+		Batch:   batchv,
+		RBAC:    rbacv,
 
 		Apply:            apply,
 		EventBroadcaster: record.NewBroadcaster(record.WithContext(ctx)),
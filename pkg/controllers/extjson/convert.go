@@ -27,12 +27,19 @@ func TryToYAML(j *apiextv1.JSON) string {
 // TryFromYAML attempts to convert a YAML string to [apiextv1.JSON].
 // It returns nil if the input is empty or if the conversion fails, effectively swallowing any parsing errors.
 func TryFromYAML(s string) *apiextv1.JSON {
+	j, _ := FromYAML(s)
+	return j
+}
+
+// FromYAML converts a YAML string to [apiextv1.JSON].
+// It returns nil if the input is empty, or an error if the input cannot be parsed as YAML.
+func FromYAML(s string) (*apiextv1.JSON, error) {
 	if len(s) == 0 {
-		return nil
+		return nil, nil
 	}
 	b, err := yaml.YAMLToJSON([]byte(s))
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	return &apiextv1.JSON{Raw: b}
+	return &apiextv1.JSON{Raw: b}, nil
 }
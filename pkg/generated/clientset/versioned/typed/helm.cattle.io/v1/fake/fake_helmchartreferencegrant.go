@@ -0,0 +1,52 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by main. DO NOT EDIT.
+
+package fake
+
+import (
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	helmcattleiov1 "github.com/k3s-io/helm-controller/pkg/generated/clientset/versioned/typed/helm.cattle.io/v1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeHelmChartReferenceGrants implements HelmChartReferenceGrantInterface
+type fakeHelmChartReferenceGrants struct {
+	*gentype.FakeClientWithList[*v1.HelmChartReferenceGrant, *v1.HelmChartReferenceGrantList]
+	Fake *FakeHelmV1
+}
+
+func newFakeHelmChartReferenceGrants(fake *FakeHelmV1, namespace string) helmcattleiov1.HelmChartReferenceGrantInterface {
+	return &fakeHelmChartReferenceGrants{
+		gentype.NewFakeClientWithList[*v1.HelmChartReferenceGrant, *v1.HelmChartReferenceGrantList](
+			fake.Fake,
+			namespace,
+			v1.SchemeGroupVersion.WithResource("helmchartreferencegrants"),
+			v1.SchemeGroupVersion.WithKind("HelmChartReferenceGrant"),
+			func() *v1.HelmChartReferenceGrant { return &v1.HelmChartReferenceGrant{} },
+			func() *v1.HelmChartReferenceGrantList { return &v1.HelmChartReferenceGrantList{} },
+			func(dst, src *v1.HelmChartReferenceGrantList) { dst.ListMeta = src.ListMeta },
+			func(list *v1.HelmChartReferenceGrantList) []*v1.HelmChartReferenceGrant {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1.HelmChartReferenceGrantList, items []*v1.HelmChartReferenceGrant) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
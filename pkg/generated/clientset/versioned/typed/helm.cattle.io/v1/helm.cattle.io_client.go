@@ -30,6 +30,7 @@ type HelmV1Interface interface {
 	RESTClient() rest.Interface
 	HelmChartsGetter
 	HelmChartConfigsGetter
+	HelmChartReferenceGrantsGetter
 }
 
 // HelmV1Client is used to interact with features provided by the helm.cattle.io group.
@@ -45,6 +46,10 @@ func (c *HelmV1Client) HelmChartConfigs(namespace string) HelmChartConfigInterfa
 	return newHelmChartConfigs(c, namespace)
 }
 
+func (c *HelmV1Client) HelmChartReferenceGrants(namespace string) HelmChartReferenceGrantInterface {
+	return newHelmChartReferenceGrants(c, namespace)
+}
+
 // NewForConfig creates a new HelmV1Client for the given config.
 // NewForConfig is equivalent to NewForConfigAndClient(c, httpClient),
 // where httpClient was generated with rest.HTTPClientFor(c).
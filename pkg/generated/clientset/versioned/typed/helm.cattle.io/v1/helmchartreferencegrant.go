@@ -0,0 +1,70 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by main. DO NOT EDIT.
+
+package v1
+
+import (
+	context "context"
+
+	helmcattleiov1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	scheme "github.com/k3s-io/helm-controller/pkg/generated/clientset/versioned/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// HelmChartReferenceGrantsGetter has a method to return a HelmChartReferenceGrantInterface.
+// A group's client should implement this interface.
+type HelmChartReferenceGrantsGetter interface {
+	HelmChartReferenceGrants(namespace string) HelmChartReferenceGrantInterface
+}
+
+// HelmChartReferenceGrantInterface has methods to work with HelmChartReferenceGrant resources.
+type HelmChartReferenceGrantInterface interface {
+	Create(ctx context.Context, helmChartReferenceGrant *helmcattleiov1.HelmChartReferenceGrant, opts metav1.CreateOptions) (*helmcattleiov1.HelmChartReferenceGrant, error)
+	Update(ctx context.Context, helmChartReferenceGrant *helmcattleiov1.HelmChartReferenceGrant, opts metav1.UpdateOptions) (*helmcattleiov1.HelmChartReferenceGrant, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*helmcattleiov1.HelmChartReferenceGrant, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*helmcattleiov1.HelmChartReferenceGrantList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *helmcattleiov1.HelmChartReferenceGrant, err error)
+	HelmChartReferenceGrantExpansion
+}
+
+// helmChartReferenceGrants implements HelmChartReferenceGrantInterface
+type helmChartReferenceGrants struct {
+	*gentype.ClientWithList[*helmcattleiov1.HelmChartReferenceGrant, *helmcattleiov1.HelmChartReferenceGrantList]
+}
+
+// newHelmChartReferenceGrants returns a HelmChartReferenceGrants
+func newHelmChartReferenceGrants(c *HelmV1Client, namespace string) *helmChartReferenceGrants {
+	return &helmChartReferenceGrants{
+		gentype.NewClientWithList[*helmcattleiov1.HelmChartReferenceGrant, *helmcattleiov1.HelmChartReferenceGrantList](
+			"helmchartreferencegrants",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *helmcattleiov1.HelmChartReferenceGrant { return &helmcattleiov1.HelmChartReferenceGrant{} },
+			func() *helmcattleiov1.HelmChartReferenceGrantList {
+				return &helmcattleiov1.HelmChartReferenceGrantList{}
+			},
+		),
+	}
+}
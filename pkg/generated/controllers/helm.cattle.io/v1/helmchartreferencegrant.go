@@ -0,0 +1,39 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by main. DO NOT EDIT.
+
+package v1
+
+import (
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/generic"
+)
+
+// HelmChartReferenceGrantController interface for managing HelmChartReferenceGrant resources.
+type HelmChartReferenceGrantController interface {
+	generic.ControllerInterface[*v1.HelmChartReferenceGrant, *v1.HelmChartReferenceGrantList]
+}
+
+// HelmChartReferenceGrantClient interface for managing HelmChartReferenceGrant resources in Kubernetes.
+type HelmChartReferenceGrantClient interface {
+	generic.ClientInterface[*v1.HelmChartReferenceGrant, *v1.HelmChartReferenceGrantList]
+}
+
+// HelmChartReferenceGrantCache interface for retrieving HelmChartReferenceGrant resources in memory.
+type HelmChartReferenceGrantCache interface {
+	generic.CacheInterface[*v1.HelmChartReferenceGrant]
+}
@@ -33,6 +33,7 @@ func init() {
 type Interface interface {
 	HelmChart() HelmChartController
 	HelmChartConfig() HelmChartConfigController
+	HelmChartReferenceGrant() HelmChartReferenceGrantController
 }
 
 func New(controllerFactory controller.SharedControllerFactory) Interface {
@@ -52,3 +53,7 @@ func (v *version) HelmChart() HelmChartController {
 func (v *version) HelmChartConfig() HelmChartConfigController {
 	return generic.NewController[*v1.HelmChartConfig, *v1.HelmChartConfigList](schema.GroupVersionKind{Group: "helm.cattle.io", Version: "v1", Kind: "HelmChartConfig"}, "helmchartconfigs", true, v.controllerFactory)
 }
+
+func (v *version) HelmChartReferenceGrant() HelmChartReferenceGrantController {
+	return generic.NewController[*v1.HelmChartReferenceGrant, *v1.HelmChartReferenceGrantList](schema.GroupVersionKind{Group: "helm.cattle.io", Version: "v1", Kind: "HelmChartReferenceGrant"}, "helmchartreferencegrants", true, v.controllerFactory)
+}
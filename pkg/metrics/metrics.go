@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	namespace = "helm_controller"
+
+	handlerLabel        = "handler"
+	resultLabel         = "result"
+	chartNamespaceLabel = "namespace"
+	chartNameLabel      = "chart"
+	actionLabel         = "action"
+	outcomeLabel        = "outcome"
+	statusLabel         = "status"
+
+	ResultSuccess = "success"
+	ResultSkip    = "skip"
+	ResultError   = "error"
+
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+)
+
+var (
+	reconcileTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reconcile_total",
+			Help:      "Total count of HelmChart reconciles, by handler and result",
+		},
+		[]string{handlerLabel, resultLabel},
+	)
+	reconcileDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "reconcile_duration_seconds",
+			Help:      "Histogram of the duration of HelmChart reconciles, by handler",
+		},
+		[]string{handlerLabel},
+	)
+	jobOutcomesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "job_outcomes_total",
+			Help:      "Total count of finished helm Jobs, by chart, action, and outcome",
+		},
+		[]string{chartNamespaceLabel, chartNameLabel, actionLabel, outcomeLabel},
+	)
+	releaseRevision = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "release_revision",
+			Help:      "Revision of the latest helm release for the chart",
+		},
+		[]string{chartNamespaceLabel, chartNameLabel},
+	)
+	releaseStatus = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "release_status",
+			Help:      "Status of the latest helm release for the chart; the value is always 1",
+		},
+		[]string{chartNamespaceLabel, chartNameLabel, statusLabel},
+	)
+	chartsFailed = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "charts_failed",
+			Help:      "Number of HelmCharts with a True Failed condition",
+		},
+	)
+
+	failedMutex  sync.Mutex
+	failedCharts = sets.Set[string]{}
+)
+
+// MustRegister registers all helm-controller metrics with the provided registerer
+func MustRegister(registerer prometheus.Registerer) {
+	registerer.MustRegister(
+		reconcileTotal,
+		reconcileDuration,
+		jobOutcomesTotal,
+		releaseRevision,
+		releaseStatus,
+		chartsFailed,
+	)
+}
+
+// ObserveReconcile records the result and duration of a reconcile by the named handler
+func ObserveReconcile(handler string, start time.Time, err error) {
+	result := ResultSuccess
+	if errors.Is(err, generic.ErrSkip) {
+		result = ResultSkip
+	} else if err != nil {
+		result = ResultError
+	}
+	reconcileTotal.With(prometheus.Labels{handlerLabel: handler, resultLabel: result}).Inc()
+	reconcileDuration.With(prometheus.Labels{handlerLabel: handler}).Observe(time.Since(start).Seconds())
+}
+
+// IncJobOutcome increments the count of finished Jobs for the specified chart, action, and outcome
+func IncJobOutcome(chartNamespace, chartName, action, outcome string) {
+	jobOutcomesTotal.With(prometheus.Labels{
+		chartNamespaceLabel: chartNamespace,
+		chartNameLabel:      chartName,
+		actionLabel:         action,
+		outcomeLabel:        outcome,
+	}).Inc()
+}
+
+// SetRelease sets the revision and status of the latest release for the specified chart
+func SetRelease(chartNamespace, chartName string, revision int64, status string) {
+	labels := prometheus.Labels{chartNamespaceLabel: chartNamespace, chartNameLabel: chartName}
+	releaseRevision.With(labels).Set(float64(revision))
+	releaseStatus.DeletePartialMatch(labels)
+	if status != "" {
+		labels[statusLabel] = status
+		releaseStatus.With(labels).Set(1)
+	}
+}
+
+// SetChartFailed tracks whether or not the specified chart has a True Failed condition
+func SetChartFailed(chartNamespace, chartName string, failed bool) {
+	failedMutex.Lock()
+	defer failedMutex.Unlock()
+	key := chartNamespace + "/" + chartName
+	if failed {
+		failedCharts.Insert(key)
+	} else {
+		failedCharts.Delete(key)
+	}
+	chartsFailed.Set(float64(failedCharts.Len()))
+}
+
+// DeleteChart removes all per-chart metrics for the specified chart
+func DeleteChart(chartNamespace, chartName string) {
+	labels := prometheus.Labels{chartNamespaceLabel: chartNamespace, chartNameLabel: chartName}
+	jobOutcomesTotal.DeletePartialMatch(labels)
+	releaseRevision.DeletePartialMatch(labels)
+	releaseStatus.DeletePartialMatch(labels)
+	SetChartFailed(chartNamespace, chartName, false)
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserveReconcile(t *testing.T) {
+	assert := assert.New(t)
+	reconcileTotal.Reset()
+
+	ObserveReconcile("OnChange", time.Now(), nil)
+	ObserveReconcile("OnChange", time.Now(), generic.ErrSkip)
+	ObserveReconcile("OnChange", time.Now(), errors.New("failed"))
+	ObserveReconcile("OnRemove", time.Now(), nil)
+
+	assert.Equal(float64(1), testutil.ToFloat64(reconcileTotal.WithLabelValues("OnChange", ResultSuccess)))
+	assert.Equal(float64(1), testutil.ToFloat64(reconcileTotal.WithLabelValues("OnChange", ResultSkip)))
+	assert.Equal(float64(1), testutil.ToFloat64(reconcileTotal.WithLabelValues("OnChange", ResultError)))
+	assert.Equal(float64(1), testutil.ToFloat64(reconcileTotal.WithLabelValues("OnRemove", ResultSuccess)))
+}
+
+func TestSetRelease(t *testing.T) {
+	assert := assert.New(t)
+	releaseRevision.Reset()
+	releaseStatus.Reset()
+
+	SetRelease("kube-system", "traefik", 1, "pending-install")
+	SetRelease("kube-system", "traefik", 2, "deployed")
+
+	assert.Equal(float64(2), testutil.ToFloat64(releaseRevision.WithLabelValues("kube-system", "traefik")))
+	assert.Equal(1, testutil.CollectAndCount(releaseStatus))
+	assert.Equal(float64(1), testutil.ToFloat64(releaseStatus.WithLabelValues("kube-system", "traefik", "deployed")))
+
+	DeleteChart("kube-system", "traefik")
+	assert.Equal(0, testutil.CollectAndCount(releaseRevision))
+	assert.Equal(0, testutil.CollectAndCount(releaseStatus))
+}
+
+func TestSetChartFailed(t *testing.T) {
+	assert := assert.New(t)
+
+	SetChartFailed("kube-system", "traefik", true)
+	SetChartFailed("kube-system", "traefik", true)
+	SetChartFailed("kube-system", "coredns", true)
+	assert.Equal(float64(2), testutil.ToFloat64(chartsFailed))
+
+	SetChartFailed("kube-system", "traefik", false)
+	assert.Equal(float64(1), testutil.ToFloat64(chartsFailed))
+
+	DeleteChart("kube-system", "coredns")
+	assert.Equal(float64(0), testutil.ToFloat64(chartsFailed))
+}
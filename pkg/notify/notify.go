@@ -0,0 +1,241 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// EventApplying is sent when a job to install or upgrade the chart is first observed after it has been created.
+	EventApplying = "applying"
+	// EventSucceeded is sent when a job to install or upgrade the chart has completed.
+	EventSucceeded = "succeeded"
+	// EventFailed is sent when a job to install, upgrade, roll back, or uninstall the chart has failed.
+	EventFailed = "failed"
+	// EventRolledBack is sent when a job to roll back the chart has completed.
+	EventRolledBack = "rolledback"
+	// EventUninstalling is sent when a job is created to uninstall the chart.
+	EventUninstalling = "uninstalling"
+	// EventUninstalled is sent when a job to uninstall the chart has completed.
+	EventUninstalled = "uninstalled"
+
+	// SignatureHeader is the header that holds the HMAC-SHA256 signature of the request body, if a secret is configured.
+	SignatureHeader = "X-Helm-Controller-Signature"
+
+	// typePrefix is prepended to the event name to form the CloudEvents type.
+	typePrefix = "io.cattle.helm.chart."
+	// queueSize limits the number of notifications waiting to be sent. Notifications are dropped if the queue is full.
+	queueSize = 100
+)
+
+var (
+	// Events are the supported event names.
+	Events = []string{EventApplying, EventSucceeded, EventFailed, EventRolledBack, EventUninstalling, EventUninstalled}
+
+	// retryInterval is the initial interval between attempts to send a notification; it doubles after each attempt.
+	retryInterval = time.Second
+)
+
+// Options configures delivery of notifications.
+type Options struct {
+	// URLs of the endpoints that notifications are posted to. Notifications are disabled if empty.
+	URLs []string
+	// Secret used to sign the request body. Requests are not signed if empty.
+	Secret string
+	// Events to send notifications for. Notifications are sent for all events if empty.
+	Events []string
+	// Selector restricts notifications to charts with matching labels, if set.
+	Selector labels.Selector
+	// Retries is the number of times that sending a notification to an endpoint is retried.
+	Retries int
+}
+
+// Validate returns an error if any of the event names are not supported.
+func (o Options) Validate() error {
+	for _, event := range o.Events {
+		if !slices.Contains(Events, event) {
+			return fmt.Errorf("unsupported notification event %q; must be one of %v", event, Events)
+		}
+	}
+	return nil
+}
+
+// CloudEvent is a CloudEvents v1.0 event, in the structured JSON format.
+type CloudEvent struct {
+	SpecVersion     string `json:"specversion"`
+	ID              string `json:"id"`
+	Source          string `json:"source"`
+	Type            string `json:"type"`
+	Subject         string `json:"subject"`
+	Time            string `json:"time"`
+	DataContentType string `json:"datacontenttype"`
+	Data            Data   `json:"data"`
+}
+
+// Data describes the chart and the transition that the event was sent for.
+type Data struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Chart     string `json:"chart,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Revision  int64  `json:"revision,omitempty"`
+	Job       string `json:"job,omitempty"`
+	Message   string `json:"message"`
+}
+
+// Notifier posts CloudEvents for HelmChart lifecycle transitions to the configured endpoints. Events are
+// queued and sent in the background, so that reconciling charts is not blocked by slow endpoints. A nil
+// Notifier does not send any events.
+type Notifier struct {
+	opts   Options
+	client *http.Client
+	queue  chan CloudEvent
+	logger klog.Logger
+}
+
+// New returns a Notifier for the provided options, or nil if no endpoints are configured.
+func New(opts Options) *Notifier {
+	if len(opts.URLs) == 0 {
+		return nil
+	}
+	return &Notifier{
+		opts:   opts,
+		client: &http.Client{Timeout: 10 * time.Second},
+		queue:  make(chan CloudEvent, queueSize),
+	}
+}
+
+// Start sends queued events until the context is cancelled.
+func (n *Notifier) Start(ctx context.Context) {
+	if n == nil {
+		return
+	}
+	n.logger = klog.FromContext(ctx)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-n.queue:
+				n.send(ctx, event)
+			}
+		}
+	}()
+}
+
+// Notify queues an event for the chart, if the event and the chart's labels match the configured filters.
+func (n *Notifier) Notify(chart *v1.HelmChart, event, job, message string) {
+	if n == nil || chart == nil {
+		return
+	}
+	if len(n.opts.Events) > 0 && !slices.Contains(n.opts.Events, event) {
+		return
+	}
+	if n.opts.Selector != nil && !n.opts.Selector.Matches(labels.Set(chart.Labels)) {
+		return
+	}
+	version := chart.Spec.Version
+	if chart.Status.ResolvedVersion != "" {
+		version = chart.Status.ResolvedVersion
+	}
+	ce := CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              newID(),
+		Source:          fmt.Sprintf("/apis/%s/namespaces/%s/helmcharts/%s", v1.SchemeGroupVersion, chart.Namespace, chart.Name),
+		Type:            typePrefix + event,
+		Subject:         chart.Name,
+		Time:            time.Now().UTC().Format(time.RFC3339),
+		DataContentType: "application/json",
+		Data: Data{
+			Namespace: chart.Namespace,
+			Name:      chart.Name,
+			Chart:     chart.Spec.Chart,
+			Version:   version,
+			Revision:  chart.Status.ReleaseRevision,
+			Job:       job,
+			Message:   message,
+		},
+	}
+	select {
+	case n.queue <- ce:
+	default:
+		n.logger.Info("Notification queue is full, dropping event", "event.type", ce.Type, "chart.name", chart.Namespace+"/"+chart.Name)
+	}
+}
+
+// send posts the event to each endpoint, retrying failed requests with exponential backoff.
+func (n *Notifier) send(ctx context.Context, event CloudEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		n.logger.Error(err, "Failed to marshal notification", "event.type", event.Type)
+		return
+	}
+	for _, url := range n.opts.URLs {
+		interval := retryInterval
+		for attempt := 0; ; attempt++ {
+			retry, err := n.post(ctx, url, body)
+			if err == nil {
+				break
+			}
+			if !retry || attempt >= n.opts.Retries {
+				n.logger.Error(err, "Failed to send notification", "event.type", event.Type, "event.id", event.ID, "url", url, "attempts", attempt+1)
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+			interval *= 2
+		}
+	}
+}
+
+// post sends the request body to the endpoint, and returns whether the request should be retried if it failed.
+func (n *Notifier) post(ctx context.Context, url string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/cloudevents+json; charset=utf-8")
+	if n.opts.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(n.opts.Secret, body))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout
+	return retry, fmt.Errorf("unexpected status %s", resp.Status)
+}
+
+// Sign returns the signature of the body, as the hex-encoded HMAC-SHA256 with the provided secret, prefixed by the algorithm.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// newID returns a random identifier for an event.
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestNotify(t *testing.T) {
+	assert := assert.New(t)
+	defer func(interval time.Duration) { retryInterval = interval }(retryInterval)
+	retryInterval = time.Millisecond
+
+	attempts := 0
+	received := make(chan CloudEvent, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal("application/cloudevents+json; charset=utf-8", r.Header.Get("Content-Type"))
+		assert.Equal(Sign("secret", body), r.Header.Get(SignatureHeader))
+		event := CloudEvent{}
+		assert.NoError(json.Unmarshal(body, &event))
+		received <- event
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := New(Options{
+		URLs:     []string{server.URL},
+		Secret:   "secret",
+		Events:   []string{EventSucceeded, EventFailed},
+		Selector: labels.SelectorFromSet(labels.Set{"team": "platform"}),
+		Retries:  1,
+	})
+	n.Start(ctx)
+
+	chart := &v1.HelmChart{
+		ObjectMeta: metav1.ObjectMeta{Name: "traefik", Namespace: "kube-system", Labels: map[string]string{"team": "platform"}},
+		Spec:       v1.HelmChartSpec{Chart: "traefik", Version: "~1.0"},
+		Status:     v1.HelmChartStatus{ResolvedVersion: "1.0.2", ReleaseRevision: 3},
+	}
+	other := chart.DeepCopy()
+	other.Labels = nil
+
+	// events and charts that do not match the filters are not sent
+	n.Notify(chart, EventApplying, "helm-install-traefik", "Applying HelmChart")
+	n.Notify(other, EventSucceeded, "helm-install-traefik", "Job completed")
+	n.Notify(chart, EventSucceeded, "helm-install-traefik", "Job completed")
+
+	select {
+	case event := <-received:
+		assert.Equal("1.0", event.SpecVersion)
+		assert.Equal("io.cattle.helm.chart.succeeded", event.Type)
+		assert.Equal("/apis/helm.cattle.io/v1/namespaces/kube-system/helmcharts/traefik", event.Source)
+		assert.Equal(Data{Namespace: "kube-system", Name: "traefik", Chart: "traefik", Version: "1.0.2", Revision: 3, Job: "helm-install-traefik", Message: "Job completed"}, event.Data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for notification")
+	}
+	assert.Equal(2, attempts, "failed request should be retried")
+	assert.Empty(received)
+
+	// a nil notifier does not send events
+	var disabled *Notifier
+	disabled.Notify(chart, EventSucceeded, "", "")
+	assert.Nil(New(Options{}))
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Options{Events: []string{EventApplying, EventUninstalled}}.Validate())
+	assert.Error(t, Options{Events: []string{"upgraded"}}.Validate())
+}
@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	certutil "k8s.io/client-go/util/cert"
+)
+
+const (
+	// certMaxAge is the lifetime of generated serving certificates.
+	certMaxAge = 365 * 24 * time.Hour
+	// certRenewBefore is how long before expiry the serving certificate is regenerated.
+	certRenewBefore = 30 * 24 * time.Hour
+)
+
+// serviceDNSNames returns the names that the webhook Service can be reached at from within the cluster.
+func serviceDNSNames(namespace, service string) []string {
+	return []string{
+		service + "." + namespace + ".svc",
+		service + "." + namespace + ".svc.cluster.local",
+		service + "." + namespace,
+		service,
+	}
+}
+
+// ensureCertificate returns the Secret holding the webhook serving certificate and CA bundle.
+// A new self-signed CA and serving certificate are generated if the Secret does not exist, or the
+// existing certificate is not valid for the Service DNS names or is due for renewal. If another
+// replica stores a certificate first, the stored certificate is returned instead.
+func ensureCertificate(ctx context.Context, secrets typedcorev1.SecretInterface, name string, dnsNames []string) (*corev1.Secret, error) {
+	secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		secret = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get webhook certificate secret: %w", err)
+	} else if certificateValid(secret, dnsNames, time.Now()) {
+		return secret, nil
+	}
+
+	certChain, key, err := certutil.GenerateSelfSignedCertKeyWithOptions(certutil.SelfSignedCertKeyOptions{
+		Host:         dnsNames[0],
+		AlternateDNS: dnsNames[1:],
+		MaxAge:       certMaxAge,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate webhook certificate: %w", err)
+	}
+	// The generated chain contains the serving certificate, followed by the CA that signed it.
+	servingCert, ca := pem.Decode(certChain)
+	data := map[string][]byte{
+		corev1.TLSCertKey:              pem.EncodeToMemory(servingCert),
+		corev1.TLSPrivateKeyKey:        key,
+		corev1.ServiceAccountRootCAKey: ca,
+	}
+
+	if secret == nil {
+		secret, err = secrets.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Type:       corev1.SecretTypeTLS,
+			Data:       data,
+		}, metav1.CreateOptions{})
+	} else {
+		secret = secret.DeepCopy()
+		secret.Data = data
+		secret, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	}
+	// The webhook runs on every replica, so another replica may have stored a certificate since the
+	// Secret was retrieved; use the stored certificate, so that all replicas serve the same CA bundle.
+	if apierrors.IsAlreadyExists(err) || apierrors.IsConflict(err) {
+		return secrets.Get(ctx, name, metav1.GetOptions{})
+	}
+	return secret, err
+}
+
+// certificateValid returns true if the Secret holds a key pair and CA bundle, and the serving
+// certificate is valid for all of the DNS names until the renewal period.
+func certificateValid(secret *corev1.Secret, dnsNames []string, now time.Time) bool {
+	if len(secret.Data[corev1.ServiceAccountRootCAKey]) == 0 {
+		return false
+	}
+	pair, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return false
+	}
+	if now.Before(cert.NotBefore) || now.Add(certRenewBefore).After(cert.NotAfter) {
+		return false
+	}
+	for _, name := range dnsNames {
+		if cert.VerifyHostname(name) != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,380 @@
+package webhook
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/k3s-io/helm-controller/pkg/config"
+	"github.com/k3s-io/helm-controller/pkg/controllers/extjson"
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilvalidation "k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+	typedcore "k8s.io/kubernetes/pkg/apis/core"
+	typedcorev1 "k8s.io/kubernetes/pkg/apis/core/v1"
+	"k8s.io/kubernetes/pkg/apis/core/validation"
+)
+
+var scheme = runtime.NewScheme()
+var opts = validation.PodValidationOptions{}
+
+func init() {
+	typedcorev1.RegisterConversions(scheme)
+}
+
+// Validator validates HelmChart and HelmChartConfig specs, including the existence of referenced Secrets and ConfigMaps.
+type Validator struct {
+	k8s kubernetes.Interface
+}
+
+// NewValidator returns a Validator that uses the provided client to look up referenced resources.
+func NewValidator(k8s kubernetes.Interface) *Validator {
+	return &Validator{k8s: k8s}
+}
+
+// ValidateHelmChart returns a list of errors for invalid fields in the HelmChart spec.
+func (v *Validator) ValidateHelmChart(ctx context.Context, chart *v1.HelmChart) field.ErrorList {
+	spec := chart.Spec
+	specPath := field.NewPath("spec")
+	errs := field.ErrorList{}
+
+	if spec.TargetNamespace != "" {
+		for _, msg := range utilvalidation.IsDNS1123Label(spec.TargetNamespace) {
+			errs = append(errs, field.Invalid(specPath.Child("targetNamespace"), spec.TargetNamespace, msg))
+		}
+	}
+
+	if strings.HasPrefix(spec.Chart, "oci://") {
+		if spec.Repo != "" {
+			errs = append(errs, field.Forbidden(specPath.Child("repo"), "may not be set when chart is an oci:// reference"))
+		}
+	}
+	if spec.Repo != "" {
+		errs = append(errs, validateHTTPURL(spec.Repo, specPath.Child("repo"))...)
+	}
+	if spec.Version != "" {
+		if _, err := semver.NewConstraint(spec.Version); err != nil {
+			errs = append(errs, field.Invalid(specPath.Child("version"), spec.Version, "must be a semver version or range: "+err.Error()))
+		}
+	}
+	if spec.ChartContent != "" {
+		if _, err := base64.StdEncoding.DecodeString(spec.ChartContent); err != nil {
+			errs = append(errs, field.Invalid(specPath.Child("chartContent"), "<chart archive>", "must be a base64-encoded chart archive: "+err.Error()))
+		}
+	}
+
+	errs = append(errs, validateValues(spec.Values, spec.ValuesContent, specPath)...)
+	for key := range spec.Set {
+		if strings.TrimSpace(key) == "" {
+			errs = append(errs, field.Invalid(specPath.Child("set"), key, "keys must not be empty"))
+		}
+	}
+
+	errs = append(errs, validateDuration(spec.Timeout, specPath.Child("timeout"))...)
+	if spec.DriftDetection != nil {
+		errs = append(errs, validateDuration(spec.DriftDetection.Interval, specPath.Child("driftDetection", "interval"))...)
+	}
+	if spec.Test != nil {
+		errs = append(errs, validateDuration(spec.Test.Timeout, specPath.Child("test", "timeout"))...)
+	}
+	if checks := spec.HealthChecks; checks != nil {
+		checksPath := specPath.Child("healthChecks")
+		if !checks.Workloads && !checks.HelmTest {
+			errs = append(errs, field.Required(checksPath, "at least one of workloads or helmTest must be enabled"))
+		}
+		if checks.Timeout != nil && checks.Timeout.Duration <= 0 {
+			errs = append(errs, field.Invalid(checksPath.Child("timeout"), checks.Timeout.Duration.String(), "must be positive"))
+		}
+	}
+
+	for i, dep := range spec.DependsOn {
+		depPath := specPath.Child("dependsOn").Index(i)
+		namespace := dep.Namespace
+		if namespace == "" {
+			namespace = chart.Namespace
+		}
+		if dep.Name == "" {
+			errs = append(errs, field.Required(depPath.Child("name"), ""))
+		} else if dep.Name == chart.Name && namespace == chart.Namespace {
+			errs = append(errs, field.Invalid(depPath, dep, "a HelmChart may not depend on itself"))
+		}
+	}
+
+	if spec.Git != nil {
+		gitPath := specPath.Child("git")
+		if !validGitURL(spec.Git.URL) {
+			errs = append(errs, field.Invalid(gitPath.Child("url"), spec.Git.URL, "must be an HTTP(S) or SSH repository URL"))
+		}
+		errs = append(errs, validateDuration(spec.Git.Interval, gitPath.Child("interval"))...)
+		errs = append(errs, v.validateSecretRef(ctx, chart.Namespace, spec.Git.AuthSecret, gitPath.Child("authSecret"))...)
+	}
+
+	errs = append(errs, validateUpgradeWindow(spec.UpgradeWindow, specPath.Child("upgradeWindow"))...)
+	errs = append(errs, validatePlacement(&corev1.PodSpec{
+		NodeSelector:              spec.NodeSelector,
+		Affinity:                  spec.Affinity,
+		Tolerations:               spec.Tolerations,
+		PriorityClassName:         spec.PriorityClassName,
+		TopologySpreadConstraints: spec.TopologySpreadConstraints,
+	}, specPath)...)
+	if spec.JobResources != nil {
+		errs = append(errs, config.ValidateResources(spec.JobResources, specPath.Child("jobResources"))...)
+	}
+	errs = append(errs, v.validateJobRBAC(ctx, chart, specPath)...)
+	errs = append(errs, v.validateObjectRef(ctx, chart.Namespace, "Secret", spec.AuthSecret, spec.AuthSecretNamespace, specPath.Child("authSecret"), specPath.Child("authSecretNamespace"))...)
+	errs = append(errs, v.validateObjectRef(ctx, chart.Namespace, "Secret", spec.DockerRegistrySecret, spec.DockerRegistrySecretNamespace, specPath.Child("dockerRegistrySecret"), specPath.Child("dockerRegistrySecretNamespace"))...)
+	errs = append(errs, v.validateObjectRef(ctx, chart.Namespace, "ConfigMap", spec.RepoCAConfigMap, spec.RepoCAConfigMapNamespace, specPath.Child("repoCAConfigMap"), specPath.Child("repoCAConfigMapNamespace"))...)
+	errs = append(errs, v.validateValuesSecrets(ctx, chart.Namespace, true, spec.ValuesSecrets, specPath.Child("valuesSecrets"))...)
+	errs = append(errs, v.validateValuesConfigMaps(ctx, chart.Namespace, true, spec.ValuesConfigMaps, specPath.Child("valuesConfigMaps"))...)
+
+	return errs
+}
+
+// ValidateHelmChartConfig returns a list of errors for invalid fields in the HelmChartConfig spec.
+func (v *Validator) ValidateHelmChartConfig(ctx context.Context, chartConfig *v1.HelmChartConfig) field.ErrorList {
+	specPath := field.NewPath("spec")
+	errs := field.ErrorList{}
+	errs = append(errs, validateValues(chartConfig.Spec.Values, chartConfig.Spec.ValuesContent, specPath)...)
+	errs = append(errs, validateUpgradeWindow(chartConfig.Spec.UpgradeWindow, specPath.Child("upgradeWindow"))...)
+	errs = append(errs, validatePlacement(&corev1.PodSpec{
+		NodeSelector:              chartConfig.Spec.NodeSelector,
+		Affinity:                  chartConfig.Spec.Affinity,
+		Tolerations:               chartConfig.Spec.Tolerations,
+		PriorityClassName:         chartConfig.Spec.PriorityClassName,
+		TopologySpreadConstraints: chartConfig.Spec.TopologySpreadConstraints,
+	}, specPath)...)
+	if chartConfig.Spec.JobResources != nil {
+		errs = append(errs, config.ValidateResources(chartConfig.Spec.JobResources, specPath.Child("jobResources"))...)
+	}
+	if chartConfig.Spec.ChartSelector != nil {
+		errs = append(errs, metav1validation.ValidateLabelSelector(chartConfig.Spec.ChartSelector, metav1validation.LabelSelectorValidationOptions{}, specPath.Child("chartSelector"))...)
+	}
+	errs = append(errs, v.validateValuesSecrets(ctx, chartConfig.Namespace, false, chartConfig.Spec.ValuesSecrets, specPath.Child("valuesSecrets"))...)
+	errs = append(errs, v.validateValuesConfigMaps(ctx, chartConfig.Namespace, false, chartConfig.Spec.ValuesConfigMaps, specPath.Child("valuesConfigMaps"))...)
+	return errs
+}
+
+// validateValues ensures that the structured values and values content are both YAML objects.
+func validateValues(values *apiextv1.JSON, valuesContent string, specPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if !extjson.IsEmpty(values) {
+		if err := json.Unmarshal(values.Raw, &map[string]interface{}{}); err != nil {
+			errs = append(errs, field.Invalid(specPath.Child("values"), string(values.Raw), "must be an object: "+err.Error()))
+		}
+	}
+	if strings.TrimSpace(valuesContent) != "" {
+		j, err := extjson.FromYAML(valuesContent)
+		if err != nil {
+			errs = append(errs, field.Invalid(specPath.Child("valuesContent"), "<values>", "must be valid YAML: "+err.Error()))
+		} else if err := json.Unmarshal(j.Raw, &map[string]interface{}{}); err != nil {
+			errs = append(errs, field.Invalid(specPath.Child("valuesContent"), "<values>", "must be a YAML mapping of values"))
+		}
+	}
+	return errs
+}
+
+// validateHTTPURL ensures that the value is an absolute HTTP or HTTPS URL.
+func validateHTTPURL(raw string, fldPath *field.Path) field.ErrorList {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return field.ErrorList{field.Invalid(fldPath, raw, err.Error())}
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return field.ErrorList{field.Invalid(fldPath, raw, "must be an absolute http or https URL")}
+	}
+	return nil
+}
+
+// validGitURL returns true if the value is a URL with a scheme and host, or an scp-like SSH address such as `git@github.com:org/repo.git`.
+func validGitURL(raw string) bool {
+	if u, err := url.Parse(raw); err == nil && u.Scheme != "" && (u.Host != "" || u.Scheme == "file") {
+		return true
+	}
+	user, rest, ok := strings.Cut(raw, "@")
+	host, _, hasPath := strings.Cut(rest, ":")
+	return ok && hasPath && user != "" && host != "" && !strings.Contains(user, "/")
+}
+
+// validateDuration ensures that the duration, if set, is not negative.
+func validateDuration(d *metav1.Duration, fldPath *field.Path) field.ErrorList {
+	if d != nil && d.Duration < 0 {
+		return field.ErrorList{field.Invalid(fldPath, d.Duration.String(), "must not be negative")}
+	}
+	return nil
+}
+
+// validateUpgradeWindow ensures that the window, if set, has valid cron schedules, a positive duration, and a known time zone.
+func validateUpgradeWindow(window *v1.UpgradeWindow, fldPath *field.Path) field.ErrorList {
+	if window == nil {
+		return nil
+	}
+	errs := field.ErrorList{}
+	if len(window.Schedules) == 0 {
+		errs = append(errs, field.Required(fldPath.Child("schedules"), ""))
+	}
+	for i, spec := range window.Schedules {
+		if _, err := cron.ParseStandard(spec); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("schedules").Index(i), spec, err.Error()))
+		}
+	}
+	if window.Duration.Duration <= 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("duration"), window.Duration.Duration.String(), "must be positive"))
+	}
+	if window.TimeZone != "" {
+		if _, err := time.LoadLocation(window.TimeZone); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("timeZone"), window.TimeZone, err.Error()))
+		}
+	}
+	return errs
+}
+
+// placementFields are the pod spec fields that may be set on HelmCharts and HelmChartConfigs
+// to control scheduling of the helm job pod.
+var placementFields = []string{"nodeSelector", "affinity", "tolerations", "priorityClassName", "topologySpreadConstraints"}
+
+// validatePlacement validates the job pod scheduling settings, using the same validation as the apiserver
+// applies to pods. The settings are validated as part of an otherwise minimal pod spec, and only errors for
+// the scheduling fields are returned; these fields have the same names on the spec as on the pod spec.
+func validatePlacement(podSpec *corev1.PodSpec, specPath *field.Path) field.ErrorList {
+	podSpec = podSpec.DeepCopy()
+	podSpec.RestartPolicy = corev1.RestartPolicyOnFailure
+	podSpec.DNSPolicy = corev1.DNSClusterFirst
+	podSpec.Containers = []corev1.Container{{
+		Name:                     "helm",
+		Image:                    "helm",
+		ImagePullPolicy:          corev1.PullIfNotPresent,
+		TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+	}}
+	corePodSpec := &typedcore.PodSpec{}
+	if err := scheme.Convert(podSpec, corePodSpec, nil); err != nil {
+		return field.ErrorList{field.InternalError(specPath, err)}
+	}
+
+	errs := field.ErrorList{}
+	for _, err := range validation.ValidatePodSpec(corePodSpec, &metav1.ObjectMeta{}, specPath, opts) {
+		for _, name := range placementFields {
+			if prefix := specPath.Child(name).String(); err.Field == prefix || strings.HasPrefix(err.Field, prefix+".") || strings.HasPrefix(err.Field, prefix+"[") {
+				errs = append(errs, err)
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// validateJobRBAC ensures that the job ServiceAccount, RBAC scope, and job role are consistent.
+// An existing ServiceAccount must exist, and is used as-is; a Role can only be bound within the target namespace.
+func (v *Validator) validateJobRBAC(ctx context.Context, chart *v1.HelmChart, specPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	spec := chart.Spec
+	if spec.ServiceAccount != "" {
+		if spec.RBACScope != "" {
+			errs = append(errs, field.Forbidden(specPath.Child("rbacScope"), "may not be set when serviceAccount is set"))
+		}
+		if spec.JobRole != nil {
+			errs = append(errs, field.Forbidden(specPath.Child("jobRole"), "may not be set when serviceAccount is set"))
+		}
+		_, err := v.k8s.CoreV1().ServiceAccounts(chart.Namespace).Get(ctx, spec.ServiceAccount, metav1.GetOptions{})
+		return append(errs, lookupError(err, specPath.Child("serviceAccount"), spec.ServiceAccount)...)
+	}
+	if spec.RBACScope == v1.RBACScopeNamespaced && spec.CreateNamespace {
+		errs = append(errs, field.Forbidden(specPath.Child("rbacScope"), "may not be namespaced when createNamespace is set"))
+	}
+	if spec.JobRole != nil {
+		if spec.JobRole.Name == "" {
+			errs = append(errs, field.Required(specPath.Child("jobRole", "name"), ""))
+		}
+		if spec.JobRole.Kind == "Role" && spec.RBACScope != v1.RBACScopeNamespaced {
+			errs = append(errs, field.Invalid(specPath.Child("jobRole", "kind"), spec.JobRole.Kind, "a Role may only be bound when rbacScope is namespaced"))
+		}
+	}
+	return errs
+}
+
+// validateSecretRef ensures that the referenced Secret, if set, exists.
+func (v *Validator) validateSecretRef(ctx context.Context, namespace string, ref *corev1.LocalObjectReference, fldPath *field.Path) field.ErrorList {
+	if ref == nil || ref.Name == "" {
+		return nil
+	}
+	_, err := v.k8s.CoreV1().Secrets(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	return lookupError(err, fldPath.Child("name"), ref.Name)
+}
+
+// validateObjectRef ensures that the referenced Secret or ConfigMap, if set, exists. References to other namespaces
+// are not looked up, as whether they are permitted by a HelmChartReferenceGrant is reported by the controller, and
+// the existence of resources in other namespaces should not be disclosed.
+func (v *Validator) validateObjectRef(ctx context.Context, namespace, kind string, ref *corev1.LocalObjectReference, refNamespace string, fldPath, namespacePath *field.Path) field.ErrorList {
+	if ref == nil || ref.Name == "" {
+		return nil
+	}
+	return v.validateReference(ctx, namespace, kind, refNamespace, ref.Name, true, false, fldPath, namespacePath)
+}
+
+// validateReference ensures that a reference to a Secret or ConfigMap has a name, and that the referenced resource
+// exists if it is in the same namespace and is not optional.
+func (v *Validator) validateReference(ctx context.Context, namespace, kind, refNamespace, name string, crossNamespace, optional bool, fldPath, namespacePath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if refNamespace != "" && refNamespace != namespace {
+		if !crossNamespace {
+			return append(errs, field.Forbidden(namespacePath, "must be the namespace of the HelmChartConfig"))
+		}
+		for _, msg := range utilvalidation.IsDNS1123Label(refNamespace) {
+			errs = append(errs, field.Invalid(namespacePath, refNamespace, msg))
+		}
+	}
+	if name == "" {
+		return append(errs, field.Required(fldPath.Child("name"), ""))
+	}
+	if optional || (refNamespace != "" && refNamespace != namespace) {
+		return errs
+	}
+	var err error
+	switch kind {
+	case "Secret":
+		_, err = v.k8s.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	case "ConfigMap":
+		_, err = v.k8s.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	}
+	return append(errs, lookupError(err, fldPath.Child("name"), name)...)
+}
+
+// validateValuesSecrets ensures that each values Secret has a name, and exists unless it is optional.
+func (v *Validator) validateValuesSecrets(ctx context.Context, namespace string, crossNamespace bool, secrets []v1.SecretSpec, fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	for i, secret := range secrets {
+		errs = append(errs, v.validateReference(ctx, namespace, "Secret", secret.Namespace, secret.Name, crossNamespace, secret.IgnoreUpdates, fldPath.Index(i), fldPath.Index(i).Child("namespace"))...)
+	}
+	return errs
+}
+
+// validateValuesConfigMaps ensures that each values ConfigMap has a name, and exists unless it is optional.
+func (v *Validator) validateValuesConfigMaps(ctx context.Context, namespace string, crossNamespace bool, configMaps []v1.ConfigMapSpec, fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	for i, configMap := range configMaps {
+		errs = append(errs, v.validateReference(ctx, namespace, "ConfigMap", configMap.Namespace, configMap.Name, crossNamespace, configMap.IgnoreUpdates, fldPath.Index(i), fldPath.Index(i).Child("namespace"))...)
+	}
+	return errs
+}
+
+// lookupError converts an error from getting a referenced resource to a field error.
+func lookupError(err error, fldPath *field.Path, name string) field.ErrorList {
+	if err == nil {
+		return nil
+	}
+	if apierrors.IsNotFound(err) {
+		return field.ErrorList{field.NotFound(fldPath, name)}
+	}
+	return field.ErrorList{field.InternalError(fldPath, err)}
+}
@@ -0,0 +1,216 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newChart(mutate func(chart *v1.HelmChart)) *v1.HelmChart {
+	chart := &v1.HelmChart{
+		ObjectMeta: metav1.ObjectMeta{Name: "traefik", Namespace: "kube-system"},
+		Spec: v1.HelmChartSpec{
+			Chart:   "traefik",
+			Repo:    "https://traefik.github.io/charts",
+			Version: "37.0.0",
+		},
+	}
+	if mutate != nil {
+		mutate(chart)
+	}
+	return chart
+}
+
+func TestValidateHelmChart(t *testing.T) {
+	k8s := fake.NewClientset(
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "auth", Namespace: "kube-system"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "shared-values", Namespace: "kube-system"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "traefik-deployer", Namespace: "kube-system"}},
+	)
+	v := NewValidator(k8s)
+
+	tests := []struct {
+		name   string
+		chart  *v1.HelmChart
+		fields []string
+	}{
+		{"valid", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.AuthSecret = &corev1.LocalObjectReference{Name: "auth"}
+			chart.Spec.ValuesContent = "replicas: 2\n"
+			chart.Spec.Values = &apiextv1.JSON{Raw: []byte(`{"image":{"tag":"v3"}}`)}
+		}), nil},
+		{"valid version range", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.Version = ">=37.0 <38"
+		}), nil},
+		{"invalid version", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.Version = "latest!"
+		}), []string{"spec.version"}},
+		{"repo with oci chart", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.Chart = "oci://ghcr.io/traefik/helm/traefik"
+		}), []string{"spec.repo"}},
+		{"invalid repo", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.Repo = "traefik.github.io/charts"
+		}), []string{"spec.repo"}},
+		{"invalid chart content", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.ChartContent = "not base64!"
+		}), []string{"spec.chartContent"}},
+		{"unparsable values content", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.ValuesContent = "replicas: [2\n"
+		}), []string{"spec.valuesContent"}},
+		{"values content not a mapping", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.ValuesContent = "- replicas\n"
+		}), []string{"spec.valuesContent"}},
+		{"values not an object", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.Values = &apiextv1.JSON{Raw: []byte(`["replicas"]`)}
+		}), []string{"spec.values"}},
+		{"missing secrets", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.AuthSecret = &corev1.LocalObjectReference{Name: "missing"}
+			chart.Spec.DockerRegistrySecret = &corev1.LocalObjectReference{Name: "missing"}
+			chart.Spec.ValuesSecrets = []v1.SecretSpec{{Name: "auth"}, {Name: "missing"}, {Name: "optional", IgnoreUpdates: true}}
+			chart.Spec.RepoCAConfigMap = &corev1.LocalObjectReference{Name: "missing"}
+		}), []string{"spec.authSecret.name", "spec.dockerRegistrySecret.name", "spec.valuesSecrets[1].name", "spec.repoCAConfigMap.name"}},
+		{"values config maps", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.ValuesConfigMaps = []v1.ConfigMapSpec{{Name: "shared-values", Keys: []string{"values.yaml"}}, {Name: "optional", IgnoreUpdates: true}}
+		}), nil},
+		{"missing values config maps", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.ValuesConfigMaps = []v1.ConfigMapSpec{{Name: "missing"}, {}}
+		}), []string{"spec.valuesConfigMaps[0].name", "spec.valuesConfigMaps[1].name"}},
+		{"cross-namespace references", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.AuthSecret = &corev1.LocalObjectReference{Name: "registry-auth"}
+			chart.Spec.AuthSecretNamespace = "shared"
+			chart.Spec.RepoCAConfigMap = &corev1.LocalObjectReference{Name: "ca"}
+			chart.Spec.RepoCAConfigMapNamespace = "shared"
+			chart.Spec.ValuesSecrets = []v1.SecretSpec{{Name: "values", Namespace: "shared", Keys: []string{"values.yaml"}}}
+		}), nil},
+		{"invalid cross-namespace references", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.DockerRegistrySecret = &corev1.LocalObjectReference{Name: "registry-auth"}
+			chart.Spec.DockerRegistrySecretNamespace = "Shared"
+			chart.Spec.ValuesConfigMaps = []v1.ConfigMapSpec{{Namespace: "shared"}}
+		}), []string{"spec.dockerRegistrySecretNamespace", "spec.valuesConfigMaps[0].name"}},
+		{"invalid target namespace", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.TargetNamespace = "Kube_System"
+		}), []string{"spec.targetNamespace"}},
+		{"negative timeout", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.Timeout = &metav1.Duration{Duration: -time.Minute}
+		}), []string{"spec.timeout"}},
+		{"health checks", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.HealthChecks = &v1.HealthChecks{Workloads: true, HelmTest: true, Timeout: &metav1.Duration{Duration: 10 * time.Minute}}
+		}), nil},
+		{"invalid health checks", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.HealthChecks = &v1.HealthChecks{Timeout: &metav1.Duration{}}
+		}), []string{"spec.healthChecks", "spec.healthChecks.timeout"}},
+		{"negative test timeout", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.Test = &v1.ReleaseTest{Timeout: &metav1.Duration{Duration: -time.Minute}, RollbackOnFailure: true}
+		}), []string{"spec.test.timeout"}},
+		{"self dependency", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.DependsOn = []v1.HelmChartReference{{Name: "traefik"}, {Name: "traefik-crd"}, {}}
+		}), []string{"spec.dependsOn[0]", "spec.dependsOn[2].name"}},
+		{"git", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.Git = &v1.GitSource{URL: "git@github.com:traefik/traefik-helm-chart.git", AuthSecret: &corev1.LocalObjectReference{Name: "auth"}}
+		}), nil},
+		{"upgrade window", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.UpgradeWindow = &v1.UpgradeWindow{Schedules: []string{"0 2 * * 6", "@daily"}, Duration: metav1.Duration{Duration: time.Hour}, TimeZone: "Europe/Berlin"}
+		}), nil},
+		{"invalid upgrade window", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.UpgradeWindow = &v1.UpgradeWindow{Schedules: []string{"0 2 * *"}, TimeZone: "Mars/Olympus"}
+		}), []string{"spec.upgradeWindow.schedules[0]", "spec.upgradeWindow.duration", "spec.upgradeWindow.timeZone"}},
+		{"placement", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.NodeSelector = map[string]string{"node-pool": "system"}
+			chart.Spec.Tolerations = []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}}
+			chart.Spec.PriorityClassName = "low-priority"
+		}), nil},
+		{"invalid placement", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.NodeSelector = map[string]string{"node pool": "system"}
+			chart.Spec.Tolerations = []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists, Value: "true"}}
+			chart.Spec.PriorityClassName = "Low_Priority"
+		}), []string{"spec.nodeSelector", "spec.tolerations[0].operator", "spec.priorityClassName"}},
+		{"job resources", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.JobResources = &corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			}
+		}), nil},
+		{"invalid job resources", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.JobResources = &corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			}
+		}), []string{"spec.jobResources.requests"}},
+		{"existing service account", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.ServiceAccount = "traefik-deployer"
+		}), nil},
+		{"invalid service account", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.ServiceAccount = "missing"
+			chart.Spec.RBACScope = v1.RBACScopeNamespaced
+			chart.Spec.JobRole = &v1.JobRoleReference{Name: "traefik-deployer"}
+		}), []string{"spec.serviceAccount", "spec.rbacScope", "spec.jobRole"}},
+		{"namespaced role", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.RBACScope = v1.RBACScopeNamespaced
+			chart.Spec.JobRole = &v1.JobRoleReference{Kind: "Role", Name: "traefik-deployer"}
+		}), nil},
+		{"invalid namespaced role", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.CreateNamespace = true
+			chart.Spec.RBACScope = v1.RBACScopeNamespaced
+			chart.Spec.JobRole = &v1.JobRoleReference{Kind: "Role"}
+		}), []string{"spec.rbacScope", "spec.jobRole.name"}},
+		{"role with cluster scope", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.JobRole = &v1.JobRoleReference{Kind: "Role", Name: "traefik-deployer"}
+		}), []string{"spec.jobRole.kind"}},
+		{"invalid git", newChart(func(chart *v1.HelmChart) {
+			chart.Spec.Git = &v1.GitSource{URL: "traefik-helm-chart", AuthSecret: &corev1.LocalObjectReference{Name: "missing"}}
+		}), []string{"spec.git.url", "spec.git.authSecret.name"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := v.ValidateHelmChart(context.Background(), tt.chart)
+			var fields []string
+			for _, err := range errs {
+				fields = append(fields, err.Field)
+			}
+			assert.ElementsMatch(t, tt.fields, fields, errs.ToAggregate())
+		})
+	}
+}
+
+func TestValidateHelmChartConfig(t *testing.T) {
+	assert := assert.New(t)
+	v := NewValidator(fake.NewClientset())
+	config := &v1.HelmChartConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "traefik", Namespace: "kube-system"},
+		Spec: v1.HelmChartConfigSpec{
+			ValuesContent: "replicas: 2\n",
+		},
+	}
+	assert.Empty(v.ValidateHelmChartConfig(context.Background(), config))
+
+	config.Spec.ValuesContent = "replicas: 2\n  image: traefik\n"
+	config.Spec.ValuesSecrets = []v1.SecretSpec{{Name: "missing"}}
+	errs := v.ValidateHelmChartConfig(context.Background(), config)
+	assert.Len(errs, 2)
+
+	config.Spec.ValuesContent = "replicas: 2\n"
+	config.Spec.ValuesSecrets = []v1.SecretSpec{{Name: "values", Namespace: "shared", IgnoreUpdates: true}}
+	errs = v.ValidateHelmChartConfig(context.Background(), config)
+	if assert.Len(errs, 1) {
+		assert.Equal("spec.valuesSecrets[0].namespace", errs[0].Field)
+	}
+
+	config.Spec.ValuesSecrets = nil
+	config.Spec.ChartSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}
+	assert.Empty(v.ValidateHelmChartConfig(context.Background(), config))
+
+	config.Spec.ChartSelector.MatchLabels["tier"] = "system!"
+	errs = v.ValidateHelmChartConfig(context.Background(), config)
+	if assert.Len(errs, 1) {
+		assert.Equal("spec.chartSelector.matchLabels", errs[0].Field)
+	}
+}
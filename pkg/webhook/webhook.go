@@ -0,0 +1,279 @@
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/k3s-io/helm-controller/pkg/controllers/chart"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// validatePath is the path that admission reviews are served on.
+	validatePath = "/validate"
+	// certCheckInterval is the interval at which the serving certificate is checked for renewal.
+	certCheckInterval = time.Hour
+)
+
+// Options configures the webhook server.
+type Options struct {
+	// Name of the controller. Used as the name of the ValidatingWebhookConfiguration and the prefix of the
+	// certificate Secret name; HelmCharts managed by other controllers are not validated.
+	Name string
+	// Namespace that the webhook Service and certificate Secret are in.
+	Namespace string
+	// Service that routes to the webhook server.
+	Service string
+	// Port that the webhook server listens on, and that the Service exposes.
+	Port int
+	// WatchNamespace restricts validation to a single namespace, if set.
+	WatchNamespace string
+}
+
+// Server serves validating admission reviews for HelmChart and HelmChartConfig resources,
+// using a self-signed serving certificate that it generates and renews.
+type Server struct {
+	opts      Options
+	k8s       kubernetes.Interface
+	validator *Validator
+	cert      atomic.Pointer[tls.Certificate]
+	caBundle  []byte
+}
+
+// Start generates or loads the serving certificate, registers the ValidatingWebhookConfiguration, and starts the webhook server.
+func Start(ctx context.Context, k8s kubernetes.Interface, opts Options) error {
+	s := &Server{
+		opts:      opts,
+		k8s:       k8s,
+		validator: NewValidator(k8s),
+	}
+	if err := s.syncCertificate(ctx); err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", opts.Port),
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				return s.cert.Load(), nil
+			},
+		},
+	}
+
+	logger := klog.FromContext(ctx)
+	go func() {
+		if err := server.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error(err, "Webhook server failed")
+		}
+	}()
+	go func() {
+		ticker := time.NewTicker(certCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				_ = server.Close()
+				return
+			case <-ticker.C:
+				if err := s.syncCertificate(ctx); err != nil {
+					logger.Error(err, "Failed to renew webhook certificate")
+				}
+			}
+		}
+	}()
+
+	logger.Info("Started validating webhook", "port", opts.Port, "service", opts.Namespace+"/"+opts.Service)
+	return nil
+}
+
+// syncCertificate ensures that the serving certificate is valid, and that the ValidatingWebhookConfiguration trusts its CA.
+func (s *Server) syncCertificate(ctx context.Context) error {
+	secret, err := ensureCertificate(ctx, s.k8s.CoreV1().Secrets(s.opts.Namespace), s.opts.Name+"-webhook-tls", serviceDNSNames(s.opts.Namespace, s.opts.Service))
+	if err != nil {
+		return err
+	}
+	cert, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return fmt.Errorf("failed to load webhook certificate: %w", err)
+	}
+	s.cert.Store(&cert)
+
+	caBundle := secret.Data[corev1.ServiceAccountRootCAKey]
+	if string(caBundle) == string(s.caBundle) {
+		return nil
+	}
+	if err := s.registerWebhooks(ctx, caBundle); err != nil {
+		return err
+	}
+	s.caBundle = caBundle
+	return nil
+}
+
+// registerWebhooks creates or updates the ValidatingWebhookConfiguration for HelmChart and HelmChartConfig resources.
+func (s *Server) registerWebhooks(ctx context.Context, caBundle []byte) error {
+	var namespaceSelector *metav1.LabelSelector
+	if s.opts.WatchNamespace != "" {
+		namespaceSelector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{corev1.LabelMetadataName: s.opts.WatchNamespace},
+		}
+	}
+	webhook := func(resource string) admissionregistrationv1.ValidatingWebhook {
+		return admissionregistrationv1.ValidatingWebhook{
+			Name: resource + "." + v1.SchemeGroupVersion.Group,
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				Service: &admissionregistrationv1.ServiceReference{
+					Namespace: s.opts.Namespace,
+					Name:      s.opts.Service,
+					Path:      ptr.To(validatePath),
+					Port:      ptr.To(int32(s.opts.Port)),
+				},
+				CABundle: caBundle,
+			},
+			Rules: []admissionregistrationv1.RuleWithOperations{{
+				Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{v1.SchemeGroupVersion.Group},
+					APIVersions: []string{v1.SchemeGroupVersion.Version},
+					Resources:   []string{resource},
+					Scope:       ptr.To(admissionregistrationv1.NamespacedScope),
+				},
+			}},
+			NamespaceSelector:       namespaceSelector,
+			FailurePolicy:           ptr.To(admissionregistrationv1.Ignore),
+			SideEffects:             ptr.To(admissionregistrationv1.SideEffectClassNone),
+			AdmissionReviewVersions: []string{"v1"},
+			TimeoutSeconds:          ptr.To(int32(10)),
+		}
+	}
+
+	client := s.k8s.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	webhooks := []admissionregistrationv1.ValidatingWebhook{webhook("helmcharts"), webhook("helmchartconfigs")}
+	config, err := client.Get(ctx, s.opts.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		config = &admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: s.opts.Name},
+			Webhooks:   webhooks,
+		}
+		_, err = client.Create(ctx, config, metav1.CreateOptions{})
+	} else if err == nil {
+		config = config.DeepCopy()
+		config.Webhooks = webhooks
+		_, err = client.Update(ctx, config, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to register validating webhook configuration: %w", err)
+	}
+	return nil
+}
+
+// ServeHTTP decodes an AdmissionReview request, and responds with the result of validating the object.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != validatePath || r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	review := &admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(r.Body).Decode(review); err != nil || review.Request == nil {
+		http.Error(w, "invalid AdmissionReview", http.StatusBadRequest)
+		return
+	}
+
+	response, err := s.admit(r.Context(), review.Request)
+	if err != nil {
+		response = &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result:  &apierrors.NewBadRequest(err.Error()).ErrStatus,
+		}
+	}
+	response.UID = review.Request.UID
+	review.Request = nil
+	review.Response = response
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(review)
+}
+
+// admit validates the object in the admission request. Updates that do not change the spec are always
+// allowed, so that metadata and finalizers can be updated on objects that reference deleted resources.
+func (s *Server) admit(ctx context.Context, req *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, error) {
+	var errs field.ErrorList
+	switch req.Kind.Kind {
+	case "HelmChart":
+		helmChart, oldChart := &v1.HelmChart{}, &v1.HelmChart{}
+		if err := decode(req, helmChart, oldChart); err != nil {
+			return nil, err
+		}
+		if skipHelmChart(helmChart, oldChart, s.opts.Name) {
+			break
+		}
+		errs = s.validator.ValidateHelmChart(ctx, helmChart)
+	case "HelmChartConfig":
+		config, oldConfig := &v1.HelmChartConfig{}, &v1.HelmChartConfig{}
+		if err := decode(req, config, oldConfig); err != nil {
+			return nil, err
+		}
+		if config.DeletionTimestamp != nil || (oldConfig.Name != "" && equality.Semantic.DeepEqual(config.Spec, oldConfig.Spec)) {
+			break
+		}
+		errs = s.validator.ValidateHelmChartConfig(ctx, config)
+	}
+
+	if len(errs) == 0 {
+		return &admissionv1.AdmissionResponse{Allowed: true}, nil
+	}
+	gk := v1.SchemeGroupVersion.WithKind(req.Kind.Kind).GroupKind()
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result:  &apierrors.NewInvalid(gk, req.Name, errs).ErrStatus,
+	}, nil
+}
+
+// skipHelmChart returns true if the HelmChart should not be validated, as it is being deleted, its spec
+// is unchanged, or it is not managed by this controller.
+func skipHelmChart(helmChart, oldChart *v1.HelmChart, managedBy string) bool {
+	if helmChart.DeletionTimestamp != nil {
+		return true
+	}
+	if oldChart.Name != "" && equality.Semantic.DeepEqual(helmChart.Spec, oldChart.Spec) {
+		return true
+	}
+	if _, ok := helmChart.Annotations[chart.AnnotationUnmanaged]; ok {
+		return true
+	}
+	if owner, ok := helmChart.Annotations[chart.AnnotationManagedBy]; ok && owner != managedBy {
+		return true
+	}
+	return false
+}
+
+// decode unmarshals the object, and the old object if set, from the admission request.
+func decode(req *admissionv1.AdmissionRequest, obj, oldObj interface{}) error {
+	if err := json.Unmarshal(req.Object.Raw, obj); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", req.Kind.Kind, err)
+	}
+	if len(req.OldObject.Raw) > 0 {
+		if err := json.Unmarshal(req.OldObject.Raw, oldObj); err != nil {
+			return fmt.Errorf("failed to decode old %s: %w", req.Kind.Kind, err)
+		}
+	}
+	return nil
+}
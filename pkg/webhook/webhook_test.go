@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	v1 "github.com/k3s-io/helm-controller/pkg/apis/helm.cattle.io/v1"
+	"github.com/k3s-io/helm-controller/pkg/controllers/chart"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestAdmit(t *testing.T) {
+	s := &Server{
+		opts:      Options{Name: "helm-controller"},
+		validator: NewValidator(fake.NewClientset()),
+	}
+	invalid := newChart(func(chart *v1.HelmChart) {
+		chart.Spec.ValuesContent = "replicas: [2\n"
+	})
+	raw := func(obj interface{}) runtime.RawExtension {
+		b, _ := json.Marshal(obj)
+		return runtime.RawExtension{Raw: b}
+	}
+
+	tests := []struct {
+		name    string
+		object  *v1.HelmChart
+		old     *v1.HelmChart
+		allowed bool
+	}{
+		{"valid create", newChart(nil), nil, true},
+		{"invalid create", invalid, nil, false},
+		{"invalid update", invalid, newChart(nil), false},
+		{"unchanged spec", invalid, invalid, true},
+		{"managed by another controller", newChart(func(c *v1.HelmChart) {
+			c.Spec.ValuesContent = invalid.Spec.ValuesContent
+			c.Annotations = map[string]string{chart.AnnotationManagedBy: "k3s"}
+		}), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &admissionv1.AdmissionRequest{
+				Kind:   metav1.GroupVersionKind{Group: "helm.cattle.io", Version: "v1", Kind: "HelmChart"},
+				Name:   tt.object.Name,
+				Object: raw(tt.object),
+			}
+			if tt.old != nil {
+				req.Operation = admissionv1.Update
+				req.OldObject = raw(tt.old)
+			}
+			response, err := s.admit(context.Background(), req)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.allowed, response.Allowed)
+			if !tt.allowed {
+				assert.Equal(t, "spec.valuesContent", response.Result.Details.Causes[0].Field)
+			}
+		})
+	}
+}
+
+func TestEnsureCertificate(t *testing.T) {
+	assert := assert.New(t)
+	k8s := fake.NewClientset()
+	secrets := k8s.CoreV1().Secrets("kube-system")
+	dnsNames := serviceDNSNames("kube-system", "helm-controller-webhook")
+
+	secret, err := ensureCertificate(context.Background(), secrets, "helm-controller-webhook-tls", dnsNames)
+	assert.NoError(err)
+	assert.True(certificateValid(secret, dnsNames, time.Now()))
+	assert.False(certificateValid(secret, dnsNames, time.Now().Add(certMaxAge-certRenewBefore)))
+	assert.False(certificateValid(secret, serviceDNSNames("helm-controller", "helm-controller-webhook"), time.Now()))
+
+	// an existing valid certificate is reused
+	again, err := ensureCertificate(context.Background(), secrets, "helm-controller-webhook-tls", dnsNames)
+	assert.NoError(err)
+	assert.Equal(secret.Data, again.Data)
+
+	// the certificate is regenerated if the service changes
+	renewed, err := ensureCertificate(context.Background(), secrets, "helm-controller-webhook-tls", serviceDNSNames("kube-system", "webhook"))
+	assert.NoError(err)
+	assert.NotEqual(secret.Data, renewed.Data)
+}
+
+func TestEnsureCertificateRace(t *testing.T) {
+	assert := assert.New(t)
+	dnsNames := serviceDNSNames("kube-system", "helm-controller-webhook")
+	stored := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "helm-controller-webhook-tls"},
+		Data:       map[string][]byte{corev1.TLSCertKey: []byte("stored")},
+	}
+
+	// another replica creates the secret after it was found not to exist
+	k8s := fake.NewClientset()
+	k8s.PrependReactor("create", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if err := k8s.Tracker().Add(stored); err != nil {
+			return true, nil, err
+		}
+		return true, nil, apierrors.NewAlreadyExists(corev1.Resource("secrets"), stored.Name)
+	})
+	secret, err := ensureCertificate(context.Background(), k8s.CoreV1().Secrets("kube-system"), stored.Name, dnsNames)
+	assert.NoError(err)
+	assert.Equal(stored.Data, secret.Data)
+
+	// another replica updates the secret after it was found to be invalid
+	k8s = fake.NewClientset(stored)
+	k8s.PrependReactor("update", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewConflict(corev1.Resource("secrets"), stored.Name, nil)
+	})
+	secret, err = ensureCertificate(context.Background(), k8s.CoreV1().Secrets("kube-system"), stored.Name, dnsNames)
+	assert.NoError(err)
+	assert.Equal(stored.Data, secret.Data)
+}